@@ -0,0 +1,28 @@
+package v1alpha1
+
+// AsNetworkPolicy returns a NetworkPolicy with the FQDN rules, resolve settings and status of the cluster network
+// policy. It allows the FQDNs of cluster network policies to be resolved like the FQDNs of network policies, the
+// actions and selectors of the rules are not part of it.
+func (cnp *ClusterNetworkPolicy) AsNetworkPolicy() *NetworkPolicy {
+	egresses := make([]EgressRule, 0, len(cnp.Spec.Egresses))
+	for _, rule := range cnp.Spec.Egresses {
+		egresses = append(egresses, *rule.EgressRule.DeepCopy())
+	}
+	return &NetworkPolicy{
+		ObjectMeta: *cnp.ObjectMeta.DeepCopy(),
+		Spec: NetworkPolicySpec{
+			MatchLabels:           cnp.Spec.MatchLabels,
+			MatchExpressions:      cnp.Spec.MatchExpressions,
+			Egresses:              egresses,
+			EnabledNetworkType:    cnp.Spec.EnabledNetworkType,
+			ResolveTimeoutSeconds: cnp.Spec.ResolveTimeoutSeconds,
+			RetryTimeoutSeconds:   cnp.Spec.RetryTimeoutSeconds,
+			TTLSeconds:            cnp.Spec.TTLSeconds,
+			BlockPrivateIPs:       &cnp.Spec.BlockPrivateIPs,
+			Resolver:              cnp.Spec.Resolver,
+			AddressRetention:      cnp.Spec.AddressRetention,
+			AllowedCNAMESuffixes:  cnp.Spec.AllowedCNAMESuffixes,
+		},
+		Status: *cnp.Status.DeepCopy(),
+	}
+}
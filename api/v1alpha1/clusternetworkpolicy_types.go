@@ -0,0 +1,215 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterOutputKind defines the kind of admin network policy generated from a cluster network policy
+//
+//   - Options are one of: 'AdminNetworkPolicy', 'BaselineAdminNetworkPolicy'
+//
+// +kubebuilder:validation:Enum=AdminNetworkPolicy;BaselineAdminNetworkPolicy
+type ClusterOutputKind string
+
+const (
+	// ClusterOutputKindAdminNetworkPolicy generates a policy.networking.k8s.io/v1alpha1 AdminNetworkPolicy, which is
+	// evaluated before the network policies of the namespaces
+	ClusterOutputKindAdminNetworkPolicy ClusterOutputKind = "AdminNetworkPolicy"
+	// ClusterOutputKindBaselineAdminNetworkPolicy generates the policy.networking.k8s.io/v1alpha1
+	// BaselineAdminNetworkPolicy of the cluster, which is evaluated after the network policies of the namespaces
+	ClusterOutputKindBaselineAdminNetworkPolicy ClusterOutputKind = "BaselineAdminNetworkPolicy"
+)
+
+// RuleAction defines what happens to traffic matching a cluster egress rule
+//
+//   - Options are one of: 'Allow', 'Deny', 'Pass'
+//
+// +kubebuilder:validation:Enum=Allow;Deny;Pass
+type RuleAction string
+
+const (
+	// RuleActionAllow allows the traffic, regardless of the network policies of the namespaces
+	RuleActionAllow RuleAction = "Allow"
+	// RuleActionDeny denies the traffic, regardless of the network policies of the namespaces
+	RuleActionDeny RuleAction = "Deny"
+	// RuleActionPass skips the remaining AdminNetworkPolicy rules and delegates the traffic to the network policies of
+	// the namespaces. Only supported by AdminNetworkPolicies.
+	RuleActionPass RuleAction = "Pass"
+)
+
+// ClusterEgressRule defines an action for outbound network traffic to the specified FQDNs on the specified ports
+type ClusterEgressRule struct {
+	// Name identifies the rule in the generated policy. Defaults to the index of the rule if not specified.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=100
+	Name string `json:"name,omitempty"`
+
+	// Action is applied to the traffic matching the rule.
+	//
+	//  - Options are one of: 'Allow', 'Deny', 'Pass'
+	//  - Defaults to 'Allow' if not specified
+	//
+	// +kubebuilder:default:=Allow
+	Action RuleAction `json:"action,omitempty"`
+
+	EgressRule `json:",inline"`
+}
+
+// ClusterNetworkPolicySpec defines the desired state of ClusterNetworkPolicy.
+//
+// +kubebuilder:validation:XValidation:rule="self.outputKind != 'AdminNetworkPolicy' || has(self.priority)",message="priority is required when outputKind is AdminNetworkPolicy"
+// +kubebuilder:validation:XValidation:rule="self.outputKind != 'BaselineAdminNetworkPolicy' || self.egress.all(r, !has(r.action) || r.action != 'Pass')",message="the Pass action is not supported by BaselineAdminNetworkPolicies"
+// +kubebuilder:validation:XValidation:rule="!has(self.resolver) || !has(self.resolver.tls) || !has(self.resolver.tls.caSecretRef)",message="resolver.tls.caSecretRef is not supported by cluster network policies"
+// +kubebuilder:validation:XValidation:rule="self.egress.all(r, !has(r.toSRV) || size(r.toSRV) == 0)",message="toSRV is not supported by cluster network policies"
+type ClusterNetworkPolicySpec struct {
+	// OutputKind is the kind of admin network policy generated from this cluster network policy.
+	//
+	//  - 'AdminNetworkPolicy' rules take precedence over the network policies of the namespaces, ordered by Priority
+	//  - 'BaselineAdminNetworkPolicy' rules apply when the network policies of the namespaces do not match. There is only one BaselineAdminNetworkPolicy per cluster, so only one cluster network policy can use this kind. The Ready condition of the others reports OutputConflict.
+	//  - Defaults to 'AdminNetworkPolicy' if not specified
+	//
+	// +kubebuilder:default:=AdminNetworkPolicy
+	OutputKind ClusterOutputKind `json:"outputKind,omitempty"`
+
+	// Priority orders the AdminNetworkPolicies of the cluster, lower values take precedence. Required for AdminNetworkPolicies, ignored for BaselineAdminNetworkPolicies.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000
+	Priority *int32 `json:"priority,omitempty"`
+
+	// NamespaceSelector defines which namespaces this cluster network policy applies to. An empty selector selects all namespaces.
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// MatchLabels defines which pods of the selected namespaces this cluster network policy applies to. All pods are selected if neither MatchLabels nor MatchExpressions are specified.
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=label
+	// +listMapKey=value
+	MatchLabels []MatchLabel `json:"matchLabels,omitempty"`
+
+	// MatchExpressions defines which pods of the selected namespaces this cluster network policy applies to.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=30
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// Egresses defines the outbound network traffic rules for the selected pods. Rules are evaluated in order, the first matching rule applies.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=30
+	Egresses []ClusterEgressRule `json:"egress"`
+
+	// EnabledNetworkType defines which type of IP addresses to match.
+	//
+	//  - Options are one of: 'all', 'ipv4', 'ipv6'
+	//  - Defaults to 'ipv4' if not specified
+	//
+	// +kubebuilder:default:=ipv4
+	EnabledNetworkType NetworkType `json:"enabledNetworkType,omitempty"`
+
+	// The timeout to use for lookups of the FQDNs.
+	//
+	//  - Defaults to 3 seconds if not specified
+	//  - Maximum value is 60 seconds
+	//  - Minimum value is 1 second
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=60
+	// +kubebuilder:default:=3
+	ResolveTimeoutSeconds int32 `json:"resolveTimeoutSeconds,omitempty"`
+
+	// How long the resolving of an individual FQDN should be retried in case of errors before being removed from the generated policy.
+	//
+	//  - Defaults to 3600 (1 hour) if not specified (nil)
+	//  - Maximum value is 86400 (24 hours)
+	//
+	// +kubebuilder:validation:Maximum=86400
+	// +kubebuilder:default:=3600
+	RetryTimeoutSeconds int32 `json:"retryTimeoutSeconds,omitempty"`
+
+	// The interval at which the IP addresses of the FQDNs are re-evaluated when the DNS answer does not provide a TTL.
+	//
+	//  - Defaults to 60 seconds if not specified
+	//  - Maximum value is 1800 seconds
+	//  - Minimum value is 5 seconds
+	//
+	// +kubebuilder:validation:Minimum=5
+	// +kubebuilder:validation:Maximum=1800
+	// +kubebuilder:default:=60
+	TTLSeconds int32 `json:"ttlSeconds,omitempty"`
+
+	// When set to true, all private IPs are omitted from the rules unless otherwise specified at the rule level.
+	//
+	//  - Defaults to false if not specified
+	BlockPrivateIPs bool `json:"blockPrivateIPs,omitempty"`
+
+	// Resolver defines the nameservers used to resolve the FQDNs of this cluster network policy.
+	//
+	//  - Defaults to the resolver configured for the operator if not specified
+	//
+	// +kubebuilder:validation:Optional
+	Resolver *ResolverConfig `json:"resolver,omitempty"`
+
+	// AddressRetention defines how the addresses of an FQDN are updated when it is resolved again.
+	//
+	//  - Defaults to replacing the addresses with the addresses of the latest lookup if not specified
+	//
+	// +kubebuilder:validation:Optional
+	AddressRetention *AddressRetention `json:"addressRetention,omitempty"`
+
+	// AllowedCNAMESuffixes restricts the CNAME chains of the FQDNs to the given domains and their subdomains. When the CNAME chain of an FQDN leaves these domains, its resolution stops with the reason CNAME_NOT_ALLOWED and its addresses are removed. FQDNs also fail with this reason if the resolver cannot report their full CNAME chain.
+	//
+	//  - Defaults to allowing any CNAME if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=50
+	// +listType=set
+	AllowedCNAMESuffixes []FQDN `json:"allowedCNAMESuffixes,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// ClusterNetworkPolicy is the Schema for the clusternetworkpolicies API. It renders FQDN egress rules into AdminNetworkPolicies or the BaselineAdminNetworkPolicy of the cluster, which namespace owners cannot override.
+//
+// +kubebuilder:resource:path=clusternetworkpolicies,singular=clusternetworkpolicy,scope=Cluster,shortName={fecnp,fcnp}
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.outputKind`,description="Generated policy kind"
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`,description="AdminNetworkPolicy priority"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Ready condition status"
+// +kubebuilder:printcolumn:name="Resolved",type=string,JSONPath=`.status.conditions[?(@.type=="Resolved")].status`,description="Resolved condition status"
+// +kubebuilder:printcolumn:name="Applied IPs",type=integer,JSONPath=`.status.appliedAddressCount`,description="Number of applied IPs"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type ClusterNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterNetworkPolicySpec `json:"spec,omitempty"`
+	Status NetworkPolicyStatus      `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterNetworkPolicyList contains a list of ClusterNetworkPolicy.
+type ClusterNetworkPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterNetworkPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterNetworkPolicy{}, &ClusterNetworkPolicyList{})
+}
@@ -0,0 +1,173 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FQDNGovernance checks FQDNs against the FQDNGovernancePolicies selecting a namespace
+type FQDNGovernance struct {
+	policies []compiledGovernancePolicy
+	// forbidCIDRs and forbidCustomResolvers are set if any of the policies forbids CIDRs or custom resolvers
+	forbidCIDRs           bool
+	forbidCustomResolvers bool
+}
+
+type compiledGovernancePolicy struct {
+	name           string
+	allowed        []FQDN
+	denied         []FQDN
+	allowedRegexes []*regexp.Regexp
+	deniedRegexes  []*regexp.Regexp
+	// err is the error of the first regex that does not compile, the policy then denies all FQDNs
+	err error
+}
+
+// NewFQDNGovernance returns the FQDNGovernance of the policies. The regexes are compiled once, a policy with an
+// invalid regex denies all FQDNs.
+func NewFQDNGovernance(policies []FQDNGovernancePolicy) *FQDNGovernance {
+	governance := &FQDNGovernance{policies: make([]compiledGovernancePolicy, 0, len(policies))}
+	for _, policy := range policies {
+		compiled := compiledGovernancePolicy{
+			name:    policy.Name,
+			allowed: policy.Spec.AllowedSuffixes,
+			denied:  policy.Spec.DeniedSuffixes,
+		}
+		compile := func(exprs []string) []*regexp.Regexp {
+			regexes := make([]*regexp.Regexp, 0, len(exprs))
+			for _, expr := range exprs {
+				regex, err := CompileFQDNRegex(expr)
+				if err != nil {
+					if compiled.err == nil {
+						compiled.err = fmt.Errorf("invalid regex %q: %w", expr, err)
+					}
+					continue
+				}
+				regexes = append(regexes, regex)
+			}
+			return regexes
+		}
+		compiled.allowedRegexes = compile(policy.Spec.AllowedRegexes)
+		compiled.deniedRegexes = compile(policy.Spec.DeniedRegexes)
+		governance.policies = append(governance.policies, compiled)
+		governance.forbidCIDRs = governance.forbidCIDRs || policy.Spec.ForbidCIDRs
+		governance.forbidCustomResolvers = governance.forbidCustomResolvers || policy.Spec.ForbidCustomResolvers
+	}
+	return governance
+}
+
+// CompileFQDNRegex compiles a regex of an FQDNGovernancePolicy. The regex must match the whole FQDN, FQDNs are
+// case-insensitive.
+func CompileFQDNRegex(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)^(?:" + expr + ")$")
+}
+
+// JoinFQDNGovernance returns the FQDNGovernance checking FQDNs against the policies of all governances, or nil if
+// there are none
+func JoinFQDNGovernance(governances ...*FQDNGovernance) *FQDNGovernance {
+	var joined *FQDNGovernance
+	for _, governance := range governances {
+		if governance == nil {
+			continue
+		}
+		if joined == nil {
+			joined = &FQDNGovernance{}
+		}
+		joined.policies = append(joined.policies, governance.policies...)
+		joined.forbidCIDRs = joined.forbidCIDRs || governance.forbidCIDRs
+		joined.forbidCustomResolvers = joined.forbidCustomResolvers || governance.forbidCustomResolvers
+	}
+	return joined
+}
+
+// ForbidsCIDRs returns true if a policy forbids the CIDRs of the governed network policies. A nil FQDNGovernance
+// allows them.
+func (g *FQDNGovernance) ForbidsCIDRs() bool {
+	return g != nil && g.forbidCIDRs
+}
+
+// ForbidsCustomResolvers returns true if a policy forbids the resolvers of the governed network policies and
+// NetworkPolicyDefaults. A nil FQDNGovernance allows them.
+func (g *FQDNGovernance) ForbidsCustomResolvers() bool {
+	return g != nil && g.forbidCustomResolvers
+}
+
+// Govern removes the settings that the policies forbid to the governed network policy and returns their paths: the
+// resolver, which could answer the FQDNs with any address, and the CIDRs, which allow destinations without an FQDN.
+// A nil FQDNGovernance keeps them.
+func (g *FQDNGovernance) Govern(np *NetworkPolicy) []string {
+	var ignored []string
+	if g.ForbidsCustomResolvers() && np.Spec.Resolver != nil {
+		np.Spec.Resolver = nil
+		ignored = append(ignored, "spec.resolver")
+	}
+	if !g.ForbidsCIDRs() {
+		return ignored
+	}
+	for i := range np.Spec.Egresses {
+		if len(np.Spec.Egresses[i].ToCIDRs) > 0 {
+			np.Spec.Egresses[i].ToCIDRs = nil
+			ignored = append(ignored, fmt.Sprintf("spec.egress[%d].toCIDRs", i))
+		}
+	}
+	return ignored
+}
+
+// Violation returns why the FQDN is denied, or an empty string if it complies with all policies. A nil
+// FQDNGovernance allows all FQDNs.
+func (g *FQDNGovernance) Violation(fqdn FQDN) string {
+	if g == nil {
+		return ""
+	}
+	name := strings.TrimSuffix(string(fqdn), ".")
+	for _, policy := range g.policies {
+		if policy.err != nil {
+			return fmt.Sprintf("denied as FQDNGovernancePolicy %s has an %v", policy.name, policy.err)
+		}
+		for _, suffix := range policy.denied {
+			if fqdn.InDomain(suffix) {
+				return fmt.Sprintf("denied by suffix %s of FQDNGovernancePolicy %s", suffix, policy.name)
+			}
+		}
+		for _, regex := range policy.deniedRegexes {
+			if regex.MatchString(name) {
+				return fmt.Sprintf("denied by a regex of FQDNGovernancePolicy %s", policy.name)
+			}
+		}
+		if len(policy.allowed) == 0 && len(policy.allowedRegexes) == 0 {
+			continue
+		}
+		allowed := false
+		for _, suffix := range policy.allowed {
+			allowed = allowed || fqdn.InDomain(suffix)
+		}
+		for _, regex := range policy.allowedRegexes {
+			allowed = allowed || regex.MatchString(name)
+		}
+		if !allowed {
+			return fmt.Sprintf("not allowed by FQDNGovernancePolicy %s", policy.name)
+		}
+	}
+	return ""
+}
+
+// Filter returns the FQDNs complying with all policies and the violations of the denied FQDNs
+func (g *FQDNGovernance) Filter(fqdns []FQDN) ([]FQDN, map[FQDN]string) {
+	if g == nil {
+		return fqdns, nil
+	}
+	allowed := make([]FQDN, 0, len(fqdns))
+	var denied map[FQDN]string
+	for _, fqdn := range fqdns {
+		if violation := g.Violation(fqdn); violation != "" {
+			if denied == nil {
+				denied = map[FQDN]string{}
+			}
+			denied[fqdn] = violation
+			continue
+		}
+		allowed = append(allowed, fqdn)
+	}
+	return allowed, denied
+}
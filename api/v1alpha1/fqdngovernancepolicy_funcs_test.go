@@ -0,0 +1,241 @@
+package v1alpha1
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func governancePolicy(name string, spec FQDNGovernancePolicySpec) FQDNGovernancePolicy {
+	return FQDNGovernancePolicy{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+func TestFQDNGovernanceViolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []FQDNGovernancePolicy
+		fqdn     FQDN
+		// violation is a substring of the expected violation, empty if the FQDN is allowed
+		violation string
+	}{
+		{
+			name:     "no policies",
+			policies: nil,
+			fqdn:     "www.example.com",
+		},
+		{
+			name: "allowed suffix",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedSuffixes: []FQDN{"example.com"},
+			})},
+			fqdn: "WWW.Example.com.",
+		},
+		{
+			name: "suffix matches whole labels",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedSuffixes: []FQDN{"example.com"},
+			})},
+			fqdn:      "www.badexample.com",
+			violation: "not allowed by FQDNGovernancePolicy tenants",
+		},
+		{
+			name: "allowed regex",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedRegexes: []string{`[a-z]+\.example\.com`},
+			})},
+			fqdn: "API.example.com",
+		},
+		{
+			name: "regex matches the whole FQDN",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedRegexes: []string{`example\.com`},
+			})},
+			fqdn:      "www.example.com",
+			violation: "not allowed by FQDNGovernancePolicy tenants",
+		},
+		{
+			name: "denied suffix before allowed suffix",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedSuffixes: []FQDN{"example.com"},
+				DeniedSuffixes:  []FQDN{"admin.example.com"},
+			})},
+			fqdn:      "db.admin.example.com",
+			violation: "denied by suffix admin.example.com",
+		},
+		{
+			name: "denied regex before allowed regex",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedRegexes: []string{`.*\.example\.com`},
+				DeniedRegexes:  []string{`admin\..*`},
+			})},
+			fqdn:      "admin.example.com",
+			violation: "denied by a regex",
+		},
+		{
+			name: "deny list only",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				DeniedSuffixes: []FQDN{"example.org"},
+			})},
+			fqdn: "www.example.com",
+		},
+		{
+			name: "every policy must allow the FQDN",
+			policies: []FQDNGovernancePolicy{
+				governancePolicy("cluster", FQDNGovernancePolicySpec{AllowedSuffixes: []FQDN{"com"}}),
+				governancePolicy("tenants", FQDNGovernancePolicySpec{AllowedSuffixes: []FQDN{"example.com"}}),
+			},
+			fqdn:      "www.example.net.com",
+			violation: "not allowed by FQDNGovernancePolicy tenants",
+		},
+		{
+			name: "deny of one policy overrides the allow of another",
+			policies: []FQDNGovernancePolicy{
+				governancePolicy("tenants", FQDNGovernancePolicySpec{AllowedSuffixes: []FQDN{"example.com"}}),
+				governancePolicy("security", FQDNGovernancePolicySpec{DeniedRegexes: []string{`.*\.internal\..*`}}),
+			},
+			fqdn:      "db.internal.example.com",
+			violation: "denied by a regex of FQDNGovernancePolicy security",
+		},
+		{
+			name: "invalid regex denies all FQDNs",
+			policies: []FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedSuffixes: []FQDN{"example.com"},
+				DeniedRegexes:   []string{"("},
+			})},
+			fqdn:      "www.example.com",
+			violation: "invalid regex",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violation := NewFQDNGovernance(tt.policies).Violation(tt.fqdn)
+			switch {
+			case tt.violation == "" && violation != "":
+				t.Errorf("expected %s to be allowed, got %q", tt.fqdn, violation)
+			case !strings.Contains(violation, tt.violation):
+				t.Errorf("expected a violation containing %q, got %q", tt.violation, violation)
+			}
+		})
+	}
+}
+
+func TestFQDNGovernanceFilter(t *testing.T) {
+	fqdns := []FQDN{"www.example.com", "admin.example.com", "www.example.org"}
+	tests := []struct {
+		name       string
+		governance *FQDNGovernance
+		allowed    []FQDN
+		denied     []FQDN
+	}{
+		{
+			name:    "nil governance allows all FQDNs",
+			allowed: fqdns,
+		},
+		{
+			name: "denied FQDNs are dropped",
+			governance: NewFQDNGovernance([]FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+				AllowedSuffixes: []FQDN{"example.com"},
+				DeniedRegexes:   []string{`admin\..*`},
+			})}),
+			allowed: []FQDN{"www.example.com"},
+			denied:  []FQDN{"admin.example.com", "www.example.org"},
+		},
+		{
+			name: "joined governances apply all policies",
+			governance: JoinFQDNGovernance(
+				NewFQDNGovernance([]FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{
+					DeniedSuffixes: []FQDN{"example.org"},
+				})}),
+				nil,
+				NewFQDNGovernance([]FQDNGovernancePolicy{governancePolicy("security", FQDNGovernancePolicySpec{
+					DeniedSuffixes: []FQDN{"admin.example.com"},
+				})}),
+			),
+			allowed: []FQDN{"www.example.com"},
+			denied:  []FQDN{"admin.example.com", "www.example.org"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, denied := tt.governance.Filter(fqdns)
+			if !reflect.DeepEqual(allowed, tt.allowed) {
+				t.Errorf("expected allowed %v, got %v", tt.allowed, allowed)
+			}
+			if names := slices.Sorted(maps.Keys(denied)); !slices.Equal(names, tt.denied) {
+				t.Errorf("expected denied %v, got %v", tt.denied, names)
+			}
+			for fqdn, violation := range denied {
+				if violation == "" {
+					t.Errorf("expected a violation for %s", fqdn)
+				}
+			}
+		})
+	}
+}
+
+func TestFQDNGovernanceGovern(t *testing.T) {
+	np := &NetworkPolicy{Spec: NetworkPolicySpec{
+		Resolver: &ResolverConfig{Nameservers: []string{"192.0.2.53"}},
+		Egresses: []EgressRule{
+			{ToFQDNs: []FQDN{"www.example.com"}},
+			{ToFQDNs: []FQDN{"api.example.com"}, ToCIDRs: []CIDRPeer{{CIDR: "192.0.2.0/24"}}},
+		},
+	}}
+
+	var governance *FQDNGovernance
+	if ignored := governance.Govern(np); ignored != nil || np.Spec.Resolver == nil {
+		t.Fatalf("expected a nil governance to keep the spec, ignored %v", ignored)
+	}
+
+	governance = NewFQDNGovernance([]FQDNGovernancePolicy{governancePolicy("tenants", FQDNGovernancePolicySpec{})})
+	if ignored := governance.Govern(np); ignored != nil || np.Spec.Resolver == nil {
+		t.Fatalf("expected a governance without opt-ins to keep the spec, ignored %v", ignored)
+	}
+
+	governance = NewFQDNGovernance([]FQDNGovernancePolicy{
+		governancePolicy("tenants", FQDNGovernancePolicySpec{}),
+		governancePolicy("strict", FQDNGovernancePolicySpec{ForbidCIDRs: true, ForbidCustomResolvers: true}),
+	})
+	ignored := governance.Govern(np)
+	if expected := []string{"spec.resolver", "spec.egress[1].toCIDRs"}; !slices.Equal(ignored, expected) {
+		t.Errorf("expected ignored %v, got %v", expected, ignored)
+	}
+	if np.Spec.Resolver != nil || np.Spec.Egresses[1].ToCIDRs != nil {
+		t.Errorf("expected the resolver and the CIDRs to be removed, got %+v", np.Spec)
+	}
+	if len(np.Spec.Egresses[1].ToFQDNs) != 1 {
+		t.Errorf("expected the FQDNs to be kept, got %v", np.Spec.Egresses[1].ToFQDNs)
+	}
+}
+
+func TestFQDNGovernanceGovernCIDRsOnly(t *testing.T) {
+	newPolicy := func() *NetworkPolicy {
+		return &NetworkPolicy{Spec: NetworkPolicySpec{
+			Egresses: []EgressRule{{ToCIDRs: []CIDRPeer{{CIDR: "192.0.2.0/24"}}}},
+		}}
+	}
+
+	np := newPolicy()
+	governance := NewFQDNGovernance([]FQDNGovernancePolicy{
+		governancePolicy("resolvers", FQDNGovernancePolicySpec{ForbidCustomResolvers: true}),
+	})
+	if ignored := governance.Govern(np); ignored != nil || len(np.Spec.Egresses[0].ToCIDRs) != 1 {
+		t.Errorf("expected the CIDRs to be kept unless forbidden, got %v, ignored %v", np.Spec.Egresses, ignored)
+	}
+
+	np = newPolicy()
+	governance = JoinFQDNGovernance(governance, NewFQDNGovernance([]FQDNGovernancePolicy{
+		governancePolicy("cidrs", FQDNGovernancePolicySpec{ForbidCIDRs: true}),
+	}))
+	ignored := governance.Govern(np)
+	if expected := []string{"spec.egress[0].toCIDRs"}; !slices.Equal(ignored, expected) {
+		t.Errorf("expected ignored %v, got %v", expected, ignored)
+	}
+	if np.Spec.Egresses[0].ToCIDRs != nil {
+		t.Errorf("expected the CIDRs to be removed, got %v", np.Spec.Egresses[0].ToCIDRs)
+	}
+}
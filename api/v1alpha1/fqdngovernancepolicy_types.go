@@ -0,0 +1,115 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FQDNGovernancePolicySpec defines the domains that the FQDN network policies of the selected namespaces may use.
+// An FQDN is denied if it matches a denied suffix or regex. If allowed suffixes or regexes are specified, an FQDN is
+// also denied unless it matches one of them. The FQDNs must comply with every FQDNGovernancePolicy selecting the
+// namespace. CIDRs and custom resolvers are only forbidden if a policy selecting the namespace opts in.
+type FQDNGovernancePolicySpec struct {
+	// NamespaceSelector selects the namespaces whose network policies are governed by this policy.
+	//
+	//  - An empty selector selects all namespaces
+	//
+	// +kubebuilder:validation:Optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// AllowedSuffixes are the domains whose FQDNs, including the domain itself, may be used.
+	//
+	//  - Maximum items is 100
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=100
+	// +listType=set
+	AllowedSuffixes []FQDN `json:"allowedSuffixes,omitempty"`
+
+	// AllowedRegexes are the regular expressions of the FQDNs that may be used. A regex must match the whole FQDN.
+	//
+	//  - Maximum items is 50
+	//  - Maximum length of each regex is 256 characters
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=50
+	// +kubebuilder:validation:items:MaxLength=256
+	// +listType=set
+	AllowedRegexes []string `json:"allowedRegexes,omitempty"`
+
+	// DeniedSuffixes are the domains whose FQDNs, including the domain itself, may not be used.
+	//
+	//  - Maximum items is 100
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=100
+	// +listType=set
+	DeniedSuffixes []FQDN `json:"deniedSuffixes,omitempty"`
+
+	// DeniedRegexes are the regular expressions of the FQDNs that may not be used. A regex must match the whole FQDN.
+	//
+	//  - Maximum items is 50
+	//  - Maximum length of each regex is 256 characters
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=50
+	// +kubebuilder:validation:items:MaxLength=256
+	// +listType=set
+	DeniedRegexes []string `json:"deniedRegexes,omitempty"`
+
+	// ForbidCIDRs forbids the toCIDRs of the governed network policies, which allow destinations without an FQDN.
+	//
+	//  - Defaults to false, the CIDRs are kept
+	//
+	// +kubebuilder:validation:Optional
+	ForbidCIDRs bool `json:"forbidCIDRs,omitempty"`
+
+	// ForbidCustomResolvers forbids the governed network policies and the NetworkPolicyDefaults of their namespaces to
+	// set a resolver, which could answer the governed FQDNs with any address.
+	//
+	//  - Defaults to false, the resolvers are kept
+	//
+	// +kubebuilder:validation:Optional
+	ForbidCustomResolvers bool `json:"forbidCustomResolvers,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FQDNGovernancePolicy is the Schema for the fqdngovernancepolicies API. It restricts the FQDNs that the network policies of the selected namespaces may use. Network policies breaking it are rejected, and the FQDNs breaking it are not resolved. The governed network policies may not use CIDRs or their own resolver if forbidCIDRs or forbidCustomResolvers is set.
+//
+// +kubebuilder:resource:path=fqdngovernancepolicies,singular=fqdngovernancepolicy,scope=Cluster,shortName={fgp}
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type FQDNGovernancePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FQDNGovernancePolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FQDNGovernancePolicyList contains a list of FQDNGovernancePolicy.
+type FQDNGovernancePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FQDNGovernancePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FQDNGovernancePolicy{}, &FQDNGovernancePolicyList{})
+}
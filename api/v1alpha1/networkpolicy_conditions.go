@@ -22,6 +22,26 @@ func (np *NetworkPolicy) SetResolveCondition(reason NetworkPolicyResolvedConditi
 	})
 }
 
+// SetNetworkAttachedCondition updates the NetworkAttached condition, which is True if the reason is NetworkPolicyNADFound
+func (np *NetworkPolicy) SetNetworkAttachedCondition(reason NetworkPolicyNetworkAttachedConditionReason, message string) {
+	condition := metav1.ConditionFalse
+	if reason == NetworkPolicyNADFound {
+		condition = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&np.Status.Conditions, metav1.Condition{
+		Type:               string(NetworkPolicyNetworkAttachedCondition),
+		Status:             condition,
+		Reason:             string(reason),
+		Message:            message,
+		ObservedGeneration: np.GetGeneration(),
+	})
+}
+
+// RemoveNetworkAttachedCondition removes the NetworkAttached condition, e.g. when the output kind has no target network
+func (np *NetworkPolicy) RemoveNetworkAttachedCondition() {
+	meta.RemoveStatusCondition(&np.Status.Conditions, string(NetworkPolicyNetworkAttachedCondition))
+}
+
 // SetReadyConditionTrue sets the Ready condition to True with a standard success message.
 // Updates the ObservedGeneration to reflect the current spec generation.
 func (np *NetworkPolicy) SetReadyConditionTrue(reason NetworkPolicyReadyConditionReason, message string) {
@@ -45,3 +65,24 @@ func (np *NetworkPolicy) SetReadyConditionFalse(reason NetworkPolicyReadyConditi
 		ObservedGeneration: np.GetGeneration(),
 	})
 }
+
+// SetGovernanceCondition updates the Governance condition, which is True if the reason is
+// NetworkPolicyGovernanceCompliant
+func (np *NetworkPolicy) SetGovernanceCondition(reason NetworkPolicyGovernanceConditionReason, message string) {
+	condition := metav1.ConditionFalse
+	if reason == NetworkPolicyGovernanceCompliant {
+		condition = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&np.Status.Conditions, metav1.Condition{
+		Type:               string(NetworkPolicyGovernanceCondition),
+		Status:             condition,
+		Reason:             string(reason),
+		Message:            message,
+		ObservedGeneration: np.GetGeneration(),
+	})
+}
+
+// RemoveGovernanceCondition removes the Governance condition, e.g. when no FQDNGovernancePolicy selects the namespace
+func (np *NetworkPolicy) RemoveGovernanceCondition() {
+	meta.RemoveStatusCondition(&np.Status.Conditions, string(NetworkPolicyGovernanceCondition))
+}
@@ -1,15 +1,20 @@
 package v1alpha1
 
 import (
+	"cmp"
 	"fmt"
 	"net"
+	"net/netip"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -75,6 +80,97 @@ func (f *FQDN) Valid() bool {
 	return true
 }
 
+// Normalize returns the FQDN in lower case without a trailing dot
+func (f FQDN) Normalize() FQDN {
+	return FQDN(strings.ToLower(strings.TrimSuffix(string(f), ".")))
+}
+
+// InDomain returns true if the FQDN is the domain or one of its subdomains. The comparison is case-insensitive and
+// ignores trailing dots.
+func (f FQDN) InDomain(domain FQDN) bool {
+	name := strings.ToLower(strings.TrimSuffix(string(f), "."))
+	suffix := strings.ToLower(strings.TrimSuffix(string(domain), "."))
+	return name == suffix || strings.HasSuffix(name, "."+suffix)
+}
+
+// base returns the domain following the wildcard label and whether the wildcard matches multiple labels
+func (p FQDNPattern) base() (FQDN, bool) {
+	if rest, ok := strings.CutPrefix(string(p), "**."); ok {
+		return FQDN(rest), true
+	}
+	return FQDN(strings.TrimPrefix(string(p), "*.")), false
+}
+
+// Base returns the domain following the wildcard label, the FQDNs matched by the pattern are its subdomains
+func (p FQDNPattern) Base() FQDN {
+	base, _ := p.base()
+	return base
+}
+
+// Valid returns true if the pattern is a valid wildcard pattern
+func (p FQDNPattern) Valid() bool {
+	if !strings.HasPrefix(string(p), "*.") && !strings.HasPrefix(string(p), "**.") {
+		return false
+	}
+	base, _ := p.base()
+	return base.Valid()
+}
+
+// Matches returns true if the FQDN is matched by the wildcard pattern. The comparison is case-insensitive and ignores
+// a trailing dot on the FQDN.
+func (p FQDNPattern) Matches(fqdn FQDN) bool {
+	base, multiLabel := p.base()
+	name := strings.ToLower(strings.TrimSuffix(string(fqdn), "."))
+	prefix, ok := strings.CutSuffix(name, "."+strings.ToLower(string(base)))
+	if !ok || prefix == "" {
+		return false
+	}
+	return multiLabel || !strings.Contains(prefix, ".")
+}
+
+// Valid returns true if the SRV name consists of a service label, a supported protocol label and a valid FQDN
+func (n SRVName) Valid() bool {
+	serviceRegexp := regexp.MustCompile(`^_[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+	parts := strings.SplitN(string(n), ".", 3)
+	if len(parts) != 3 || !serviceRegexp.MatchString(parts[0]) || n.Protocol() == "" {
+		return false
+	}
+	domain := n.Domain()
+	return domain.Valid()
+}
+
+// Domain returns the domain following the service and protocol labels of the SRV name
+func (n SRVName) Domain() FQDN {
+	parts := strings.SplitN(string(n), ".", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return FQDN(parts[2])
+}
+
+// Protocol returns the protocol given by the protocol label of the SRV name, or an empty protocol if the label is not
+// supported
+func (n SRVName) Protocol() corev1.Protocol {
+	parts := strings.SplitN(string(n), ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	switch strings.ToLower(parts[1]) {
+	case "_tcp":
+		return corev1.ProtocolTCP
+	case "_udp":
+		return corev1.ProtocolUDP
+	case "_sctp":
+		return corev1.ProtocolSCTP
+	}
+	return ""
+}
+
+// Compare orders SRV targets by target and port
+func (t SRVTarget) Compare(other SRVTarget) int {
+	return cmp.Or(strings.Compare(string(t.Target), string(other.Target)), cmp.Compare(t.Port, other.Port))
+}
+
 func isAllowed(cidrString string, globalBlock bool, ruleBlock *bool) bool {
 	blockPrivateIP := globalBlock
 	if ruleBlock != nil {
@@ -90,6 +186,115 @@ func isAllowed(cidrString string, globalBlock bool, ruleBlock *bool) bool {
 	return true
 }
 
+// privateNetworks are the ranges reported as private by net.IP.IsPrivate
+var privateNetworks = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// containsPrefix returns true if inner is within outer
+func containsPrefix(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+// getIPBlocks converts the static CIDRs of a rule to IP blocks. When private IPs are blocked, CIDRs within a private
+// range are dropped and the private ranges within a CIDR are added to its except blocks.
+func getIPBlocks(cidrs []CIDRPeer, globalBlock bool, ruleBlock *bool) []mnetv1beta1.IPBlock {
+	blockPrivateIP := globalBlock
+	if ruleBlock != nil {
+		blockPrivateIP = *ruleBlock
+	}
+	var blocks []mnetv1beta1.IPBlock
+	for _, peer := range cidrs {
+		prefix, err := netip.ParsePrefix(peer.CIDR)
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Masked()
+		var except []netip.Prefix
+		for _, e := range peer.Except {
+			if p, err := netip.ParsePrefix(e); err == nil {
+				except = append(except, p.Masked())
+			}
+		}
+		if blockPrivateIP {
+			if slices.ContainsFunc(privateNetworks, func(private netip.Prefix) bool {
+				return containsPrefix(private, prefix)
+			}) {
+				continue
+			}
+			for _, private := range privateNetworks {
+				if containsPrefix(prefix, private) {
+					except = append(except, private)
+				}
+			}
+		}
+		block := mnetv1beta1.IPBlock{CIDR: prefix.String(), Except: compactPrefixes(except)}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// compactPrefixes returns the sorted prefixes without the prefixes within another one
+func compactPrefixes(prefixes []netip.Prefix) []string {
+	var result []string
+	for i, prefix := range prefixes {
+		covered := slices.ContainsFunc(prefixes, func(other netip.Prefix) bool {
+			return other != prefix && containsPrefix(other, prefix)
+		})
+		// Of equal prefixes only the first is kept
+		if covered || slices.Index(prefixes, prefix) != i {
+			continue
+		}
+		result = append(result, prefix.String())
+	}
+	slices.Sort(result)
+	return result
+}
+
+// ExcludeCIDRs returns the smallest set of CIDRs covering the range of cidr without the ranges in except, for
+// policy kinds that do not support except blocks. Returns nil if cidr is invalid or fully excluded.
+func ExcludeCIDRs(cidr string, except []string) []string {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil
+	}
+	var excluded []netip.Prefix
+	for _, e := range except {
+		if p, err := netip.ParsePrefix(e); err == nil {
+			excluded = append(excluded, p.Masked())
+		}
+	}
+	var result []string
+	var split func(netip.Prefix)
+	split = func(p netip.Prefix) {
+		overlapping := false
+		for _, e := range excluded {
+			if containsPrefix(e, p) {
+				return
+			}
+			if containsPrefix(p, e) {
+				overlapping = true
+			}
+		}
+		if !overlapping {
+			result = append(result, p.String())
+			return
+		}
+		// Both halves of the prefix share its address bits, the upper half has the next bit set
+		lower := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+		bytes := p.Addr().AsSlice()
+		bytes[p.Bits()/8] |= 0x80 >> (p.Bits() % 8)
+		upperAddr, _ := netip.AddrFromSlice(bytes)
+		split(lower)
+		split(netip.PrefixFrom(upperAddr, p.Bits()+1))
+	}
+	split(prefix.Masked())
+	return result
+}
+
 func sortPeersByCIDR(peers []mnetv1beta1.MultiNetworkPolicyPeer) {
 	sort.SliceStable(peers, func(i, j int) bool {
 		// Make sure both peers have IPBlocks
@@ -103,59 +308,197 @@ func sortPeersByCIDR(peers []mnetv1beta1.MultiNetworkPolicyPeer) {
 	})
 }
 
-func getPeers(fqdns []FQDN, ips map[FQDN]*FQDNStatus, globalBlock bool, ruleBlock *bool) []mnetv1beta1.MultiNetworkPolicyPeer {
-	var peers []mnetv1beta1.MultiNetworkPolicyPeer
-
+// matchingStatuses returns the statuses of the given FQDNs and of all FQDNs matching one of the patterns
+func matchingStatuses(fqdns []FQDN, patterns []FQDNPattern, ips map[FQDN]*FQDNStatus) []*FQDNStatus {
+	var statuses []*FQDNStatus
+	seen := make(map[FQDN]struct{})
 	for _, fqdn := range fqdns {
 		if status, ok := ips[fqdn]; ok {
-			for _, addr := range status.Addresses {
-				if isAllowed(addr, globalBlock, ruleBlock) {
-					peers = append(peers, mnetv1beta1.MultiNetworkPolicyPeer{IPBlock: &mnetv1beta1.IPBlock{
-						CIDR: addr,
-					}})
-				}
+			statuses = append(statuses, status)
+			seen[fqdn] = struct{}{}
+		}
+	}
+	if len(patterns) == 0 {
+		return statuses
+	}
+	for fqdn, status := range ips {
+		if _, ok := seen[fqdn]; ok {
+			continue
+		}
+		for _, pattern := range patterns {
+			if pattern.Matches(fqdn) {
+				statuses = append(statuses, status)
+				break
 			}
 		}
 	}
+	return statuses
+}
+
+func getPeers(
+	fqdns []FQDN, patterns []FQDNPattern, cidrs []CIDRPeer, ips map[FQDN]*FQDNStatus, globalBlock bool,
+	ruleBlock *bool,
+) []mnetv1beta1.MultiNetworkPolicyPeer {
+	var peers []mnetv1beta1.MultiNetworkPolicyPeer
+
+	for _, addr := range getAddresses(fqdns, patterns, ips, globalBlock, ruleBlock) {
+		peers = append(peers, mnetv1beta1.MultiNetworkPolicyPeer{IPBlock: &mnetv1beta1.IPBlock{
+			CIDR: addr,
+		}})
+	}
+	for _, block := range getIPBlocks(cidrs, globalBlock, ruleBlock) {
+		peers = append(peers, mnetv1beta1.MultiNetworkPolicyPeer{IPBlock: &block})
+	}
 	sortPeersByCIDR(peers)
 	return peers
 }
 
+func getAddresses(
+	fqdns []FQDN, patterns []FQDNPattern, ips map[FQDN]*FQDNStatus, globalBlock bool, ruleBlock *bool,
+) []string {
+	var addresses []string
+	for _, status := range matchingStatuses(fqdns, patterns, ips) {
+		for _, addr := range status.ResolvedAddresses() {
+			if isAllowed(addr, globalBlock, ruleBlock) {
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+	return addresses
+}
+
+// Addresses returns the sorted, unique addresses resolved for the FQDNs and patterns of the rule and the static CIDRs
+// of the rule that are allowed by the private IP blocking of the rule or, if unset, of the network policy. The except
+// blocks of the static CIDRs are cut out of them.
+func (r *EgressRule) Addresses(fqdnStatuses []FQDNStatus, blockPrivate bool) []string {
+	addresses := getAddresses(
+		r.ToFQDNs, r.ToFQDNPatterns, FQDNStatusList(fqdnStatuses).LookupTable(), blockPrivate, r.BlockPrivateIPs,
+	)
+	for _, block := range getIPBlocks(r.ToCIDRs, blockPrivate, r.BlockPrivateIPs) {
+		addresses = append(addresses, ExcludeCIDRs(block.CIDR, block.Except)...)
+	}
+	slices.Sort(addresses)
+	return slices.Compact(addresses)
+}
+
+// SRVEgressRules returns a rule for each target and port of the SRV names of the rule, allowing the addresses
+// resolved for the target that are allowed by the private IP blocking of the rule or, if unset, of the network policy.
+// The port is the port of the SRV record and the protocol is given by the SRV name. Targets without allowed addresses
+// are omitted.
+func (r *EgressRule) SRVEgressRules(
+	fqdnStatuses []FQDNStatus, srvStatuses []SRVStatus, blockPrivate bool,
+) []mnetv1beta1.MultiNetworkPolicyEgressRule {
+	return r.srvEgressRules(
+		FQDNStatusList(fqdnStatuses).LookupTable(), SRVStatusList(srvStatuses).LookupTable(), blockPrivate,
+	)
+}
+
+func (r *EgressRule) srvEgressRules(
+	ips map[FQDN]*FQDNStatus, srvs map[SRVName]*SRVStatus, blockPrivate bool,
+) []mnetv1beta1.MultiNetworkPolicyEgressRule {
+	var rules []mnetv1beta1.MultiNetworkPolicyEgressRule
+	for _, name := range r.ToSRV {
+		status, ok := srvs[name]
+		if !ok {
+			continue
+		}
+		for _, target := range status.Targets {
+			peers := getPeers([]FQDN{target.Target}, nil, nil, ips, blockPrivate, r.BlockPrivateIPs)
+			if len(peers) == 0 {
+				continue
+			}
+			rules = append(rules, mnetv1beta1.MultiNetworkPolicyEgressRule{
+				Ports: toMultiNetworkPolicyPorts([]MultiNetworkPolicyPort{
+					{Protocol: name.Protocol(), Port: target.Port},
+				}),
+				To: peers,
+			})
+		}
+	}
+	return rules
+}
+
 // toNetworkPolicyEgressRule converts the EgressRule to a netv1.NetworkPolicyEgressRule.
 // Returns nil if no peers were found.
 func (r *EgressRule) toMultiNetworkPolicyEgressRule(ips map[FQDN]*FQDNStatus, blockPrivate bool) *mnetv1beta1.MultiNetworkPolicyEgressRule {
-	peers := getPeers(r.ToFQDNs, ips, blockPrivate, r.BlockPrivateIPs)
+	peers := getPeers(r.ToFQDNs, r.ToFQDNPatterns, r.ToCIDRs, ips, blockPrivate, r.BlockPrivateIPs)
 	if len(peers) == 0 {
 		return nil
 	}
 
-	/*
-		external := []mnetv1beta1.MultiNetworkPolicyPort{}
-		for _, local := range r.Ports {
-			temp := intstr.Parse(local.Port)
-			p := mnetv1beta1.MultiNetworkPolicyPort{
-				Port:     &temp,
-				Protocol: local.Protocol,
-				EndPort:  nil,
-			}
-			external = append(external, p)
-		}*/
+	return &mnetv1beta1.MultiNetworkPolicyEgressRule{
+		Ports: toMultiNetworkPolicyPorts(r.Ports),
+		To:    peers,
+	}
+}
+
+// toMultiNetworkPolicyIngressRule converts the IngressRule to a MultiNetworkPolicyIngressRule.
+// Returns nil if no peers were found.
+func (r *IngressRule) toMultiNetworkPolicyIngressRule(
+	ips map[FQDN]*FQDNStatus, blockPrivate bool,
+) *mnetv1beta1.MultiNetworkPolicyIngressRule {
+	peers := getPeers(r.FromFQDNs, nil, nil, ips, blockPrivate, r.BlockPrivateIPs)
+	if len(peers) == 0 {
+		return nil
+	}
+
+	return &mnetv1beta1.MultiNetworkPolicyIngressRule{
+		Ports: toMultiNetworkPolicyPorts(r.Ports),
+		From:  peers,
+	}
+}
 
+func toMultiNetworkPolicyPorts(ports []MultiNetworkPolicyPort) []mnetv1beta1.MultiNetworkPolicyPort {
 	external := []mnetv1beta1.MultiNetworkPolicyPort{}
-	for _, local := range r.Ports {
+	for _, local := range ports {
 		temp := intstr.FromInt(int(local.Port))
 		p := mnetv1beta1.MultiNetworkPolicyPort{
 			Port:     &temp,
 			Protocol: &local.Protocol,
-			EndPort:  nil,
+			EndPort:  local.RangeEnd(),
 		}
 		external = append(external, p)
 	}
+	return external
+}
 
-	return &mnetv1beta1.MultiNetworkPolicyEgressRule{
-		Ports: external,
-		To:    peers,
+// Addresses returns the sorted, unique addresses resolved for the FQDNs of the rule that are allowed by the private
+// IP blocking of the rule or, if unset, of the network policy
+func (r *IngressRule) Addresses(fqdnStatuses []FQDNStatus, blockPrivate bool) []string {
+	addresses := getAddresses(
+		r.FromFQDNs, nil, FQDNStatusList(fqdnStatuses).LookupTable(), blockPrivate, r.BlockPrivateIPs,
+	)
+	slices.Sort(addresses)
+	return slices.Compact(addresses)
+}
+
+// RangeEnd returns the last port of the range starting at Port, or nil if the port is not a range
+func (p *MultiNetworkPolicyPort) RangeEnd() *int32 {
+	if p.EndPort == nil || *p.EndPort <= p.Port {
+		return nil
 	}
+	endPort := *p.EndPort
+	return &endPort
+}
+
+// TargetNetworkRefs returns the NetworkAttachmentDefinitions selected by TargetNetwork or TargetNetworks. Names without
+// namespace refer to the namespace of the network policy.
+func (np *NetworkPolicy) TargetNetworkRefs() []types.NamespacedName {
+	names := np.Spec.TargetNetworks
+	if np.Spec.TargetNetwork != "" {
+		names = append([]string{np.Spec.TargetNetwork}, names...)
+	}
+	refs := make([]types.NamespacedName, 0, len(names))
+	for _, name := range names {
+		ref := types.NamespacedName{Namespace: np.Namespace, Name: name}
+		if namespace, name, ok := strings.Cut(name, "/"); ok {
+			ref = types.NamespacedName{Namespace: namespace, Name: name}
+		}
+		if !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
 }
 
 // FQDNs Returns all unique FQDNs defined in the network policy
@@ -166,6 +509,11 @@ func (np *NetworkPolicy) FQDNs() []FQDN {
 			set[fqdn] = struct{}{}
 		}
 	}
+	for _, rule := range np.Spec.Ingresses {
+		for _, fqdn := range rule.FromFQDNs {
+			set[fqdn] = struct{}{}
+		}
+	}
 
 	fqdns := make([]FQDN, 0, len(set))
 	for fqdn := range set {
@@ -179,34 +527,173 @@ func (np *NetworkPolicy) FQDNs() []FQDN {
 	return fqdns
 }
 
+// SRVNames returns all unique SRV names defined in the network policy
+func (np *NetworkPolicy) SRVNames() []SRVName {
+	var names []SRVName
+	for _, rule := range np.Spec.Egresses {
+		names = append(names, rule.ToSRV...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// FQDNPatterns Returns all unique wildcard patterns defined in the network policy
+func (np *NetworkPolicy) FQDNPatterns() []FQDNPattern {
+	set := make(map[FQDNPattern]struct{})
+	for _, rule := range np.Spec.Egresses {
+		for _, pattern := range rule.ToFQDNPatterns {
+			set[pattern] = struct{}{}
+		}
+	}
+
+	patterns := make([]FQDNPattern, 0, len(set))
+	for pattern := range set {
+		patterns = append(patterns, pattern)
+	}
+
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i] < patterns[j]
+	})
+
+	return patterns
+}
+
+// ExpandFQDNPatterns returns the concrete FQDNs matching the wildcard patterns of the network policy, mapped to the
+// pattern that produced them.
+//   - Candidates are the KnownFQDNs of each rule and the names returned by discover for each pattern
+//   - FQDNs already listed in ToFQDNs are not included
+//   - At most limit distinct FQDNs are expanded per pattern across all rules, a limit <= 0 disables the limit. An FQDN
+//     matched by several patterns counts against the first one only.
+func (np *NetworkPolicy) ExpandFQDNPatterns(discover func(FQDNPattern) []FQDN, limit int) map[FQDN]FQDNPattern {
+	literal := make(map[FQDN]struct{})
+	for _, fqdn := range np.FQDNs() {
+		literal[fqdn] = struct{}{}
+	}
+
+	discovered := make(map[FQDNPattern][]FQDN)
+	for _, pattern := range np.FQDNPatterns() {
+		if discover != nil {
+			discovered[pattern] = discover(pattern)
+		}
+	}
+
+	expanded := make(map[FQDN]FQDNPattern)
+	counts := make(map[FQDNPattern]int)
+	for _, rule := range np.Spec.Egresses {
+		for _, pattern := range rule.ToFQDNPatterns {
+			candidates := make([]FQDN, 0, len(rule.KnownFQDNs)+len(discovered[pattern]))
+			for _, candidate := range append(slices.Clone(rule.KnownFQDNs), discovered[pattern]...) {
+				candidates = append(candidates, candidate.Normalize())
+			}
+			slices.Sort(candidates)
+
+			for _, fqdn := range slices.Compact(candidates) {
+				if limit > 0 && counts[pattern] >= limit {
+					break
+				}
+				if _, ok := literal[fqdn]; ok || !fqdn.Valid() || !pattern.Matches(fqdn) {
+					continue
+				}
+				if _, ok := expanded[fqdn]; ok {
+					continue
+				}
+				expanded[fqdn] = pattern
+				counts[pattern]++
+			}
+		}
+	}
+	return expanded
+}
+
 // ToNetworkPolicy converts the NetworkPolicy to a netv1.NetworkPolicy.
-// If no Egress rules are specified, nil is returned.
-func (np *NetworkPolicy) ToMultiNetworkPolicy(fqdnStatuses []FQDNStatus) *mnetv1beta1.MultiNetworkPolicy {
-	if len(np.Spec.Egresses) == 0 {
+// If neither Egress nor Ingress rules are specified, nil is returned. The policy types only include the directions
+// with rules, so traffic in the other direction is not restricted. Each target and port of the SRV names of an Egress
+// rule is rendered as its own rule.
+func (np *NetworkPolicy) ToMultiNetworkPolicy(
+	fqdnStatuses []FQDNStatus, srvStatuses []SRVStatus,
+) *mnetv1beta1.MultiNetworkPolicy {
+	if len(np.Spec.Egresses) == 0 && len(np.Spec.Ingresses) == 0 {
 		return nil
 	}
 
 	lookup := FQDNStatusList(fqdnStatuses).LookupTable()
+	srvLookup := SRVStatusList(srvStatuses).LookupTable()
+	var policyTypes []mnetv1beta1.MultiPolicyType
 	var egress []mnetv1beta1.MultiNetworkPolicyEgressRule
 	for _, fqdnRule := range np.Spec.Egresses {
-		if rule := fqdnRule.toMultiNetworkPolicyEgressRule(lookup, np.Spec.BlockPrivateIPs); rule != nil {
+		if rule := fqdnRule.toMultiNetworkPolicyEgressRule(lookup, np.BlockPrivateIPs()); rule != nil {
 			egress = append(egress, *rule)
 		}
+		egress = append(egress, fqdnRule.srvEgressRules(lookup, srvLookup, np.BlockPrivateIPs())...)
 	}
-
-	// 由于OpenShift控制台界面显示并传入的只有EndPort的值，因此这里将EndPort的值赋予给Port，希望在下面生成MultiNetworkPolicy的时候能将Port的值带入
-	// EndPort的本义是结束的端口号，是相对于Port而言的，比如Port为8080，EndPort为8090，则端口8080~8090均满足条件
-	// 当EndPort和Port为相同值的时候，范围就收窄为一个端口
-	// 由于OpenShift控制台界面的Bug，只显示EndPort的输入并回传，不显示Port的输入也没办法回传，因此在创建MultiNetworkPolicy的前一刻，强制将EndPort的值赋予Port，迂回的解决问题
-	/*for i, rule := range egress {
-		for j := range rule.Ports {
-			if egress[i].Ports[j].EndPort != nil {
-				temp := intstr.FromInt(int(*egress[i].Ports[j].EndPort))
-				egress[i].Ports[j].Port = &temp
-			}
+	if len(np.Spec.Egresses) > 0 {
+		policyTypes = append(policyTypes, mnetv1beta1.PolicyTypeEgress)
+	}
+	var ingress []mnetv1beta1.MultiNetworkPolicyIngressRule
+	for _, fqdnRule := range np.Spec.Ingresses {
+		if rule := fqdnRule.toMultiNetworkPolicyIngressRule(lookup, np.BlockPrivateIPs()); rule != nil {
+			ingress = append(ingress, *rule)
 		}
-	}*/
+	}
+	if len(np.Spec.Ingresses) > 0 {
+		policyTypes = append(policyTypes, mnetv1beta1.PolicyTypeIngress)
+	}
+
+	return &mnetv1beta1.MultiNetworkPolicy{
+		ObjectMeta: np.ObjectMeta,
+		Spec: mnetv1beta1.MultiNetworkPolicySpec{
+			PodSelector: np.PodSelector(),
+			Egress:      egress,
+			Ingress:     ingress,
+			PolicyTypes: policyTypes,
+		},
+	}
+}
+
+// BlockPrivateIPs returns whether private IPs are omitted from the rules of the network policy
+func (np *NetworkPolicy) BlockPrivateIPs() bool {
+	return np.Spec.BlockPrivateIPs != nil && *np.Spec.BlockPrivateIPs
+}
+
+// ApplyDefaults sets the resolve settings the network policy does not specify from the defaults of its namespace, and
+// from the built-in defaults if the namespace has no defaults for them. defaults may be nil.
+func (np *NetworkPolicy) ApplyDefaults(defaults *NetworkPolicyDefaultsSpec) {
+	if defaults == nil {
+		defaults = &NetworkPolicyDefaultsSpec{}
+	}
+	spec := &np.Spec
+	if spec.EnabledNetworkType == "" {
+		spec.EnabledNetworkType = cmp.Or(defaults.EnabledNetworkType, DefaultEnabledNetworkType)
+	}
+	if spec.RetryTimeoutSeconds == 0 {
+		spec.RetryTimeoutSeconds = cmp.Or(defaults.RetryTimeoutSeconds, DefaultRetryTimeoutSeconds)
+	}
+	if spec.TTLSeconds == 0 {
+		spec.TTLSeconds = cmp.Or(defaults.TTLSeconds, DefaultTTLSeconds)
+	}
+	if spec.BlockPrivateIPs == nil && defaults.BlockPrivateIPs != nil {
+		blockPrivateIPs := *defaults.BlockPrivateIPs
+		spec.BlockPrivateIPs = &blockPrivateIPs
+	}
+	if spec.Resolver == nil && defaults.Resolver != nil {
+		spec.Resolver = defaults.Resolver.DeepCopy()
+	}
+}
 
+// OutputKindOrDefault returns the output kind of the network policy, or defaultKind if the network policy does not
+// specify one. Falls back to MultiNetworkPolicy if defaultKind is empty.
+func (np *NetworkPolicy) OutputKindOrDefault(defaultKind OutputKind) OutputKind {
+	switch {
+	case np.Spec.OutputKind != "":
+		return np.Spec.OutputKind
+	case defaultKind != "":
+		return defaultKind
+	}
+	return OutputKindMultiNetworkPolicy
+}
+
+// PodSelector returns the label selector of the pods the network policy applies to
+func (np *NetworkPolicy) PodSelector() metav1.LabelSelector {
 	selectorMap := make(map[string]string, len(np.Spec.MatchLabels))
 
 	for _, item := range np.Spec.MatchLabels {
@@ -215,38 +702,58 @@ func (np *NetworkPolicy) ToMultiNetworkPolicy(fqdnStatuses []FQDNStatus) *mnetv1
 
 	external := make([]metav1.LabelSelectorRequirement, len(np.Spec.MatchExpressions))
 	for i, item := range np.Spec.MatchExpressions {
-		external[i].Key = item.Key
+		external[i].Key = string(item.Key)
 		external[i].Operator = item.Operator
 		//external[i].Values = item.Values
 
+		// Exists and DoesNotExist do not take values
+		if len(item.Values) == 0 {
+			continue
+		}
 		external[i].Values = make([]string, len(item.Values))
 		for j, v := range item.Values {
 			external[i].Values[j] = string(v)
 		}
 	}
 
-	return &mnetv1beta1.MultiNetworkPolicy{
-		ObjectMeta: np.ObjectMeta,
-		Spec: mnetv1beta1.MultiNetworkPolicySpec{
-			PodSelector: metav1.LabelSelector{
-				MatchLabels:      selectorMap,
-				MatchExpressions: external,
-			},
-			Egress:      egress,
-			PolicyTypes: []mnetv1beta1.MultiPolicyType{mnetv1beta1.PolicyTypeEgress},
-		},
+	return metav1.LabelSelector{
+		MatchLabels:      selectorMap,
+		MatchExpressions: external,
 	}
 }
 
-// Update updates the status of the FQDN.
+// Window returns how long an address is kept after the last lookup returning it. Zero means the addresses are
+// replaced by the addresses of the latest lookup.
+func (r *AddressRetention) Window() time.Duration {
+	if r == nil || r.Mode != AddressRetentionSticky {
+		return 0
+	}
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// Update updates the status of the FQDN. The addresses of a successful lookup are merged with the addresses seen
+// within retentionWindow, a zero retentionWindow replaces the addresses. cnames is the CNAME chain followed by the
+// lookup and is recorded on the FQDN.
 // If addresses were cleared due to an error during the update, the method returns true.
 func (f *FQDNStatus) Update(
-	cidrs []*CIDR, reason NetworkPolicyResolvedConditionReason, message string, retryTimeoutSeconds int,
+	cidrs []*CIDR, cnames []string, reason NetworkPolicyResolvedConditionReason, message string,
+	retryTimeoutSeconds int, retentionWindow time.Duration,
 ) bool {
 	cleared := false
 	if reason == NetworkPolicyResolveSuccess {
+		previous := f.AddressRecords
+		if len(previous) == 0 {
+			// Statuses written before the address records only have the addresses, last seen at the last success
+			for _, address := range f.Addresses {
+				previous = append(previous, AddressRecord{
+					Address: address, FirstSeen: f.LastSuccessfulTime, LastSeen: f.LastSuccessfulTime, Source: f.FQDN,
+				})
+			}
+		}
 		f.LastSuccessfulTime = metav1.Now()
-		f.Addresses = CIDRList(cidrs).String()
+		f.setAddressRecords(mergeAddressRecords(
+			previous, CIDRList(cidrs).String(), f.LastSuccessfulTime, retentionWindow, f.FQDN, cnames,
+		))
 	}
 	// On transient errors we want to adhere to the retry timeout specification
 	if reason != NetworkPolicyResolveSuccess && reason.Transient() {
@@ -255,15 +762,19 @@ func (f *FQDNStatus) Update(
 		)
 
 		if retryLimitReached {
-			f.Addresses = []string{}
+			f.setAddressRecords(nil)
 			cleared = true
 		}
 	}
 	// On non-transient errors we clear the addresses immediately
 	if reason != NetworkPolicyResolveSuccess && !reason.Transient() {
-		f.Addresses = []string{}
+		f.setAddressRecords(nil)
 		cleared = true
 	}
+	// Transient errors keep the chain leading to the addresses that are still applied
+	if reason == NetworkPolicyResolveSuccess || !reason.Transient() {
+		f.CNAMEs = cnames
+	}
 	if f.ResolveReason != reason {
 		f.LastTransitionTime = metav1.Now()
 	}
@@ -272,20 +783,146 @@ func (f *FQDNStatus) Update(
 	return cleared
 }
 
-func NewFQDNStatus(fqdn FQDN, cidrs []*CIDR, reason NetworkPolicyResolvedConditionReason, message string) FQDNStatus {
+// setAddressRecords replaces the address records and the addresses of the FQDN
+func (f *FQDNStatus) setAddressRecords(records []AddressRecord) {
+	f.AddressRecords = records
+	f.Addresses = nil
+	for _, record := range records {
+		f.Addresses = append(f.Addresses, record.Address)
+	}
+}
+
+// ResolvedAddresses returns the resolved addresses of the FQDN in CIDR notation. Statuses written before the address
+// records only have the addresses.
+func (f *FQDNStatus) ResolvedAddresses() []string {
+	if len(f.AddressRecords) == 0 {
+		return f.Addresses
+	}
+	addresses := make([]string, 0, len(f.AddressRecords))
+	for _, record := range f.AddressRecords {
+		addresses = append(addresses, record.Address)
+	}
+	return addresses
+}
+
+// maxRetainedAddressRecords is the maximum number of records kept within the retention window besides the addresses
+// of the latest lookup, so an FQDN rotating its addresses does not grow the status without bound
+const maxRetainedAddressRecords = 100
+
+// mergeAddressRecords marks the addresses as seen at the given time and keeps the previous records seen within the
+// retention window, at most maxRetainedAddressRecords of them, the most recently seen first. Addresses already
+// recorded keep the time they were first seen, the others are recorded as first seen now. The source of the addresses
+// is the last name of the CNAME chain, or the FQDN if the chain is empty. The records are sorted by address.
+func mergeAddressRecords(
+	previous []AddressRecord, addresses []string, seen metav1.Time, retentionWindow time.Duration,
+	fqdn FQDN, cnames []string,
+) []AddressRecord {
+	source := fqdn
+	if len(cnames) > 0 {
+		source = FQDN(cnames[len(cnames)-1])
+	}
+	records := make([]AddressRecord, 0, len(addresses))
+	for _, address := range addresses {
+		firstSeen := seen
+		i := slices.IndexFunc(previous, func(r AddressRecord) bool { return r.Address == address })
+		if i >= 0 && !previous[i].FirstSeen.IsZero() {
+			firstSeen = previous[i].FirstSeen
+		}
+		records = append(records, AddressRecord{
+			Address: address, FirstSeen: firstSeen, LastSeen: seen, Source: source,
+		})
+	}
+	var retained []AddressRecord
+	for _, record := range previous {
+		if slices.Contains(addresses, record.Address) || seen.Sub(record.LastSeen.Time) >= retentionWindow {
+			continue
+		}
+		retained = append(retained, record)
+	}
+	if len(retained) > maxRetainedAddressRecords {
+		slices.SortStableFunc(retained, func(a, b AddressRecord) int {
+			return b.LastSeen.Compare(a.LastSeen.Time)
+		})
+		retained = retained[:maxRetainedAddressRecords]
+	}
+	records = append(records, retained...)
+	slices.SortFunc(records, func(a, b AddressRecord) int {
+		return strings.Compare(a.Address, b.Address)
+	})
+	return records
+}
+
+func NewFQDNStatus(
+	fqdn FQDN, cidrs []*CIDR, cnames []string, reason NetworkPolicyResolvedConditionReason, message string,
+) FQDNStatus {
 	timeNow := metav1.Now()
-	return FQDNStatus{
+	status := FQDNStatus{
 		FQDN:               fqdn,
 		LastSuccessfulTime: timeNow,
 		LastTransitionTime: timeNow,
 		ResolveReason:      reason,
 		ResolveMessage:     message,
-		Addresses:          CIDRList(cidrs).String(),
+		CNAMEs:             cnames,
 	}
+	status.setAddressRecords(mergeAddressRecords(nil, CIDRList(cidrs).String(), timeNow, 0, fqdn, cnames))
+	return status
+}
+
+// Due returns true if the FQDN has to be resolved again at the given time
+func (f *FQDNStatus) Due(now time.Time) bool {
+	return f.NextRefreshTime.IsZero() || !now.Before(f.NextRefreshTime.Time)
 }
 
 type FQDNStatusList []FQDNStatus
 
+// NextRefreshTime returns the earliest time at which one of the FQDNs has to be resolved again.
+// Returns false if the list does not contain any scheduled refresh.
+func (s FQDNStatusList) NextRefreshTime() (time.Time, bool) {
+	var next time.Time
+	for _, status := range s {
+		if status.NextRefreshTime.IsZero() {
+			continue
+		}
+		if next.IsZero() || status.NextRefreshTime.Time.Before(next) {
+			next = status.NextRefreshTime.Time
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// AddressCount returns the number of addresses of all FQDNs before filtering
+func (s FQDNStatusList) AddressCount() int {
+	count := 0
+	for _, status := range s {
+		count += len(status.ResolvedAddresses())
+	}
+	return count
+}
+
+// AggregatedResolveStatus returns the reason with the highest priority in the list
+func (s FQDNStatusList) AggregatedResolveStatus() NetworkPolicyResolvedConditionReason {
+	reason := NetworkPolicyResolveSuccess
+	for _, status := range s {
+		if status.ResolveReason.Priority() > reason.Priority() {
+			reason = status.ResolveReason
+		}
+	}
+	return reason
+}
+
+// AggregatedResolveMessage returns the message of the reason with the highest priority in the list
+func (s FQDNStatusList) AggregatedResolveMessage() string {
+	reason := NetworkPolicyResolveSuccess
+	message := ""
+	for _, status := range s {
+		if message == "" || status.ResolveReason.Priority() > reason.Priority() {
+			reason = status.ResolveReason
+			message = status.ResolveMessage
+		}
+	}
+	return message
+}
+
 func (s FQDNStatusList) LookupTable() map[FQDN]*FQDNStatus {
 	lookupTable := make(map[FQDN]*FQDNStatus)
 	for _, status := range s {
@@ -293,3 +930,113 @@ func (s FQDNStatusList) LookupTable() map[FQDN]*FQDNStatus {
 	}
 	return lookupTable
 }
+
+// Update updates the status of the SRV name. The targets of a successful lookup replace the previous targets.
+// If targets were cleared due to an error during the update, the method returns true.
+func (s *SRVStatus) Update(
+	targets []SRVTarget, reason NetworkPolicyResolvedConditionReason, message string, retryTimeoutSeconds int,
+) bool {
+	cleared := false
+	if reason == NetworkPolicyResolveSuccess {
+		s.LastSuccessfulTime = metav1.Now()
+		s.Targets = targets
+	}
+	// On transient errors we want to adhere to the retry timeout specification
+	if reason != NetworkPolicyResolveSuccess && reason.Transient() {
+		if time.Now().After(s.LastSuccessfulTime.Add(time.Duration(retryTimeoutSeconds) * time.Second)) {
+			s.Targets = nil
+			cleared = true
+		}
+	}
+	// On non-transient errors we clear the targets immediately
+	if reason != NetworkPolicyResolveSuccess && !reason.Transient() {
+		s.Targets = nil
+		cleared = true
+	}
+	if s.ResolveReason != reason {
+		s.LastTransitionTime = metav1.Now()
+	}
+	s.ResolveReason = reason
+	s.ResolveMessage = message
+	return cleared
+}
+
+func NewSRVStatus(
+	name SRVName, targets []SRVTarget, reason NetworkPolicyResolvedConditionReason, message string,
+) SRVStatus {
+	timeNow := metav1.Now()
+	return SRVStatus{
+		Name:               name,
+		LastSuccessfulTime: timeNow,
+		LastTransitionTime: timeNow,
+		ResolveReason:      reason,
+		ResolveMessage:     message,
+		Targets:            targets,
+	}
+}
+
+// Due returns true if the SRV name has to be resolved again at the given time
+func (s *SRVStatus) Due(now time.Time) bool {
+	return s.NextRefreshTime.IsZero() || !now.Before(s.NextRefreshTime.Time)
+}
+
+type SRVStatusList []SRVStatus
+
+// NextRefreshTime returns the earliest time at which one of the SRV names has to be resolved again.
+// Returns false if the list does not contain any scheduled refresh.
+func (l SRVStatusList) NextRefreshTime() (time.Time, bool) {
+	var next time.Time
+	for _, status := range l {
+		if status.NextRefreshTime.IsZero() {
+			continue
+		}
+		if next.IsZero() || status.NextRefreshTime.Time.Before(next) {
+			next = status.NextRefreshTime.Time
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Targets returns the sorted, unique targets of all SRV names
+func (l SRVStatusList) Targets() []FQDN {
+	var targets []FQDN
+	for _, status := range l {
+		for _, target := range status.Targets {
+			targets = append(targets, target.Target)
+		}
+	}
+	slices.Sort(targets)
+	return slices.Compact(targets)
+}
+
+// AggregatedResolveStatus returns the reason with the highest priority in the list
+func (l SRVStatusList) AggregatedResolveStatus() NetworkPolicyResolvedConditionReason {
+	reason := NetworkPolicyResolveSuccess
+	for _, status := range l {
+		if status.ResolveReason.Priority() > reason.Priority() {
+			reason = status.ResolveReason
+		}
+	}
+	return reason
+}
+
+// AggregatedResolveMessage returns the message of the reason with the highest priority in the list
+func (l SRVStatusList) AggregatedResolveMessage() string {
+	reason := NetworkPolicyResolveSuccess
+	message := ""
+	for _, status := range l {
+		if message == "" || status.ResolveReason.Priority() > reason.Priority() {
+			reason = status.ResolveReason
+			message = status.ResolveMessage
+		}
+	}
+	return message
+}
+
+func (l SRVStatusList) LookupTable() map[SRVName]*SRVStatus {
+	lookupTable := make(map[SRVName]*SRVStatus)
+	for _, status := range l {
+		lookupTable[status.Name] = &status
+	}
+	return lookupTable
+}
@@ -0,0 +1,370 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func TestFQDNPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern FQDNPattern
+		fqdn    FQDN
+		matches bool
+	}{
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "API.Example.COM.", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", "api.example.org", false},
+		{"*.example.com", "apiexample.com", false},
+		{"**.example.com", "api.example.com", true},
+		{"**.example.com", "a.b.example.com", true},
+		{"**.example.com", "example.com", false},
+		{"*.Example.com", "api.example.com", true},
+	}
+	for _, tt := range tests {
+		if matches := tt.pattern.Matches(tt.fqdn); matches != tt.matches {
+			t.Errorf("%s matching %s: expected %v, got %v", tt.pattern, tt.fqdn, tt.matches, matches)
+		}
+	}
+}
+
+func TestExpandFQDNPatterns(t *testing.T) {
+	np := &NetworkPolicy{Spec: NetworkPolicySpec{Egresses: []EgressRule{
+		{
+			ToFQDNs:        []FQDN{"www.example.com"},
+			ToFQDNPatterns: []FQDNPattern{"*.example.com"},
+			KnownFQDNs:     []FQDN{"API.example.com.", "other.example.org", "a.b.example.com"},
+		},
+		{ToFQDNPatterns: []FQDNPattern{"**.example.com"}},
+	}}}
+	discover := func(pattern FQDNPattern) []FQDN {
+		return []FQDN{"www.example.com", "cdn.example.com", "api.example.com", "a.b.example.com"}
+	}
+
+	expected := map[FQDN]FQDNPattern{
+		"api.example.com": "*.example.com",
+		"cdn.example.com": "*.example.com",
+		"a.b.example.com": "**.example.com",
+	}
+	if expanded := np.ExpandFQDNPatterns(discover, 0); !maps.Equal(expanded, expected) {
+		t.Errorf("expected %v, got %v", expected, expanded)
+	}
+}
+
+func TestExpandFQDNPatternsLimit(t *testing.T) {
+	// The same pattern in two rules shares one limit, and duplicates of an expanded name do not count
+	np := &NetworkPolicy{Spec: NetworkPolicySpec{Egresses: []EgressRule{
+		{ToFQDNPatterns: []FQDNPattern{"*.example.com"}, KnownFQDNs: []FQDN{"a.example.com", "A.example.com."}},
+		{ToFQDNPatterns: []FQDNPattern{"*.example.com"}},
+	}}}
+	discover := func(pattern FQDNPattern) []FQDN {
+		var fqdns []FQDN
+		for i := range 10 {
+			fqdns = append(fqdns, FQDN(fmt.Sprintf("host%d.example.com", i)))
+		}
+		return fqdns
+	}
+
+	expanded := np.ExpandFQDNPatterns(discover, 3)
+	if len(expanded) != 3 {
+		t.Fatalf("expected 3 expanded FQDNs, got %v", expanded)
+	}
+	if _, ok := expanded["a.example.com"]; !ok {
+		t.Errorf("expected a.example.com to be expanded once, got %v", expanded)
+	}
+	if expanded := np.ExpandFQDNPatterns(discover, 0); len(expanded) != 11 {
+		t.Errorf("expected 11 expanded FQDNs without a limit, got %d", len(expanded))
+	}
+}
+
+func TestGetIPBlocks(t *testing.T) {
+	tests := []struct {
+		name        string
+		cidrs       []CIDRPeer
+		globalBlock bool
+		ruleBlock   *bool
+		expected    []mnetv1beta1.IPBlock
+	}{
+		{
+			name:     "masks the CIDR and keeps its except blocks",
+			cidrs:    []CIDRPeer{{CIDR: "192.0.2.7/24", Except: []string{"192.0.2.128/25"}}},
+			expected: []mnetv1beta1.IPBlock{{CIDR: "192.0.2.0/24", Except: []string{"192.0.2.128/25"}}},
+		},
+		{
+			name:     "skips invalid CIDRs",
+			cidrs:    []CIDRPeer{{CIDR: "invalid"}, {CIDR: "2001:db8::/32"}},
+			expected: []mnetv1beta1.IPBlock{{CIDR: "2001:db8::/32"}},
+		},
+		{
+			name:        "drops CIDRs within a private range",
+			cidrs:       []CIDRPeer{{CIDR: "10.1.0.0/16"}, {CIDR: "192.0.2.0/24"}},
+			globalBlock: true,
+			expected:    []mnetv1beta1.IPBlock{{CIDR: "192.0.2.0/24"}},
+		},
+		{
+			name:        "excludes the private ranges within a CIDR",
+			cidrs:       []CIDRPeer{{CIDR: "0.0.0.0/0"}},
+			globalBlock: true,
+			expected: []mnetv1beta1.IPBlock{{
+				CIDR: "0.0.0.0/0", Except: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+			}},
+		},
+		{
+			name:        "the rule overrides the global setting",
+			cidrs:       []CIDRPeer{{CIDR: "10.1.0.0/16"}},
+			globalBlock: true,
+			ruleBlock:   ptr.To(false),
+			expected:    []mnetv1beta1.IPBlock{{CIDR: "10.1.0.0/16"}},
+		},
+		{
+			name:        "deduplicates the except blocks within a private range",
+			cidrs:       []CIDRPeer{{CIDR: "0.0.0.0/0", Except: []string{"10.1.0.0/16", "10.0.0.0/8", "198.51.100.0/24"}}},
+			globalBlock: true,
+			expected: []mnetv1beta1.IPBlock{{
+				CIDR:   "0.0.0.0/0",
+				Except: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "198.51.100.0/24"},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if blocks := getIPBlocks(test.cidrs, test.globalBlock, test.ruleBlock); !reflect.DeepEqual(blocks, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, blocks)
+			}
+		})
+	}
+}
+
+func TestExcludeCIDRs(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		except   []string
+		expected []string
+	}{
+		{"192.0.2.0/24", nil, []string{"192.0.2.0/24"}},
+		{"192.0.2.0/24", []string{"192.0.2.0/25"}, []string{"192.0.2.128/25"}},
+		{"192.0.2.0/24", []string{"192.0.2.64/26"}, []string{"192.0.2.0/26", "192.0.2.128/25"}},
+		{"192.0.2.0/24", []string{"192.0.2.0/24"}, nil},
+		{"192.0.2.0/24", []string{"198.51.100.0/24"}, []string{"192.0.2.0/24"}},
+		{"192.0.2.0/24", []string{"192.0.2.64/26", "192.0.2.64/27"}, []string{"192.0.2.0/26", "192.0.2.128/25"}},
+		{"2001:db8::/32", []string{"2001:db8:8000::/33"}, []string{"2001:db8::/33"}},
+		{"invalid", nil, nil},
+	}
+	for _, test := range tests {
+		excluded := ExcludeCIDRs(test.cidr, test.except)
+		slices.Sort(excluded)
+		if !slices.Equal(excluded, test.expected) {
+			t.Errorf("expected %v for %s without %v, got %v", test.expected, test.cidr, test.except, excluded)
+		}
+	}
+}
+
+func TestPodSelector(t *testing.T) {
+	np := &NetworkPolicy{Spec: NetworkPolicySpec{
+		MatchLabels: []MatchLabel{{Label: "team", Value: "payments"}, {Label: LabelWithKubernetesAppName, Value: "web"}},
+		MatchExpressions: []LabelSelectorRequirement{
+			{Key: "example.com/tier", Operator: metav1.LabelSelectorOpIn, Values: []LabelValue{"frontend", "api"}},
+			{Key: "example.com/canary", Operator: metav1.LabelSelectorOpExists},
+			{Key: "example.com/legacy", Operator: metav1.LabelSelectorOpDoesNotExist},
+		},
+	}}
+
+	expected := metav1.LabelSelector{
+		MatchLabels: map[string]string{"team": "payments", "app.kubernetes.io/name": "web"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "example.com/tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"frontend", "api"}},
+			{Key: "example.com/canary", Operator: metav1.LabelSelectorOpExists},
+			{Key: "example.com/legacy", Operator: metav1.LabelSelectorOpDoesNotExist},
+		},
+	}
+	selector := np.PodSelector()
+	if !reflect.DeepEqual(selector, expected) {
+		t.Errorf("expected %v, got %v", expected, selector)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(&selector); err != nil {
+		t.Errorf("expected a valid selector, got %v", err)
+	}
+
+	// The MultiNetworkPolicy selects the same pods
+	np.Spec.Egresses = []EgressRule{{ToCIDRs: []CIDRPeer{{CIDR: "192.0.2.0/24"}}}}
+	mnp := np.ToMultiNetworkPolicy(nil, nil)
+	if !reflect.DeepEqual(mnp.Spec.PodSelector, expected) {
+		t.Errorf("expected the pod selector %v, got %v", expected, mnp.Spec.PodSelector)
+	}
+}
+
+func TestToMultiNetworkPolicyIngress(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	https := intstr.FromInt(443)
+	fqdnStatuses := []FQDNStatus{
+		{FQDN: "client.example.com", AddressRecords: []AddressRecord{{Address: "192.0.2.1/32"}}},
+		{FQDN: "private.example.com", AddressRecords: []AddressRecord{{Address: "10.0.0.1/32"}}},
+	}
+	np := &NetworkPolicy{Spec: NetworkPolicySpec{
+		BlockPrivateIPs: ptr.To(true),
+		Ingresses: []IngressRule{
+			{FromFQDNs: []FQDN{"client.example.com", "private.example.com"}, Ports: []MultiNetworkPolicyPort{
+				{Protocol: tcp, Port: 443},
+			}},
+			// Rules without addresses are omitted
+			{FromFQDNs: []FQDN{"private.example.com"}},
+		},
+	}}
+
+	mnp := np.ToMultiNetworkPolicy(fqdnStatuses, nil)
+	expected := []mnetv1beta1.MultiNetworkPolicyIngressRule{{
+		Ports: []mnetv1beta1.MultiNetworkPolicyPort{{Protocol: &tcp, Port: &https}},
+		From: []mnetv1beta1.MultiNetworkPolicyPeer{
+			{IPBlock: &mnetv1beta1.IPBlock{CIDR: "192.0.2.1/32"}},
+		},
+	}}
+	if !reflect.DeepEqual(mnp.Spec.Ingress, expected) {
+		t.Errorf("expected the ingress rules %v, got %v", expected, mnp.Spec.Ingress)
+	}
+	if mnp.Spec.Egress != nil {
+		t.Errorf("expected no egress rules, got %v", mnp.Spec.Egress)
+	}
+}
+
+func TestToMultiNetworkPolicyPolicyTypes(t *testing.T) {
+	egress := []EgressRule{{ToCIDRs: []CIDRPeer{{CIDR: "192.0.2.0/24"}}}}
+	ingress := []IngressRule{{FromFQDNs: []FQDN{"client.example.com"}}}
+	tests := []struct {
+		name     string
+		spec     NetworkPolicySpec
+		expected []mnetv1beta1.MultiPolicyType
+	}{
+		{"ingress only", NetworkPolicySpec{Ingresses: ingress}, []mnetv1beta1.MultiPolicyType{
+			mnetv1beta1.PolicyTypeIngress,
+		}},
+		{"egress only", NetworkPolicySpec{Egresses: egress}, []mnetv1beta1.MultiPolicyType{
+			mnetv1beta1.PolicyTypeEgress,
+		}},
+		{"both", NetworkPolicySpec{Egresses: egress, Ingresses: ingress}, []mnetv1beta1.MultiPolicyType{
+			mnetv1beta1.PolicyTypeEgress, mnetv1beta1.PolicyTypeIngress,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Unresolved ingress rules are omitted, but their policy type still denies the other traffic
+			np := &NetworkPolicy{Spec: test.spec}
+			if mnp := np.ToMultiNetworkPolicy(nil, nil); !slices.Equal(mnp.Spec.PolicyTypes, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, mnp.Spec.PolicyTypes)
+			}
+		})
+	}
+	if mnp := (&NetworkPolicy{}).ToMultiNetworkPolicy(nil, nil); mnp != nil {
+		t.Errorf("expected no MultiNetworkPolicy without rules, got %v", mnp)
+	}
+}
+
+func TestMergeAddressRecordsLimit(t *testing.T) {
+	seen := metav1.Now()
+	var previous []AddressRecord
+	for i := range maxRetainedAddressRecords + 5 {
+		previous = append(previous, AddressRecord{
+			Address:  fmt.Sprintf("10.0.%d.%d/32", i/256, i%256),
+			LastSeen: metav1.NewTime(seen.Add(-time.Duration(i+1) * time.Second)),
+		})
+	}
+
+	records := mergeAddressRecords(previous, []string{"192.0.2.1/32"}, seen, time.Hour, "www.example.com", nil)
+	if len(records) != maxRetainedAddressRecords+1 {
+		t.Fatalf("expected %d records, got %d", maxRetainedAddressRecords+1, len(records))
+	}
+	if !slices.ContainsFunc(records, func(r AddressRecord) bool { return r.Address == "192.0.2.1/32" }) {
+		t.Error("expected the address of the latest lookup to be kept")
+	}
+	for _, evicted := range previous[maxRetainedAddressRecords:] {
+		if slices.ContainsFunc(records, func(r AddressRecord) bool { return r.Address == evicted.Address }) {
+			t.Errorf("expected %s seen least recently to be evicted", evicted.Address)
+		}
+	}
+}
+
+func TestFQDNStatusUpdateSeedsAddressRecords(t *testing.T) {
+	// A status written before the address records only has the addresses
+	lastSuccess := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	status := FQDNStatus{
+		FQDN: "www.example.com", Addresses: []string{"192.0.2.1/32"}, LastSuccessfulTime: lastSuccess,
+	}
+	if addresses := status.ResolvedAddresses(); !slices.Equal(addresses, []string{"192.0.2.1/32"}) {
+		t.Errorf("expected the addresses of the status, got %v", addresses)
+	}
+
+	cidr, err := NewCIDR("192.0.2.2/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status.Update([]*CIDR{cidr}, nil, NetworkPolicyResolveSuccess, "", 3600, time.Hour)
+	if !slices.Equal(status.Addresses, []string{"192.0.2.1/32", "192.0.2.2/32"}) {
+		t.Errorf("expected the retained and the resolved address, got %v", status.Addresses)
+	}
+	if len(status.AddressRecords) != 2 || !status.AddressRecords[0].LastSeen.Equal(&lastSuccess) {
+		t.Errorf("expected the seeded record to be last seen at the last success, got %v", status.AddressRecords)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	resolver := &ResolverConfig{Nameservers: []string{"192.0.2.53"}}
+	namespaceDefaults := &NetworkPolicyDefaultsSpec{
+		EnabledNetworkType: All,
+		TTLSeconds:         300,
+		BlockPrivateIPs:    ptr.To(true),
+		Resolver:           resolver,
+	}
+	tests := []struct {
+		name     string
+		spec     NetworkPolicySpec
+		defaults *NetworkPolicyDefaultsSpec
+		expected NetworkPolicySpec
+	}{
+		{
+			name:     "built-in defaults",
+			expected: NetworkPolicySpec{EnabledNetworkType: IPv4, RetryTimeoutSeconds: 3600, TTLSeconds: 60},
+		},
+		{
+			name:     "namespace defaults",
+			defaults: namespaceDefaults,
+			expected: NetworkPolicySpec{
+				EnabledNetworkType: All, RetryTimeoutSeconds: 3600, TTLSeconds: 300,
+				BlockPrivateIPs: ptr.To(true), Resolver: resolver,
+			},
+		},
+		{
+			name: "set fields are kept",
+			spec: NetworkPolicySpec{
+				EnabledNetworkType: IPv6, RetryTimeoutSeconds: 60, TTLSeconds: 30,
+				BlockPrivateIPs: ptr.To(false), Resolver: &ResolverConfig{Nameservers: []string{"198.51.100.53"}},
+			},
+			defaults: namespaceDefaults,
+			expected: NetworkPolicySpec{
+				EnabledNetworkType: IPv6, RetryTimeoutSeconds: 60, TTLSeconds: 30,
+				BlockPrivateIPs: ptr.To(false), Resolver: &ResolverConfig{Nameservers: []string{"198.51.100.53"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &NetworkPolicy{Spec: tt.spec}
+			np.ApplyDefaults(tt.defaults)
+			if !reflect.DeepEqual(np.Spec, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, np.Spec)
+			}
+			if tt.defaults != nil && np.Spec.Resolver == tt.defaults.Resolver {
+				t.Error("expected the resolver of the defaults to be copied")
+			}
+		})
+	}
+}
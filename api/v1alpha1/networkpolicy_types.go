@@ -51,9 +51,15 @@ func (n NetworkType) ResolverString() string {
 	return ""
 }
 
-// +kubebuilder:validation:Enum=vm.kubevirt.io/name;app.kubernetes.io/name
+// Label is a label key: a name of at most 63 characters, optionally prefixed by a DNS subdomain and a slash,
+// e.g. 'team' or 'app.kubernetes.io/name'.
+//
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:MaxLength=317
+// +kubebuilder:validation:XValidation:rule="self.matches('^([a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$')",message="must be a valid label key"
 type Label string
 
+// Well-known label keys
 const (
 	LabelWithVirtualMachineName Label = "vm.kubevirt.io/name"
 	LabelWithKubernetesAppName  Label = "app.kubernetes.io/name"
@@ -74,22 +80,26 @@ type MatchLabel struct {
 type LabelValue string
 
 // Shadow LabelSelectorRequirement struct
+//
+// +kubebuilder:validation:XValidation:rule="self.operator in ['In', 'NotIn'] ? (has(self.values) && size(self.values) > 0) : (!has(self.values) || size(self.values) == 0)",message="values must be non-empty for the In and NotIn operators and empty for the Exists and DoesNotExist operators"
 type LabelSelectorRequirement struct {
 	// Key is the label key that the selector applies to.
 	// +kubebuilder:default="vm.kubevirt.io/name"
-	// +kubebuilder:validation:Enum=vm.kubevirt.io/name;app.kubernetes.io/name
-	Key string `json:"key"`
-	// Operator represents a key's relationship to a set of values. Valid operators are In and NotIn.
+	Key Label `json:"key"`
+	// Operator represents a key's relationship to a set of values. Valid operators are In, NotIn, Exists and DoesNotExist.
 	// +kubebuilder:default="In"
-	// +kubebuilder:validation:Enum=In;NotIn
+	// +kubebuilder:validation:Enum=In;NotIn;Exists;DoesNotExist
 	Operator metav1.LabelSelectorOperator `json:"operator"`
-	// Values is an array of string values. If the operator is In or NotIn, the values array must be non-empty.
+	// Values is an array of string values. If the operator is In or NotIn, the values array must be non-empty. If the operator is Exists or DoesNotExist, the values array must be empty.
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MaxItems=50
 	// +listType=set
-	Values []LabelValue `json:"values"`
+	Values []LabelValue `json:"values,omitempty"`
 }
 
 // Shadow MultiNetworkPolicyPort struct
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.endPort) || self.endPort >= self.port",message="endPort must be greater than or equal to port"
 type MultiNetworkPolicyPort struct {
 	// Protocol defines network protocols supported for things like container ports.
 	// +kubebuilder:default="TCP"
@@ -99,6 +109,11 @@ type MultiNetworkPolicyPort struct {
 	// +kubebuilder:validation:Minimum=1
 	// +kubebuilder:validation:Maximum=65535
 	Port int32 `json:"port"`
+	// EndPort allows the range of ports from Port to EndPort, inclusive. Only a single port is allowed if not specified.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	EndPort *int32 `json:"endPort,omitempty"`
 }
 
 // FQDN is short for Fully Qualified Domain Name and represents a complete domain name that uniquely identifies a host on the internet. It must consist of one or more labels separated by dots (e.g., "api.example.com"), where each label can contain letters, digits, and hyphens, but cannot start or end with a hyphen. The FQDN must end with a top-level domain (e.g., ".com", ".org") of at least two characters.
@@ -106,13 +121,86 @@ type MultiNetworkPolicyPort struct {
 // +kubebuilder:validation:Pattern=`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`
 type FQDN string
 
+// FQDNPattern is a wildcard domain name that matches multiple FQDNs. The leftmost label must be a wildcard:
+//
+//   - '*.example.com' matches exactly one label in place of the wildcard, e.g. 'api.example.com' but not 'a.b.example.com'
+//   - '**.example.com' matches one or more labels in place of the wildcard, e.g. 'api.example.com' and 'a.b.example.com'
+//
+// The pattern never matches the bare domain itself ('example.com').
+//
+// +kubebuilder:validation:Pattern=`^\*{1,2}\.([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`
+type FQDNPattern string
+
+// SRVName is the name of the SRV records of a service in the form '_service._protocol.domain', e.g. '_ldap._tcp.corp.example.com'. The protocol label must be one of '_tcp', '_udp' or '_sctp'.
+//
+// +kubebuilder:validation:Pattern=`^_[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\._(tcp|udp|sctp)\.([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`
+type SRVName string
+
+// SRVTarget is a host and port announced by an SRV record
+type SRVTarget struct {
+	// Target is the host providing the service
+	Target FQDN `json:"target"`
+	// Port is the port of the service on the host
+	Port int32 `json:"port"`
+}
+
+// CIDRPeer defines a static IP range to which traffic is allowed
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.except) || !isCIDR(self.cidr) || self.except.all(e, !isCIDR(e) || cidr(self.cidr).containsCIDR(e))",message="except blocks must be within cidr"
+type CIDRPeer struct {
+	// CIDR is the IP range in CIDR notation, e.g. '192.0.2.0/24' or '2001:db8::/32'.
+	// +kubebuilder:validation:MaxLength=43
+	// +kubebuilder:validation:XValidation:rule="isCIDR(self)",message="cidr must be in CIDR notation"
+	CIDR string `json:"cidr"`
+	// Except lists IP ranges within CIDR to which traffic is not allowed.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=20
+	// +kubebuilder:validation:items:MaxLength=43
+	// +kubebuilder:validation:XValidation:rule="self.all(e, isCIDR(e))",message="except blocks must be in CIDR notation"
+	// +listType=set
+	Except []string `json:"except,omitempty"`
+}
+
 // EgressRule defines rules for outbound network traffic to the specified FQDNs on the specified ports.
 // Each FQDNs IP's will be looked up periodically to update the underlying NetworkPolicy.
+//
+// +kubebuilder:validation:XValidation:rule="(has(self.toFQDNs) && self.toFQDNs.size() > 0) || (has(self.toFQDNPatterns) && self.toFQDNPatterns.size() > 0) || (has(self.toCIDRs) && self.toCIDRs.size() > 0) || (has(self.toSRV) && self.toSRV.size() > 0)",message="at least one of toFQDNs, toFQDNPatterns, toCIDRs or toSRV must be specified"
 type EgressRule struct {
 	// ToFQDNs are the FQDNs to which traffic is allowed (outgoing).
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=50
+	// +listType=set
+	ToFQDNs []FQDN `json:"toFQDNs,omitempty"`
+	// ToFQDNPatterns are wildcard patterns to which traffic is allowed (outgoing).
+	// Since wildcards cannot be resolved directly, the concrete names matching a pattern are discovered from:
+	//
+	//  - KnownFQDNs of this rule
+	//  - CNAME targets seen while resolving other FQDNs
+	//  - Names reported to the operator by a DNS observer
+	//
+	// Each discovered name is resolved like an FQDN listed in ToFQDNs and reported in the status with its pattern.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=20
+	// +listType=set
+	ToFQDNPatterns []FQDNPattern `json:"toFQDNPatterns,omitempty"`
+	// KnownFQDNs lists concrete names that are matched against ToFQDNPatterns. Names not matching any of the patterns of this rule are ignored.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=100
+	// +listType=set
+	KnownFQDNs []FQDN `json:"knownFQDNs,omitempty"`
+	// ToCIDRs are static IP ranges to which traffic is allowed (outgoing), in addition to the addresses of the FQDNs.
+	// Private ranges are excluded from them when private IPs are blocked. EnabledNetworkType does not apply to them, IPv6 ranges are allowed even if only IPv4 is enabled and vice versa. Not allowed in namespaces whose FQDNGovernancePolicies forbid CIDRs.
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MaxItems=50
+	// +listType=map
+	// +listMapKey=cidr
+	ToCIDRs []CIDRPeer `json:"toCIDRs,omitempty"`
+	// ToSRV are the names of SRV records whose targets traffic is allowed to (outgoing). The targets are resolved like FQDNs and each of them is only allowed on the port announced by its SRV record, using the protocol of the SRV name. Ports does not apply to them.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=20
 	// +listType=set
-	ToFQDNs []FQDN `json:"toFQDNs"`
+	ToSRV []SRVName `json:"toSRV,omitempty"`
 	// Ports describes the ports to allow traffic on.
 	// +kubebuilder:validation:MaxItems=10
 	// +listType=map
@@ -123,14 +211,174 @@ type EgressRule struct {
 	BlockPrivateIPs *bool `json:"blockPrivateIPs,omitempty"`
 }
 
+// IngressRule defines rules for inbound network traffic from the specified FQDNs on the specified ports.
+// The addresses of the FQDNs are looked up periodically like the FQDNs of Egress rules.
+type IngressRule struct {
+	// FromFQDNs are the FQDNs from which traffic is allowed (incoming).
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=50
+	// +listType=set
+	FromFQDNs []FQDN `json:"fromFQDNs"`
+	// Ports describes the ports to allow traffic on.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=10
+	// +listType=map
+	// +listMapKey=protocol
+	// +listMapKey=port
+	Ports []MultiNetworkPolicyPort `json:"ports,omitempty"`
+	// When set, overwrites the default behavior of the same field in NetworkPolicySpec.
+	BlockPrivateIPs *bool `json:"blockPrivateIPs,omitempty"`
+}
+
+// DNSProtocol defines the transport used to query nameservers
+//
+//   - Options are one of: 'UDP', 'TCP', 'TLS' (DNS-over-TLS, RFC 7858), 'HTTPS' (DNS-over-HTTPS, RFC 8484)
+//
+// +kubebuilder:validation:Enum=UDP;TCP;TLS;HTTPS
+type DNSProtocol string
+
+const (
+	DNSProtocolUDP   DNSProtocol = "UDP"
+	DNSProtocolTCP   DNSProtocol = "TCP"
+	DNSProtocolTLS   DNSProtocol = "TLS"
+	DNSProtocolHTTPS DNSProtocol = "HTTPS"
+)
+
+// DefaultPort returns the well-known port of the protocol
+func (p DNSProtocol) DefaultPort() int32 {
+	switch p {
+	case DNSProtocolTLS:
+		return 853
+	case DNSProtocolHTTPS:
+		return 443
+	}
+	return 53
+}
+
+// ResolverTLSConfig defines how the certificates of nameservers queried over TLS or HTTPS are verified
+type ResolverTLSConfig struct {
+	// ServerName is sent as SNI and used to verify the certificate of the nameservers. Since nameservers are given as IP addresses, it is required unless their certificates contain the IP addresses.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=253
+	ServerName string `json:"serverName,omitempty"`
+
+	// CASecretRef selects a key of a Secret in the namespace of the network policy holding the PEM encoded CA certificates used to verify the nameservers.
+	//
+	//  - Defaults to the system trust store if not specified
+	//
+	// +kubebuilder:validation:Optional
+	CASecretRef *corev1.SecretKeySelector `json:"caSecretRef,omitempty"`
+}
+
+// ResolverConfig defines the nameservers used to resolve the FQDNs of a network policy and how names are searched, following the semantics of resolv.conf.
+type ResolverConfig struct {
+	// Nameservers are the IP addresses of the nameservers to query. They are tried in order until one answers.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=3
+	// +kubebuilder:validation:items:MaxLength=45
+	// +kubebuilder:validation:XValidation:rule="self.all(s, isIP(s))",message="nameservers must be IP addresses"
+	// +listType=atomic
+	Nameservers []string `json:"nameservers"`
+
+	// Port is the port the nameservers listen on.
+	//
+	//  - Defaults to the well-known port of the protocol if not specified: 53 for UDP and TCP, 853 for TLS, 443 for HTTPS
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// Protocol is the transport used to query the nameservers. UDP queries are retried over TCP when the response is truncated.
+	//
+	//  - Options are one of: 'UDP', 'TCP', 'TLS', 'HTTPS'
+	//  - Defaults to 'UDP' if not specified
+	//
+	// +kubebuilder:default:=UDP
+	Protocol DNSProtocol `json:"protocol,omitempty"`
+
+	// Path is the URL path of the DNS-over-HTTPS endpoint. Only used with the HTTPS protocol.
+	//
+	//  - Defaults to '/dns-query' if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path,omitempty"`
+
+	// TLS configures the verification of the nameservers. Only used with the TLS and HTTPS protocols.
+	// +kubebuilder:validation:Optional
+	TLS *ResolverTLSConfig `json:"tls,omitempty"`
+
+	// Search is the list of domains appended to FQDNs with fewer dots than NDots before they are resolved as-is.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=6
+	// +listType=atomic
+	Search []FQDN `json:"search,omitempty"`
+
+	// NDots is the number of dots an FQDN must contain to be resolved as-is before the search domains are tried.
+	//
+	//  - Defaults to 1 if not specified
+	//  - Maximum value is 15
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=15
+	// +kubebuilder:default:=1
+	NDots int32 `json:"ndots,omitempty"`
+}
+
+// OutputKind defines the kind of network policy generated from an FQDN network policy
+//
+//   - Options are one of: 'MultiNetworkPolicy', 'NetworkPolicy', 'CiliumNetworkPolicy', 'EgressFirewall'
+//
+// +kubebuilder:validation:Enum=MultiNetworkPolicy;NetworkPolicy;CiliumNetworkPolicy;EgressFirewall
+type OutputKind string
+
+const (
+	// OutputKindMultiNetworkPolicy generates a k8s.cni.cncf.io/v1beta1 MultiNetworkPolicy for a secondary network
+	OutputKindMultiNetworkPolicy OutputKind = "MultiNetworkPolicy"
+	// OutputKindNetworkPolicy generates a networking.k8s.io/v1 NetworkPolicy for the primary pod network
+	OutputKindNetworkPolicy OutputKind = "NetworkPolicy"
+	// OutputKindCiliumNetworkPolicy generates a cilium.io/v2 CiliumNetworkPolicy for clusters running Cilium
+	OutputKindCiliumNetworkPolicy OutputKind = "CiliumNetworkPolicy"
+	// OutputKindEgressFirewall merges all FQDN network policies of a namespace into the k8s.ovn.org/v1 EgressFirewall
+	// of the namespace for clusters running OVN-Kubernetes
+	OutputKindEgressFirewall OutputKind = "EgressFirewall"
+)
+
 // NetworkPolicySpec defines the desired state of NetworkPolicy.
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.outputKind) || self.outputKind != 'MultiNetworkPolicy' || (has(self.targetNetwork) && size(self.targetNetwork) > 0) || (has(self.targetNetworks) && size(self.targetNetworks) > 0)",message="targetNetwork or targetNetworks is required when outputKind is MultiNetworkPolicy"
+// +kubebuilder:validation:XValidation:rule="!has(self.targetNetwork) || !has(self.targetNetworks)",message="targetNetwork and targetNetworks are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!has(self.outputKind) || self.outputKind != 'EgressFirewall' || !has(self.egress) || self.egress.all(r, r.ports.all(p, !has(p.endPort) || p.endPort == p.port))",message="port ranges are not supported by EgressFirewalls"
+// +kubebuilder:validation:XValidation:rule="!has(self.outputKind) || self.outputKind != 'EgressFirewall' || !has(self.ingress) || size(self.ingress) == 0",message="ingress rules are not supported by EgressFirewalls"
+// +kubebuilder:validation:XValidation:rule="(has(self.egress) && size(self.egress) > 0) || (has(self.ingress) && size(self.ingress) > 0)",message="at least one of egress or ingress must be specified"
 type NetworkPolicySpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// TargetNetwork represents the network where the network policy is effective. If the list is empty, please confirm whether a NAD has been created in the current project.
+	// OutputKind is the kind of network policy generated from this FQDN network policy.
+	//
+	//  - 'MultiNetworkPolicy' applies to the secondary networks given by TargetNetwork or TargetNetworks
+	//  - 'NetworkPolicy' applies to the primary pod network, TargetNetwork and TargetNetworks are ignored
+	//  - 'CiliumNetworkPolicy' applies to the pods managed by Cilium, TargetNetwork and TargetNetworks are ignored
+	//  - 'EgressFirewall' applies to all pods of the namespace, merged with the other FQDN network policies of the namespace using this kind. MatchLabels, MatchExpressions, TargetNetwork and TargetNetworks are ignored.
+	//  - Defaults to the defaultOutputKind of the OperatorConfig, or 'MultiNetworkPolicy' if neither is specified
+	//
+	// +kubebuilder:validation:Optional
+	OutputKind OutputKind `json:"outputKind,omitempty"`
+
+	// TargetNetwork represents the network where the network policy is effective. If the list is empty, please confirm whether a NAD has been created in the current project. Required when OutputKind is MultiNetworkPolicy.
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MinLength=1
-	TargetNetwork string `json:"targetNetwork"`
+	TargetNetwork string `json:"targetNetwork,omitempty"`
+
+	// TargetNetworks lists the networks where the network policy is effective, for pods attached to several secondary networks. Each entry is the name of a NetworkAttachmentDefinition in the namespace of the network policy, or a namespace/name reference to a NetworkAttachmentDefinition in another namespace. Mutually exclusive with TargetNetwork.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=317
+	// +listType=set
+	TargetNetworks []string `json:"targetNetworks,omitempty"`
 
 	// MatchLabels defines which pods this network policy shall apply to.
 	// +kubebuilder:validation:Optional
@@ -142,20 +390,26 @@ type NetworkPolicySpec struct {
 	// MatchExpressions defines which pods this network policy shall apply to.
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MaxItems=30
-	// +kubebuilder:validation:XValidation:rule="self.all(i, self.filter(j, i.key == j.key && i.operator == j.operator && j.values.exists(v, v in i.values)).size() == 1)",message="spec.matchExpressions in body should not contain overlapping values for the same key and operator"
+	// +kubebuilder:validation:XValidation:rule="self.all(i, self.filter(j, i.key == j.key && i.operator == j.operator && (i.operator in ['Exists', 'DoesNotExist'] || j.values.exists(v, v in i.values))).size() == 1)",message="spec.matchExpressions in body should not contain overlapping values for the same key and operator"
 	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
 
-	// Egresses defines the outbound network traffic rules for the selected pods.
+	// Egresses defines the outbound network traffic rules for the selected pods. Outbound traffic of the selected pods is only restricted if Egress rules are specified.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=30
+	// +kubebuilder:validation:XValidation:rule="self.all(i, !has(i.toFQDNs) || self.filter(j, has(j.toFQDNs) && j.toFQDNs.exists(f, f in i.toFQDNs) && j.ports.exists(p, p in i.ports)).size() <= 1)",message="spec.egress in body should not contain overlapping toFQDNs and ports across different rules"
+	Egresses []EgressRule `json:"egress,omitempty"`
+
+	// Ingresses defines the inbound network traffic rules for the selected pods. Inbound traffic of the selected pods is only restricted if Ingress rules are specified.
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MaxItems=30
-	// +kubebuilder:validation:XValidation:rule="self.all(i, self.filter(j, j.toFQDNs.exists(f, f in i.toFQDNs) && j.ports.exists(p, p in i.ports)).size() == 1)",message="spec.egress in body should not contain overlapping toFQDNs and ports across different rules"	
-	Egresses []EgressRule `json:"egress"`
+	Ingresses []IngressRule `json:"ingress,omitempty"`
 
 	// EnabledNetworkType defines which type of IP addresses to allow.
 	//
 	//  - Options are one of: 'all', 'ipv4', 'ipv6'
-	//  - Defaults to 'ipv4' if not specified
+	//  - Defaults to the NetworkPolicyDefaults of the namespace, or 'ipv4' if neither is specified
 	//
-	// +kubebuilder:default:=ipv4
+	// +kubebuilder:validation:Optional
 	EnabledNetworkType NetworkType `json:"enabledNetworkType,omitempty"`
 
 	// The timeout to use for lookups of the FQDNs.
@@ -172,29 +426,89 @@ type NetworkPolicySpec struct {
 
 	// How long the resolving of an individual FQDN should be retried in case of errors before being removed from the underlying network policy. This ensures intermittent failures in name resolution do not clear existing addresses causing unwanted service disruption.
 	//
-	//  - Defaults to 3600 (1 hour) if not specified (nil)
+	//  - Defaults to the NetworkPolicyDefaults of the namespace, or 3600 (1 hour) if neither is specified
 	//  - Maximum value is 86400 (24 hours)
 	//
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Maximum=86400
-	// +kubebuilder:default:=3600
 	RetryTimeoutSeconds int32 `json:"retryTimeoutSeconds,omitempty"`
 
-	// The interval at which the IP addresses of the FQDNs are re-evaluated.
+	// The interval at which the IP addresses of the FQDNs are re-evaluated when the DNS answer does not provide a TTL, e.g. because the lookup failed. Successful lookups are re-evaluated when their DNS records expire.
 	//
-	//  - Defaults to 60 seconds if not specified
+	//  - Defaults to the NetworkPolicyDefaults of the namespace, or 60 seconds if neither is specified
 	//  - Maximum value is 1800 seconds
 	//  - Minimum value is 5 seconds
 	//  - Must be greater than ResolveTimeoutSeconds
 	//
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Minimum=5
 	// +kubebuilder:validation:Maximum=1800
-	// +kubebuilder:default:=60
 	TTLSeconds int32 `json:"ttlSeconds,omitempty"`
 
 	// When set to true, all private IPs are omitted from the rules unless otherwise specified at the EgressRule level.
 	//
-	//  - Defaults to false if not specified
-	BlockPrivateIPs bool `json:"blockPrivateIPs,omitempty"`
+	//  - Defaults to the NetworkPolicyDefaults of the namespace, or false if neither is specified
+	//
+	// +kubebuilder:validation:Optional
+	BlockPrivateIPs *bool `json:"blockPrivateIPs,omitempty"`
+
+	// Resolver defines the nameservers used to resolve the FQDNs of this network policy.
+	//
+	//  - Defaults to the NetworkPolicyDefaults of the namespace, or the resolver configured for the operator if neither is specified
+	//  - Not allowed in namespaces whose FQDNGovernancePolicies forbid custom resolvers, the NetworkPolicyDefaults do not apply there either
+	//
+	// +kubebuilder:validation:Optional
+	Resolver *ResolverConfig `json:"resolver,omitempty"`
+
+	// AddressRetention defines how the addresses of an FQDN are updated when it is resolved again.
+	//
+	//  - Defaults to replacing the addresses with the addresses of the latest lookup if not specified
+	//
+	// +kubebuilder:validation:Optional
+	AddressRetention *AddressRetention `json:"addressRetention,omitempty"`
+
+	// AllowedCNAMESuffixes restricts the CNAME chains of the FQDNs to the given domains and their subdomains. When the CNAME chain of an FQDN leaves these domains, its resolution stops with the reason CNAME_NOT_ALLOWED and its addresses are removed, so a hijacked CNAME cannot widen the rules. FQDNs also fail with this reason if the resolver cannot report their full CNAME chain.
+	//
+	//  - Defaults to allowing any CNAME if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=50
+	// +listType=set
+	AllowedCNAMESuffixes []FQDN `json:"allowedCNAMESuffixes,omitempty"`
+}
+
+// AddressRetentionMode defines how the addresses of an FQDN are updated when it is resolved again
+// +kubebuilder:validation:Enum=Replace;Sticky
+type AddressRetentionMode string
+
+const (
+	// AddressRetentionReplace replaces the addresses of an FQDN with the addresses returned by the latest lookup
+	AddressRetentionReplace AddressRetentionMode = "Replace"
+	// AddressRetentionSticky keeps every address returned within the retention window, for FQDNs answering with a
+	// different subset of a large pool on each query
+	AddressRetentionSticky AddressRetentionMode = "Sticky"
+)
+
+// AddressRetention defines how long the resolved addresses of an FQDN are kept
+type AddressRetention struct {
+	// Mode defines how the addresses of an FQDN are updated when it is resolved again.
+	//
+	//  - 'Replace' keeps only the addresses returned by the latest lookup
+	//  - 'Sticky' keeps the union of the addresses returned within the last WindowSeconds. Each address expires WindowSeconds after the last lookup returning it. At most 100 addresses are kept per FQDN besides those of the latest lookup, the addresses seen least recently are removed first.
+	//  - Defaults to 'Replace' if not specified
+	//
+	// +kubebuilder:default:=Replace
+	Mode AddressRetentionMode `json:"mode,omitempty"`
+
+	// WindowSeconds is how long an address is kept after the last lookup returning it, when Mode is 'Sticky'. Failed lookups do not expire addresses, they are removed after RetryTimeoutSeconds instead.
+	//
+	//  - Defaults to 3600 (1 hour) if not specified
+	//  - Maximum value is 86400 (24 hours)
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +kubebuilder:default:=3600
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
 }
 
 type NetworkPolicyConditionType string
@@ -202,6 +516,12 @@ type NetworkPolicyConditionType string
 const (
 	NetworkPolicyReadyCondition    NetworkPolicyConditionType = "Ready"
 	NetworkPolicyResolvedCondition NetworkPolicyConditionType = "Resolved"
+	// NetworkPolicyNetworkAttachedCondition reports whether the NetworkAttachmentDefinition of the target network
+	// exists and can be enforced. Only set for the MultiNetworkPolicy output kind.
+	NetworkPolicyNetworkAttachedCondition NetworkPolicyConditionType = "NetworkAttached"
+	// NetworkPolicyGovernanceCondition reports whether the FQDNs comply with the FQDNGovernancePolicies of the
+	// namespace. Only set if an FQDNGovernancePolicy selects the namespace.
+	NetworkPolicyGovernanceCondition NetworkPolicyConditionType = "Governance"
 )
 
 type NetworkPolicyReadyConditionReason string
@@ -210,6 +530,43 @@ const (
 	NetworkPolicyReady      NetworkPolicyReadyConditionReason = "Ready"
 	NetworkPolicyEmptyRules NetworkPolicyReadyConditionReason = "EmptyRules"
 	NetworkPolicyFailed     NetworkPolicyReadyConditionReason = "Failed"
+	// NetworkPolicySizeLimitExceeded is set when the generated network policy exceeds the size limit of its kind, or
+	// the network policy exceeds the limits of the OperatorConfig. The network policy generated before is kept rather
+	// than deleted, as deleting it would also drop the deny-all of its policy types.
+	NetworkPolicySizeLimitExceeded NetworkPolicyReadyConditionReason = "SizeLimitExceeded"
+	// NetworkPolicyOutputConflict is set when the generated object is already controlled by another policy, e.g. a
+	// second cluster network policy with the BaselineAdminNetworkPolicy output kind
+	NetworkPolicyOutputConflict NetworkPolicyReadyConditionReason = "OutputConflict"
+	// NetworkPolicyUnsupportedRules is set when the network policy has rules its output kind can not render, e.g. port
+	// ranges with the EgressFirewall output kind
+	NetworkPolicyUnsupportedRules NetworkPolicyReadyConditionReason = "UnsupportedRules"
+)
+
+type NetworkPolicyNetworkAttachedConditionReason string
+
+const (
+	NetworkPolicyNADFound NetworkPolicyNetworkAttachedConditionReason = "NADFound"
+	// NetworkPolicyNADNotFound is set when the target network is missing or its NetworkAttachmentDefinition does not
+	// exist
+	NetworkPolicyNADNotFound NetworkPolicyNetworkAttachedConditionReason = "NADNotFound"
+	// NetworkPolicyNADInvalidConfig is set when the CNI configuration of the NetworkAttachmentDefinition cannot be
+	// parsed
+	NetworkPolicyNADInvalidConfig NetworkPolicyNetworkAttachedConditionReason = "NADInvalidConfig"
+	// NetworkPolicyNADMismatchedType is set when the CNI plugin of the NetworkAttachmentDefinition does not enforce
+	// MultiNetworkPolicies
+	NetworkPolicyNADMismatchedType NetworkPolicyNetworkAttachedConditionReason = "NADMismatchedType"
+)
+
+type NetworkPolicyGovernanceConditionReason string
+
+const (
+	NetworkPolicyGovernanceCompliant NetworkPolicyGovernanceConditionReason = "Compliant"
+	// NetworkPolicyFQDNsDenied is set when FQDNs breaking the FQDNGovernancePolicies were dropped from the network
+	// policy
+	NetworkPolicyFQDNsDenied NetworkPolicyGovernanceConditionReason = "FQDNsDenied"
+	// NetworkPolicySettingsIgnored is set when the resolver or the CIDRs of the network policy are ignored, as the
+	// FQDNGovernancePolicies of the namespace forbid them
+	NetworkPolicySettingsIgnored NetworkPolicyGovernanceConditionReason = "SettingsIgnored"
 )
 
 type NetworkPolicyResolvedConditionReason string
@@ -222,10 +579,14 @@ const (
 	NetworkPolicyResolveTemporaryError NetworkPolicyResolvedConditionReason = "TEMPORARY"
 	NetworkPolicyResolveUnknown        NetworkPolicyResolvedConditionReason = "UNKNOWN"
 	NetworkPolicyResolveSuccess        NetworkPolicyResolvedConditionReason = "SUCCESS"
+	// NetworkPolicyResolveCNAMENotAllowed is set when the CNAME chain of an FQDN leaves the allowed CNAME suffixes
+	NetworkPolicyResolveCNAMENotAllowed NetworkPolicyResolvedConditionReason = "CNAME_NOT_ALLOWED"
 )
 
 func (r NetworkPolicyResolvedConditionReason) Priority() int {
 	switch r {
+	case NetworkPolicyResolveCNAMENotAllowed:
+		return 7
 	case NetworkPolicyResolveOtherError:
 		return 6
 	case NetworkPolicyResolveInvalidDomain:
@@ -249,6 +610,8 @@ func (r NetworkPolicyResolvedConditionReason) Transient() bool {
 		return false
 	case NetworkPolicyResolveDomainNotFound:
 		return false
+	case NetworkPolicyResolveCNAMENotAllowed:
+		return false
 	default:
 		return true
 	}
@@ -258,6 +621,10 @@ func (r NetworkPolicyResolvedConditionReason) Transient() bool {
 type FQDNStatus struct {
 	// FQDN is the FQDN this status refers to
 	FQDN FQDN `json:"fqdn"`
+	// Pattern is the wildcard pattern the FQDN was discovered from. Empty for FQDNs listed in ToFQDNs.
+	Pattern FQDNPattern `json:"pattern,omitempty"`
+	// LastDiscoveredTime is the last time the FQDN was a known FQDN of its pattern or returned by the name source. Only set for FQDNs discovered from a pattern. The FQDN is dropped once it was not discovered within the discovered name retention of the operator.
+	LastDiscoveredTime metav1.Time `json:"lastDiscoveredTime,omitempty"`
 	// LastSuccessfulTime is the last time the FQDN was resolved successfully. I.e. the last time the ResolveReason was NetworkPolicyResolveSuccess
 	LastSuccessfulTime metav1.Time `json:"LastSuccessfulTime,omitempty"`
 	// LastTransitionTime is the last time the reason changed
@@ -266,8 +633,46 @@ type FQDNStatus struct {
 	ResolveReason NetworkPolicyResolvedConditionReason `json:"resolvedReason,omitempty"`
 	// ResolveMessage is a message describing the reason for the status
 	ResolveMessage string `json:"resolveMessage,omitempty"`
-	// Addresses is the list of resolved addresses for the given FQDN. The list is cleared if LastSuccessfulTime exceeds the time limit specified by NetworkPolicySpec.RetryTimeoutSeconds
+	// Addresses is the list of resolved addresses for the given FQDN, the addresses of AddressRecords. The list is cleared if LastSuccessfulTime exceeds the time limit specified by NetworkPolicySpec.RetryTimeoutSeconds
 	Addresses []string `json:"addresses,omitempty"`
+	// AddressRecords lists the resolved addresses of the FQDN with the time they were first and last returned by a lookup. The list is cleared if LastSuccessfulTime exceeds the time limit specified by NetworkPolicySpec.RetryTimeoutSeconds
+	AddressRecords []AddressRecord `json:"addressRecords,omitempty"`
+	// CNAMEs is the chain of canonical names followed from the FQDN by the last lookup, in order. It ends at the first name outside of NetworkPolicySpec.AllowedCNAMESuffixes if the chain left them.
+	// +optional
+	CNAMEs []string `json:"cnames,omitempty"`
+	// NextRefreshTime is the time at which the FQDN is resolved again. It follows the TTL of the DNS records, bounded by the minimum and maximum refresh intervals of the operator.
+	NextRefreshTime metav1.Time `json:"nextRefreshTime,omitempty"`
+}
+
+// AddressRecord is a resolved address of an FQDN
+type AddressRecord struct {
+	// Address is the resolved address in CIDR notation
+	Address string `json:"address"`
+	// FirstSeen is the time since which the address has been applied without interruption
+	FirstSeen metav1.Time `json:"firstSeen,omitempty"`
+	// LastSeen is the last time a lookup of the FQDN returned the address
+	LastSeen metav1.Time `json:"lastSeen"`
+	// Source is the name whose address record returned the address: the FQDN itself, or the last name of its CNAME chain
+	// at the time. The chain is recorded once on the FQDN.
+	Source FQDN `json:"source,omitempty"`
+}
+
+// SRVStatus defines the status of the SRV records of a name
+type SRVStatus struct {
+	// Name is the SRV name this status refers to
+	Name SRVName `json:"name"`
+	// LastSuccessfulTime is the last time the SRV records were resolved successfully
+	LastSuccessfulTime metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// LastTransitionTime is the last time the reason changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// ResolveReason describes the last resolve status
+	ResolveReason NetworkPolicyResolvedConditionReason `json:"resolvedReason,omitempty"`
+	// ResolveMessage is a message describing the reason for the status
+	ResolveMessage string `json:"resolveMessage,omitempty"`
+	// Targets lists the hosts and ports of the SRV records. The hosts are resolved like FQDNs and reported in the FQDN statuses. The list is cleared if LastSuccessfulTime exceeds the time limit specified by NetworkPolicySpec.RetryTimeoutSeconds
+	Targets []SRVTarget `json:"targets,omitempty"`
+	// NextRefreshTime is the time at which the SRV records are resolved again
+	NextRefreshTime metav1.Time `json:"nextRefreshTime,omitempty"`
 }
 
 // NetworkPolicyStatus defines the observed state of NetworkPolicy.
@@ -281,9 +686,15 @@ type NetworkPolicyStatus struct {
 	// FQDNs lists the status of each FQDN in the network policy
 	FQDNs []FQDNStatus `json:"fqdns,omitempty"`
 
+	// SRVs lists the status of each SRV name in the network policy
+	SRVs []SRVStatus `json:"srvs,omitempty"`
+
 	// AppliedAddressCount counts the number of unique IPs applied in the generated network policy
 	AppliedAddressCount int32 `json:"appliedAddressCount,omitempty"`
 
+	// AppliedOutputKind is the output kind of the network policy generated by the last reconciliation. When the output kind changes, the generated network policy of this kind is removed.
+	AppliedOutputKind OutputKind `json:"appliedOutputKind,omitempty"`
+
 	// TotalAddressCount is the number of total IPs resolved from the FQDNs before filtering
 	TotalAddressCount int32 `json:"totalAddressesCount,omitempty"`
 
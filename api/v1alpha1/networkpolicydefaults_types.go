@@ -0,0 +1,104 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkPolicyDefaultsName is the name of the only NetworkPolicyDefaults read in a namespace
+const NetworkPolicyDefaultsName = "default"
+
+const (
+	// DefaultEnabledNetworkType is the EnabledNetworkType of network policies without defaults
+	DefaultEnabledNetworkType = IPv4
+	// DefaultRetryTimeoutSeconds is the RetryTimeoutSeconds of network policies without defaults
+	DefaultRetryTimeoutSeconds int32 = 3600
+	// DefaultTTLSeconds is the TTLSeconds of network policies without defaults
+	DefaultTTLSeconds int32 = 60
+)
+
+// NetworkPolicyDefaultsSpec defines the defaults of the FQDN network policies in the namespace. The defaults are
+// applied when a network policy is created or updated without the corresponding field, network policies that were
+// already created keep their values.
+type NetworkPolicyDefaultsSpec struct {
+	// EnabledNetworkType defines which type of IP addresses to allow.
+	//
+	//  - Options are one of: 'all', 'ipv4', 'ipv6'
+	//
+	// +kubebuilder:validation:Optional
+	EnabledNetworkType NetworkType `json:"enabledNetworkType,omitempty"`
+
+	// How long the resolving of an individual FQDN should be retried in case of errors before being removed from the underlying network policy.
+	//
+	//  - Maximum value is 86400 (24 hours)
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Maximum=86400
+	RetryTimeoutSeconds int32 `json:"retryTimeoutSeconds,omitempty"`
+
+	// The interval at which the IP addresses of the FQDNs are re-evaluated when the DNS answer does not provide a TTL.
+	//
+	//  - Maximum value is 1800 seconds
+	//  - Minimum value is 5 seconds
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=5
+	// +kubebuilder:validation:Maximum=1800
+	TTLSeconds int32 `json:"ttlSeconds,omitempty"`
+
+	// When set to true, all private IPs are omitted from the rules unless otherwise specified at the EgressRule level.
+	//
+	// +kubebuilder:validation:Optional
+	BlockPrivateIPs *bool `json:"blockPrivateIPs,omitempty"`
+
+	// Resolver defines the nameservers used to resolve the FQDNs of the network policies.
+	//
+	//  - Rejected in namespaces whose FQDNGovernancePolicies forbid custom resolvers, the cluster resolver must be used there
+	//
+	// +kubebuilder:validation:Optional
+	Resolver *ResolverConfig `json:"resolver,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NetworkPolicyDefaults is the Schema for the networkpolicydefaults API. It supplies the defaults of the FQDN network policies in its namespace. Only the NetworkPolicyDefaults named 'default' is read.
+//
+// +kubebuilder:resource:path=networkpolicydefaults,singular=networkpolicydefaults,scope=Namespaced,shortName={fenpd,fnpd}
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="the network policy defaults must be named default"
+// +kubebuilder:printcolumn:name="Network Type",type=string,JSONPath=`.spec.enabledNetworkType`,description="Default enabled network type"
+// +kubebuilder:printcolumn:name="Block Private IPs",type=boolean,JSONPath=`.spec.blockPrivateIPs`,description="Default for blocking private IPs"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type NetworkPolicyDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NetworkPolicyDefaultsSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NetworkPolicyDefaultsList contains a list of NetworkPolicyDefaults.
+type NetworkPolicyDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkPolicyDefaults `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkPolicyDefaults{}, &NetworkPolicyDefaultsList{})
+}
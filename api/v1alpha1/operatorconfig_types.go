@@ -0,0 +1,127 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperatorConfigName is the name of the only OperatorConfig read by the operator
+const OperatorConfigName = "cluster"
+
+// OperatorConfigSpec defines the operator-wide defaults and limits. Unset fields fall back to the command line flags of
+// the operator.
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.minTTLSeconds) || !has(self.maxTTLSeconds) || self.maxTTLSeconds == 0 || self.minTTLSeconds <= self.maxTTLSeconds",message="minTTLSeconds must not be greater than maxTTLSeconds"
+type OperatorConfigSpec struct {
+	// Resolver defines the nameservers used to resolve the FQDNs of network policies without their own resolver configuration.
+	//
+	//  - Defaults to the resolver given by the command line flags of the operator if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XValidation:rule="!has(self.tls) || !has(self.tls.caSecretRef)",message="tls.caSecretRef is not supported by the operator configuration"
+	Resolver *ResolverConfig `json:"resolver,omitempty"`
+
+	// MinTTLSeconds is the lower bound for re-resolving an FQDN, regardless of the TTL of its DNS records.
+	//
+	//  - Defaults to the --min-refresh-interval of the operator if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	MinTTLSeconds int32 `json:"minTTLSeconds,omitempty"`
+
+	// MaxTTLSeconds is the upper bound for re-resolving an FQDN, regardless of the TTL of its DNS records.
+	//
+	//  - Defaults to the --max-refresh-interval of the operator if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	MaxTTLSeconds int32 `json:"maxTTLSeconds,omitempty"`
+
+	// MaxFQDNsPerPolicy limits the number of FQDNs of a network policy, including the FQDNs expanded from its wildcard patterns and the targets of its SRV names. Network policies exceeding the limit are not resolved and their Ready condition reports SizeLimitExceeded. The network policy applied before is kept and the policy is not requeued until it or the OperatorConfig changes.
+	//
+	//  - Defaults to no limit if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxFQDNsPerPolicy int32 `json:"maxFQDNsPerPolicy,omitempty"`
+
+	// MaxAddressesPerPolicy limits the number of addresses applied by a network policy. Network policies exceeding the limit are not applied and their Ready condition reports SizeLimitExceeded. The network policy applied before is kept and the policy is not requeued until it or the OperatorConfig changes.
+	//
+	//  - Defaults to no limit if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxAddressesPerPolicy int32 `json:"maxAddressesPerPolicy,omitempty"`
+
+	// MaxConcurrentReconciles is the number of network policies reconciled in parallel.
+	//
+	//  - Defaults to 5 if not specified
+	//  - Maximum value is 50
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=50
+	// +kubebuilder:default:=5
+	MaxConcurrentReconciles int32 `json:"maxConcurrentReconciles,omitempty"`
+
+	// DefaultOutputKind is the kind of network policy generated from FQDN network policies that do not specify an output kind.
+	//
+	//  - Defaults to 'MultiNetworkPolicy' if not specified
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=MultiNetworkPolicy
+	DefaultOutputKind OutputKind `json:"defaultOutputKind,omitempty"`
+}
+
+// OperatorConfigStatus defines the observed state of OperatorConfig.
+type OperatorConfigStatus struct {
+	// ObservedGeneration is the generation of the configuration applied by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// OperatorConfig is the Schema for the operatorconfigs API. It holds the defaults and limits of the operator, changes are applied without restarting the operator. Only the OperatorConfig named 'cluster' is read.
+//
+// +kubebuilder:resource:path=operatorconfigs,singular=operatorconfig,scope=Cluster
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'cluster'",message="the operator configuration must be named cluster"
+// +kubebuilder:printcolumn:name="Output Kind",type=string,JSONPath=`.spec.defaultOutputKind`,description="Default output kind"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type OperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OperatorConfigSpec   `json:"spec,omitempty"`
+	Status OperatorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OperatorConfigList contains a list of OperatorConfig.
+type OperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{}, &OperatorConfigList{})
+}
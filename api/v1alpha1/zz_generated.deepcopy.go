@@ -21,11 +21,44 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	netx "net"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressRecord) DeepCopyInto(out *AddressRecord) {
+	*out = *in
+	in.FirstSeen.DeepCopyInto(&out.FirstSeen)
+	in.LastSeen.DeepCopyInto(&out.LastSeen)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressRecord.
+func (in *AddressRecord) DeepCopy() *AddressRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressRetention) DeepCopyInto(out *AddressRetention) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressRetention.
+func (in *AddressRetention) DeepCopy() *AddressRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CIDR) DeepCopyInto(out *CIDR) {
 	*out = *in
@@ -71,6 +104,156 @@ func (in CIDRList) DeepCopy() CIDRList {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CIDRPeer) DeepCopyInto(out *CIDRPeer) {
+	*out = *in
+	if in.Except != nil {
+		in, out := &in.Except, &out.Except
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CIDRPeer.
+func (in *CIDRPeer) DeepCopy() *CIDRPeer {
+	if in == nil {
+		return nil
+	}
+	out := new(CIDRPeer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterEgressRule) DeepCopyInto(out *ClusterEgressRule) {
+	*out = *in
+	in.EgressRule.DeepCopyInto(&out.EgressRule)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterEgressRule.
+func (in *ClusterEgressRule) DeepCopy() *ClusterEgressRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterEgressRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkPolicy) DeepCopyInto(out *ClusterNetworkPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkPolicy.
+func (in *ClusterNetworkPolicy) DeepCopy() *ClusterNetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterNetworkPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkPolicyList) DeepCopyInto(out *ClusterNetworkPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterNetworkPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkPolicyList.
+func (in *ClusterNetworkPolicyList) DeepCopy() *ClusterNetworkPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNetworkPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterNetworkPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkPolicySpec) DeepCopyInto(out *ClusterNetworkPolicySpec) {
+	*out = *in
+	if in.Priority != nil {
+		in, out := &in.Priority, &out.Priority
+		*out = new(int32)
+		**out = **in
+	}
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make([]MatchLabel, len(*in))
+		copy(*out, *in)
+	}
+	if in.MatchExpressions != nil {
+		in, out := &in.MatchExpressions, &out.MatchExpressions
+		*out = make([]LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Egresses != nil {
+		in, out := &in.Egresses, &out.Egresses
+		*out = make([]ClusterEgressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Resolver != nil {
+		in, out := &in.Resolver, &out.Resolver
+		*out = new(ResolverConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AddressRetention != nil {
+		in, out := &in.AddressRetention, &out.AddressRetention
+		*out = new(AddressRetention)
+		**out = **in
+	}
+	if in.AllowedCNAMESuffixes != nil {
+		in, out := &in.AllowedCNAMESuffixes, &out.AllowedCNAMESuffixes
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkPolicySpec.
+func (in *ClusterNetworkPolicySpec) DeepCopy() *ClusterNetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNetworkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EgressRule) DeepCopyInto(out *EgressRule) {
 	*out = *in
@@ -79,10 +262,34 @@ func (in *EgressRule) DeepCopyInto(out *EgressRule) {
 		*out = make([]FQDN, len(*in))
 		copy(*out, *in)
 	}
+	if in.ToFQDNPatterns != nil {
+		in, out := &in.ToFQDNPatterns, &out.ToFQDNPatterns
+		*out = make([]FQDNPattern, len(*in))
+		copy(*out, *in)
+	}
+	if in.KnownFQDNs != nil {
+		in, out := &in.KnownFQDNs, &out.KnownFQDNs
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
+	if in.ToCIDRs != nil {
+		in, out := &in.ToCIDRs, &out.ToCIDRs
+		*out = make([]CIDRPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ToSRV != nil {
+		in, out := &in.ToSRV, &out.ToSRV
+		*out = make([]SRVName, len(*in))
+		copy(*out, *in)
+	}
 	if in.Ports != nil {
 		in, out := &in.Ports, &out.Ports
 		*out = make([]MultiNetworkPolicyPort, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.BlockPrivateIPs != nil {
 		in, out := &in.BlockPrivateIPs, &out.BlockPrivateIPs
@@ -101,9 +308,104 @@ func (in *EgressRule) DeepCopy() *EgressRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FQDNGovernancePolicy) DeepCopyInto(out *FQDNGovernancePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FQDNGovernancePolicy.
+func (in *FQDNGovernancePolicy) DeepCopy() *FQDNGovernancePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(FQDNGovernancePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FQDNGovernancePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FQDNGovernancePolicyList) DeepCopyInto(out *FQDNGovernancePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FQDNGovernancePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FQDNGovernancePolicyList.
+func (in *FQDNGovernancePolicyList) DeepCopy() *FQDNGovernancePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(FQDNGovernancePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FQDNGovernancePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FQDNGovernancePolicySpec) DeepCopyInto(out *FQDNGovernancePolicySpec) {
+	*out = *in
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.AllowedSuffixes != nil {
+		in, out := &in.AllowedSuffixes, &out.AllowedSuffixes
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedRegexes != nil {
+		in, out := &in.AllowedRegexes, &out.AllowedRegexes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedSuffixes != nil {
+		in, out := &in.DeniedSuffixes, &out.DeniedSuffixes
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedRegexes != nil {
+		in, out := &in.DeniedRegexes, &out.DeniedRegexes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FQDNGovernancePolicySpec.
+func (in *FQDNGovernancePolicySpec) DeepCopy() *FQDNGovernancePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FQDNGovernancePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FQDNStatus) DeepCopyInto(out *FQDNStatus) {
 	*out = *in
+	in.LastDiscoveredTime.DeepCopyInto(&out.LastDiscoveredTime)
 	in.LastSuccessfulTime.DeepCopyInto(&out.LastSuccessfulTime)
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 	if in.Addresses != nil {
@@ -111,6 +413,19 @@ func (in *FQDNStatus) DeepCopyInto(out *FQDNStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AddressRecords != nil {
+		in, out := &in.AddressRecords, &out.AddressRecords
+		*out = make([]AddressRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CNAMEs != nil {
+		in, out := &in.CNAMEs, &out.CNAMEs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.NextRefreshTime.DeepCopyInto(&out.NextRefreshTime)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FQDNStatus.
@@ -144,6 +459,38 @@ func (in FQDNStatusList) DeepCopy() FQDNStatusList {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressRule) DeepCopyInto(out *IngressRule) {
+	*out = *in
+	if in.FromFQDNs != nil {
+		in, out := &in.FromFQDNs, &out.FromFQDNs
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]MultiNetworkPolicyPort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BlockPrivateIPs != nil {
+		in, out := &in.BlockPrivateIPs, &out.BlockPrivateIPs
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressRule.
+func (in *IngressRule) DeepCopy() *IngressRule {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LabelSelectorRequirement) DeepCopyInto(out *LabelSelectorRequirement) {
 	*out = *in
@@ -182,6 +529,11 @@ func (in *MatchLabel) DeepCopy() *MatchLabel {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MultiNetworkPolicyPort) DeepCopyInto(out *MultiNetworkPolicyPort) {
 	*out = *in
+	if in.EndPort != nil {
+		in, out := &in.EndPort, &out.EndPort
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiNetworkPolicyPort.
@@ -221,6 +573,89 @@ func (in *NetworkPolicy) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyDefaults) DeepCopyInto(out *NetworkPolicyDefaults) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyDefaults.
+func (in *NetworkPolicyDefaults) DeepCopy() *NetworkPolicyDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NetworkPolicyDefaults) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyDefaultsList) DeepCopyInto(out *NetworkPolicyDefaultsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NetworkPolicyDefaults, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyDefaultsList.
+func (in *NetworkPolicyDefaultsList) DeepCopy() *NetworkPolicyDefaultsList {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyDefaultsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NetworkPolicyDefaultsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyDefaultsSpec) DeepCopyInto(out *NetworkPolicyDefaultsSpec) {
+	*out = *in
+	if in.BlockPrivateIPs != nil {
+		in, out := &in.BlockPrivateIPs, &out.BlockPrivateIPs
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Resolver != nil {
+		in, out := &in.Resolver, &out.Resolver
+		*out = new(ResolverConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyDefaultsSpec.
+func (in *NetworkPolicyDefaultsSpec) DeepCopy() *NetworkPolicyDefaultsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyDefaultsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyList) DeepCopyInto(out *NetworkPolicyList) {
 	*out = *in
@@ -256,6 +691,11 @@ func (in *NetworkPolicyList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicySpec) DeepCopyInto(out *NetworkPolicySpec) {
 	*out = *in
+	if in.TargetNetworks != nil {
+		in, out := &in.TargetNetworks, &out.TargetNetworks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.MatchLabels != nil {
 		in, out := &in.MatchLabels, &out.MatchLabels
 		*out = make([]MatchLabel, len(*in))
@@ -275,6 +715,33 @@ func (in *NetworkPolicySpec) DeepCopyInto(out *NetworkPolicySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Ingresses != nil {
+		in, out := &in.Ingresses, &out.Ingresses
+		*out = make([]IngressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BlockPrivateIPs != nil {
+		in, out := &in.BlockPrivateIPs, &out.BlockPrivateIPs
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Resolver != nil {
+		in, out := &in.Resolver, &out.Resolver
+		*out = new(ResolverConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AddressRetention != nil {
+		in, out := &in.AddressRetention, &out.AddressRetention
+		*out = new(AddressRetention)
+		**out = **in
+	}
+	if in.AllowedCNAMESuffixes != nil {
+		in, out := &in.AllowedCNAMESuffixes, &out.AllowedCNAMESuffixes
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicySpec.
@@ -298,9 +765,16 @@ func (in *NetworkPolicyStatus) DeepCopyInto(out *NetworkPolicyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SRVs != nil {
+		in, out := &in.SRVs, &out.SRVs
+		*out = make([]SRVStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -316,3 +790,206 @@ func (in *NetworkPolicyStatus) DeepCopy() *NetworkPolicyStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfig.
+func (in *OperatorConfig) DeepCopy() *OperatorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigList) DeepCopyInto(out *OperatorConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OperatorConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigList.
+func (in *OperatorConfigList) DeepCopy() *OperatorConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigSpec) DeepCopyInto(out *OperatorConfigSpec) {
+	*out = *in
+	if in.Resolver != nil {
+		in, out := &in.Resolver, &out.Resolver
+		*out = new(ResolverConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigSpec.
+func (in *OperatorConfigSpec) DeepCopy() *OperatorConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigStatus) DeepCopyInto(out *OperatorConfigStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigStatus.
+func (in *OperatorConfigStatus) DeepCopy() *OperatorConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolverConfig) DeepCopyInto(out *ResolverConfig) {
+	*out = *in
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(ResolverTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Search != nil {
+		in, out := &in.Search, &out.Search
+		*out = make([]FQDN, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolverConfig.
+func (in *ResolverConfig) DeepCopy() *ResolverConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolverConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolverTLSConfig) DeepCopyInto(out *ResolverTLSConfig) {
+	*out = *in
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolverTLSConfig.
+func (in *ResolverTLSConfig) DeepCopy() *ResolverTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolverTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SRVStatus) DeepCopyInto(out *SRVStatus) {
+	*out = *in
+	in.LastSuccessfulTime.DeepCopyInto(&out.LastSuccessfulTime)
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]SRVTarget, len(*in))
+		copy(*out, *in)
+	}
+	in.NextRefreshTime.DeepCopyInto(&out.NextRefreshTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SRVStatus.
+func (in *SRVStatus) DeepCopy() *SRVStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SRVStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in SRVStatusList) DeepCopyInto(out *SRVStatusList) {
+	{
+		in := &in
+		*out = make(SRVStatusList, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SRVStatusList.
+func (in SRVStatusList) DeepCopy() SRVStatusList {
+	if in == nil {
+		return nil
+	}
+	out := new(SRVStatusList)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SRVTarget) DeepCopyInto(out *SRVTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SRVTarget.
+func (in *SRVTarget) DeepCopy() *SRVTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(SRVTarget)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -17,14 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"go.uber.org/automaxprocs/maxprocs"
-	
+
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -38,6 +42,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -45,7 +50,9 @@ import (
 	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
 	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
 	"github.com/mransonwang/fqdn-egress-operator/internal/controller"
+	webhookv1alpha1 "github.com/mransonwang/fqdn-egress-operator/internal/webhook/v1alpha1"
 	"github.com/mransonwang/fqdn-egress-operator/pkg/network"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
 	klog "k8s.io/klog/v2"
 	// +kubebuilder:scaffold:imports
 )
@@ -74,6 +81,17 @@ func main() {
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
 	var maxConcurrentResolves int
+	var observedNameRetention time.Duration
+	var nameObserverAddr string
+	var minRefreshInterval, maxRefreshInterval time.Duration
+	var dnsCacheNegativeTTL time.Duration
+	var dnsNameservers, dnsSearch, dnsProtocol string
+	var dnsTLSServerName, dnsCAFile, dnsPath string
+	var dnsPort, dnsNDots int
+	var multiNetworkPlugins string
+	var tracingEndpoint string
+	var tracingInsecure bool
+	var tracingSamplingRatio float64
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -93,6 +111,52 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.IntVar(&maxConcurrentResolves, "max-concurrent-resolves", 0,
 		"How many goroutines can be spawned to resolve FQDNs to IP addresses.")
+	flag.DurationVar(&observedNameRetention, "observed-name-retention", 24*time.Hour,
+		"How long domain names seen in CNAME chains or reported by a DNS observer are kept "+
+			"to expand wildcard FQDN patterns, and how long an expanded FQDN stays in a network policy "+
+			"after it was last seen.")
+	flag.StringVar(&nameObserverAddr, "name-observer-bind-address", "0",
+		"The address the endpoint receiving the domain names seen by an external DNS observer binds to, "+
+			"e.g. :8082. The endpoint is unauthenticated: it is served via HTTP and any client reaching it can "+
+			"add names, so restrict access to it with a network policy. Disabled by default, use 0 to disable it.")
+	flag.DurationVar(&minRefreshInterval, "min-refresh-interval", 5*time.Second,
+		"The minimum interval between two lookups of the same FQDN, even if its DNS records have a shorter TTL.")
+	flag.DurationVar(&maxRefreshInterval, "max-refresh-interval", 30*time.Minute,
+		"The maximum interval between two lookups of the same FQDN, even if its DNS records have a longer TTL. "+
+			"Use 0 to follow the TTL of the records without an upper bound.")
+	flag.DurationVar(&dnsCacheNegativeTTL, "dns-cache-negative-ttl", 30*time.Second,
+		"How long the shared DNS cache remembers that an FQDN does not exist. Use 0 to disable negative caching.")
+	flag.StringVar(&dnsNameservers, "dns-nameservers", "",
+		"Comma separated IP addresses of the nameservers used for network policies without resolver configuration. "+
+			"Leave empty to use the nameservers of /etc/resolv.conf.")
+	flag.IntVar(&dnsPort, "dns-port", 0, "The port of the nameservers given by --dns-nameservers. "+
+		"Defaults to the well-known port of the protocol: 53 for UDP and TCP, 853 for TLS and 443 for HTTPS.")
+	flag.StringVar(&dnsProtocol, "dns-protocol", string(networkingv1alpha1.DNSProtocolUDP),
+		"The protocol used to query the nameservers given by --dns-nameservers, one of UDP, TCP, "+
+			"TLS (DNS-over-TLS) or HTTPS (DNS-over-HTTPS).")
+	flag.StringVar(&dnsTLSServerName, "dns-tls-server-name", "",
+		"The server name used to verify the certificates of TLS and HTTPS nameservers. "+
+			"Defaults to the address of the nameserver.")
+	flag.StringVar(&dnsCAFile, "dns-ca-file", "",
+		"The PEM encoded CA bundle used to verify the certificates of TLS and HTTPS nameservers. "+
+			"Defaults to the system roots.")
+	flag.StringVar(&dnsPath, "dns-path", "",
+		"The URL path of HTTPS nameservers. Defaults to /dns-query.")
+	flag.StringVar(&dnsSearch, "dns-search", "",
+		"Comma separated search domains for the nameservers given by --dns-nameservers.")
+	flag.IntVar(&dnsNDots, "dns-ndots", 1,
+		"The number of dots an FQDN must contain to be resolved as-is before the search domains are tried.")
+	flag.StringVar(&multiNetworkPlugins, "multi-network-plugins", "",
+		"Comma separated CNI plugin types enforcing MultiNetworkPolicies, e.g. ovn-k8s-cni-overlay,macvlan. "+
+			"Target networks using another plugin are reported in the NetworkAttached condition. "+
+			"Leave empty to accept any plugin.")
+	flag.StringVar(&tracingEndpoint, "tracing-endpoint", "",
+		"The host:port of the OpenTelemetry collector receiving the traces over OTLP gRPC. "+
+			"Leave empty to disable tracing.")
+	flag.BoolVar(&tracingInsecure, "tracing-insecure", false,
+		"If set, the connection to the OpenTelemetry collector does not use TLS.")
+	flag.Float64Var(&tracingSamplingRatio, "tracing-sampling-ratio", 1,
+		"The fraction of the reconciliations that are traced, between 0 and 1.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -108,7 +172,7 @@ func main() {
 	}))
 	if err != nil {
 		setupLog.Error(err, "unable to set GOMAXPROCS")
-	}	
+	}
 
 	if maxConcurrentResolves <= 0 {
 		maxConcurrentResolves = min(max(1, goruntime.GOMAXPROCS(0)), 20)
@@ -227,16 +291,114 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := (&controller.NetworkPolicyReconciler{
-		Client:                mgr.GetClient(),
-		Scheme:                mgr.GetScheme(),
-		EventRecorder:         mgr.GetEventRecorderFor("fqdn-egress-controller"),
-		DNSResolver:           network.NewDNSResolver(),
-		MaxConcurrentResolves: maxConcurrentResolves,
+	// Names seen while resolving or reported by an external DNS observer are used to expand wildcard FQDN patterns.
+	// The observer endpoint has its own server, so it does not depend on the metrics server being enabled.
+	nameObserver := network.NewNameObserver(observedNameRetention)
+	if nameObserverAddr != "0" {
+		mux := http.NewServeMux()
+		mux.Handle("/observed-names", nameObserver)
+		if err := mgr.Add(&manager.Server{
+			Name:   "name-observer",
+			Server: &http.Server{Addr: nameObserverAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		}); err != nil {
+			setupLog.Error(err, "unable to add name observer server")
+			os.Exit(1)
+		}
+	}
+
+	// All network policies share one DNS cache, so FQDNs listed in many policies are only resolved once per TTL
+	dnsCache := network.NewCache(network.CacheOptions{
+		DefaultTTL:  minRefreshInterval,
+		MaxTTL:      maxRefreshInterval,
+		NegativeTTL: dnsCacheNegativeTTL,
+	})
+
+	// The operator-wide resolver applies to all network policies without their own resolver configuration
+	var defaultDNSConfig *network.ClientConfig
+	if dnsNameservers != "" {
+		spec := &networkingv1alpha1.ResolverConfig{
+			Port:     int32(dnsPort),
+			Protocol: networkingv1alpha1.DNSProtocol(dnsProtocol),
+			NDots:    int32(dnsNDots),
+			Path:     dnsPath,
+			TLS:      &networkingv1alpha1.ResolverTLSConfig{ServerName: dnsTLSServerName},
+		}
+		for _, server := range strings.Split(dnsNameservers, ",") {
+			spec.Nameservers = append(spec.Nameservers, strings.TrimSpace(server))
+		}
+		for _, domain := range strings.Split(dnsSearch, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				spec.Search = append(spec.Search, networkingv1alpha1.FQDN(domain))
+			}
+		}
+		config := network.ClientConfigFromSpec(spec)
+		if dnsCAFile != "" {
+			if config.CABundle, err = os.ReadFile(dnsCAFile); err != nil {
+				setupLog.Error(err, "unable to read the CA bundle of the nameservers")
+				os.Exit(1)
+			}
+		}
+		defaultDNSConfig = &config
+	}
+	dnsResolvers := network.NewDNSResolverPool(dnsCache, nameObserver, defaultDNSConfig)
+
+	// The OperatorConfig overrides the defaults and limits given by the flags without restarting the operator
+	settings := controller.NewOperatorSettings()
+	if err := (&controller.OperatorConfigReconciler{
+		Client:   mgr.GetClient(),
+		Settings: settings,
 	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OperatorConfig")
+		os.Exit(1)
+	}
+
+	networkPolicyReconciler := &controller.NetworkPolicyReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("fqdn-egress-controller"),
+		DNSResolver:   dnsResolvers.Default(),
+		APIReader:     mgr.GetAPIReader(),
+		DNSResolverFor: func(config network.ClientConfig) controller.DNSResolver {
+			return dnsResolvers.ForConfig(config)
+		},
+		NameSource:              nameObserver,
+		MaxConcurrentResolves:   maxConcurrentResolves,
+		DiscoveredNameRetention: observedNameRetention,
+		MinRefreshInterval:      minRefreshInterval,
+		MaxRefreshInterval:      maxRefreshInterval,
+		Settings:                settings,
+	}
+	if multiNetworkPlugins != "" {
+		networkPolicyReconciler.MultiNetworkPlugins = strings.Split(multiNetworkPlugins, ",")
+	}
+	if err := networkPolicyReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkPolicy")
 		os.Exit(1)
 	}
+	if err := (&controller.ClusterNetworkPolicyReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("fqdn-egress-controller"),
+		FQDNResolver:  networkPolicyReconciler,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterNetworkPolicy")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1alpha1.SetupNetworkPolicyWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NetworkPolicy")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupNetworkPolicyDefaultsWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NetworkPolicyDefaults")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupFQDNGovernancePolicyWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "FQDNGovernancePolicy")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -264,9 +426,33 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx := ctrl.SetupSignalHandler()
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Endpoint:      tracingEndpoint,
+		Insecure:      tracingInsecure,
+		SamplingRatio: tracingSamplingRatio,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	// The API reader is used as the cache of the manager is not started yet
+	if err := settings.Load(ctx, mgr.GetAPIReader()); err != nil {
+		setupLog.Error(err, "unable to load the operator configuration")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager", "maxConcurrentResolves", maxConcurrentResolves)
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	// Flush the spans of the last reconciliations
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		setupLog.Error(err, "unable to flush the traces")
+	}
 }
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"maps"
+
+	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Backend renders FQDN network policies into the policy objects enforced by a network plugin
+type Backend interface {
+	// Apply creates or updates the objects enforcing the FQDN network policy, using the addresses resolved in its
+	// status
+	Apply(ctx context.Context, np *v1alpha1.NetworkPolicy) error
+	// Delete removes the objects created by Apply for the FQDN network policy. Objects that do not exist or are not
+	// controlled by the FQDN network policy are ignored.
+	Delete(ctx context.Context, np *v1alpha1.NetworkPolicy) error
+}
+
+// NewBackends returns the built-in backends by output kind. settings provide the default output kind of the network
+// policies merged by the EgressFirewall backend, and may be nil. enqueue requests the reconciliation of the other
+// network policies merged into a shared object when it changed, and may be nil.
+func NewBackends(
+	c client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, settings *OperatorSettings,
+	enqueue func(ctx context.Context, nps ...*v1alpha1.NetworkPolicy),
+) map[v1alpha1.OutputKind]Backend {
+	writer := objectWriter{client: c, scheme: scheme, recorder: recorder}
+	return map[v1alpha1.OutputKind]Backend{
+		v1alpha1.OutputKindMultiNetworkPolicy:  &multiNetworkPolicyBackend{writer: writer},
+		v1alpha1.OutputKindNetworkPolicy:       &networkPolicyBackend{writer: writer},
+		v1alpha1.OutputKindCiliumNetworkPolicy: &ciliumNetworkPolicyBackend{writer: writer},
+		v1alpha1.OutputKindEgressFirewall: &egressFirewallBackend{
+			writer: writer, limit: maxEgressFirewallRules, settings: settings, enqueue: enqueue,
+		},
+	}
+}
+
+// namespaceBackend is implemented by backends merging the FQDN network policies of a namespace into shared objects
+type namespaceBackend interface {
+	// Refresh rebuilds the shared objects of the namespace, e.g. after one of its FQDN network policies was removed
+	Refresh(ctx context.Context, namespace string) error
+}
+
+// renderMultiNetworkPolicy converts the FQDN network policy to a MultiNetworkPolicy with the resolved addresses
+// in its status, merging rules with the same ports. Returns nil if no Egress rules are specified.
+func renderMultiNetworkPolicy(np *v1alpha1.NetworkPolicy) *mnetv1beta1.MultiNetworkPolicy {
+	networkPolicy := np.ToMultiNetworkPolicy(np.Status.FQDNs, np.Status.SRVs)
+	utils.RemoveDuplicateCidrsInNetworkPolicy(networkPolicy)
+	return networkPolicy
+}
+
+// multiNetworkPolicyBackend renders k8s.cni.cncf.io/v1beta1 MultiNetworkPolicies
+type multiNetworkPolicyBackend struct {
+	writer objectWriter
+}
+
+func (b *multiNetworkPolicyBackend) Apply(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	desired := renderMultiNetworkPolicy(np)
+	current := &mnetv1beta1.MultiNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: np.Name, Namespace: np.Namespace}}
+	return b.writer.createOrUpdate(ctx, np, current, desired, func() error {
+		if !equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+			current.Spec = *desired.Spec.DeepCopy()
+		}
+		return ctrl.SetControllerReference(np, current, b.writer.scheme)
+	})
+}
+
+func (b *multiNetworkPolicyBackend) Delete(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	return b.writer.deleteOwned(ctx, np, &mnetv1beta1.MultiNetworkPolicy{})
+}
+
+// objectWriter writes the objects of the backends and records events on the FQDN network policy
+type objectWriter struct {
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+}
+
+// createOrUpdate creates or updates the current object with the metadata of the desired object. mutate sets the spec
+// and the owner references of the current object. Events are recorded on the owner.
+func (w objectWriter) createOrUpdate(
+	ctx context.Context, owner client.Object, current, desired client.Object, mutate func() error,
+) error {
+	_, err := w.createOrUpdateResult(ctx, owner, current, desired, mutate)
+	return err
+}
+
+// createOrUpdateResult is createOrUpdate returning whether the object was created, updated or left unchanged
+func (w objectWriter) createOrUpdateResult(
+	ctx context.Context, owner client.Object, current, desired client.Object, mutate func() error,
+) (controllerutil.OperationResult, error) {
+	ctx, span := tracer.Start(ctx, utils.Kind(desired)+".CreateOrUpdate", trace.WithAttributes(
+		semconv.K8SNamespaceName(current.GetNamespace()), tracing.PolicyNameKey.String(owner.GetName()),
+	))
+	defer span.End()
+
+	op, err := controllerutil.CreateOrUpdate(ctx, w.client, current, func() error {
+		if err := mutate(); err != nil {
+			return err
+		}
+
+		if !utils.MapContains(current.GetLabels(), desired.GetLabels()) {
+			current.SetLabels(desired.GetLabels())
+		}
+
+		if !utils.MapContains(current.GetAnnotations(), desired.GetAnnotations()) {
+			current.SetAnnotations(maps.Clone(desired.GetAnnotations()))
+		}
+		return nil
+	})
+	span.SetAttributes(tracing.OperationKey.String(string(op)))
+	recordSpanError(span, err)
+	if err != nil {
+		w.recorder.Event(
+			owner,
+			corev1.EventTypeWarning,
+			utils.OperationErrorReason(desired),
+			err.Error(),
+		)
+		return op, err
+	}
+	if op != controllerutil.OperationResultNone {
+		w.recorder.Event(
+			owner,
+			corev1.EventTypeNormal,
+			utils.OperationReason(desired, op),
+			utils.OperationMessage(desired, op))
+	}
+	return op, nil
+}
+
+// deleteOwned removes the object if it is controlled by the owner. The object is looked up by its name or, if it has
+// no name, by the name of the owner. Kinds whose CRD is not installed are ignored.
+func (w objectWriter) deleteOwned(ctx context.Context, owner client.Object, object client.Object) error {
+	key := client.ObjectKeyFromObject(owner)
+	if object.GetName() != "" {
+		key = client.ObjectKeyFromObject(object)
+	}
+	err := w.client.Get(ctx, key, object)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(object, owner) {
+		return nil
+	}
+	if err := w.client.Delete(ctx, object); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	w.recorder.Event(
+		owner, corev1.EventTypeNormal,
+		utils.DeletionReason(object), utils.DeletionMessage(object),
+	)
+	return nil
+}
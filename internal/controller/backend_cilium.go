@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ciliumNetworkPolicyGVK is the kind written by the Cilium backend. The Cilium API is not imported, the objects are
+// written as unstructured objects.
+var ciliumNetworkPolicyGVK = schema.GroupVersionKind{Group: "cilium.io", Version: "v2", Kind: "CiliumNetworkPolicy"}
+
+// +kubebuilder:rbac:groups=cilium.io,resources=ciliumnetworkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// ciliumNetworkPolicyBackend renders cilium.io/v2 CiliumNetworkPolicies with one toCIDR rule per Egress rule and per
+// target of its SRV names
+type ciliumNetworkPolicyBackend struct {
+	writer objectWriter
+}
+
+func newCiliumNetworkPolicy() *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(ciliumNetworkPolicyGVK)
+	return object
+}
+
+func (b *ciliumNetworkPolicyBackend) Apply(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	spec, err := renderCiliumNetworkPolicySpec(np)
+	if err != nil {
+		return err
+	}
+	desired := newCiliumNetworkPolicy()
+	desired.SetLabels(np.Labels)
+	desired.SetAnnotations(np.Annotations)
+
+	current := newCiliumNetworkPolicy()
+	current.SetName(np.Name)
+	current.SetNamespace(np.Namespace)
+	return b.writer.createOrUpdate(ctx, np, current, desired, func() error {
+		if !equality.Semantic.DeepEqual(current.Object["spec"], spec) {
+			current.Object["spec"] = spec
+		}
+		return ctrl.SetControllerReference(np, current, b.writer.scheme)
+	})
+}
+
+func (b *ciliumNetworkPolicyBackend) Delete(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	return b.writer.deleteOwned(ctx, np, newCiliumNetworkPolicy())
+}
+
+// renderCiliumNetworkPolicySpec converts the FQDN network policy to the spec of a CiliumNetworkPolicy. The endpoint
+// selector is the pod selector of the network policy. Rules without any allowed address are omitted, if no rule of a
+// direction with rules is left a single empty rule keeps the selected endpoints in default deny for that direction.
+func renderCiliumNetworkPolicySpec(np *v1alpha1.NetworkPolicy) (map[string]interface{}, error) {
+	podSelector := np.PodSelector()
+	selector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&podSelector)
+	if err != nil {
+		return nil, err
+	}
+	spec := map[string]interface{}{"endpointSelector": selector}
+
+	if len(np.Spec.Egresses) > 0 {
+		egress := []interface{}{}
+		for _, rule := range np.Spec.Egresses {
+			addresses := rule.Addresses(np.Status.FQDNs, np.BlockPrivateIPs())
+			if egressRule := renderCiliumRule("toCIDR", addresses, rule.Ports); egressRule != nil {
+				egress = append(egress, egressRule)
+			}
+			for _, srvRule := range rule.SRVEgressRules(np.Status.FQDNs, np.Status.SRVs, np.BlockPrivateIPs()) {
+				egress = append(egress, renderCiliumSRVRule(srvRule))
+			}
+		}
+		if len(egress) == 0 {
+			egress = append(egress, map[string]interface{}{})
+		}
+		spec["egress"] = egress
+	}
+
+	if len(np.Spec.Ingresses) > 0 {
+		ingress := []interface{}{}
+		for _, rule := range np.Spec.Ingresses {
+			addresses := rule.Addresses(np.Status.FQDNs, np.BlockPrivateIPs())
+			if ingressRule := renderCiliumRule("fromCIDR", addresses, rule.Ports); ingressRule != nil {
+				ingress = append(ingress, ingressRule)
+			}
+		}
+		if len(ingress) == 0 {
+			ingress = append(ingress, map[string]interface{}{})
+		}
+		spec["ingress"] = ingress
+	}
+	return spec, nil
+}
+
+// renderCiliumRule returns a rule allowing the addresses in the field given by cidrField on the ports, or nil if
+// there are no addresses
+func renderCiliumRule(
+	cidrField string, addresses []string, ports []v1alpha1.MultiNetworkPolicyPort,
+) map[string]interface{} {
+	if len(addresses) == 0 {
+		return nil
+	}
+	cidrs := make([]interface{}, 0, len(addresses))
+	for _, address := range addresses {
+		cidrs = append(cidrs, address)
+	}
+	rule := map[string]interface{}{cidrField: cidrs}
+
+	renderedPorts := make([]interface{}, 0, len(ports))
+	for _, port := range ports {
+		rendered := map[string]interface{}{
+			"port":     strconv.Itoa(int(port.Port)),
+			"protocol": string(port.Protocol),
+		}
+		if endPort := port.RangeEnd(); endPort != nil {
+			rendered["endPort"] = int64(*endPort)
+		}
+		renderedPorts = append(renderedPorts, rendered)
+	}
+	if len(renderedPorts) > 0 {
+		rule["toPorts"] = []interface{}{map[string]interface{}{"ports": renderedPorts}}
+	}
+	return rule
+}
+
+// renderCiliumSRVRule returns a rule allowing the addresses of the rendered rule of an SRV target on its port
+func renderCiliumSRVRule(rule mnetv1beta1.MultiNetworkPolicyEgressRule) map[string]interface{} {
+	addresses := make([]string, 0, len(rule.To))
+	for _, peer := range rule.To {
+		addresses = append(addresses, peer.IPBlock.CIDR)
+	}
+	ports := make([]v1alpha1.MultiNetworkPolicyPort, 0, len(rule.Ports))
+	for _, port := range rule.Ports {
+		ports = append(ports, v1alpha1.MultiNetworkPolicyPort{Protocol: *port.Protocol, Port: port.Port.IntVal})
+	}
+	return renderCiliumRule("toCIDR", addresses, ports)
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var _ = Describe("CiliumNetworkPolicy Backend", func() {
+	var np *networkingv1alpha1.NetworkPolicy
+	var backend Backend
+
+	BeforeEach(func() {
+		np = &networkingv1alpha1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "cilium-backend", Namespace: "default"},
+			Spec: networkingv1alpha1.NetworkPolicySpec{
+				OutputKind: networkingv1alpha1.OutputKindCiliumNetworkPolicy,
+				MatchLabels: []networkingv1alpha1.MatchLabel{
+					{Label: networkingv1alpha1.LabelWithKubernetesAppName, Value: "web"},
+				},
+				Egresses: []networkingv1alpha1.EgressRule{{
+					ToFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+					Ports:   []networkingv1alpha1.MultiNetworkPolicyPort{{Protocol: "TCP", Port: 443}},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, np)).To(Succeed())
+		np.Status.FQDNs = []networkingv1alpha1.FQDNStatus{{
+			FQDN:           "www.example.com",
+			ResolveReason:  networkingv1alpha1.NetworkPolicyResolveSuccess,
+			AddressRecords: addressRecords("192.0.2.2/32", "192.0.2.1/32"),
+		}}
+		backend = NewBackends(k8sClient, k8sClient.Scheme(), record.NewFakeRecorder(10), nil, nil)[np.Spec.OutputKind]
+	})
+
+	AfterEach(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, np))).To(Succeed())
+	})
+
+	It("should write toCIDR and toPorts rules from the resolved addresses", func() {
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+
+		cnp := newCiliumNetworkPolicy()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), cnp)).To(Succeed())
+		Expect(metav1.IsControlledBy(cnp, np)).To(BeTrue())
+
+		egress, found, err := unstructured.NestedSlice(cnp.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(egress).To(Equal([]interface{}{map[string]interface{}{
+			"toCIDR": []interface{}{"192.0.2.1/32", "192.0.2.2/32"},
+			"toPorts": []interface{}{map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": "443", "protocol": "TCP"}},
+			}},
+		}}))
+		labels, _, err := unstructured.NestedStringMap(cnp.Object, "spec", "endpointSelector", "matchLabels")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(labels).To(Equal(map[string]string{"app.kubernetes.io/name": "web"}))
+	})
+
+	It("should select endpoints by arbitrary label keys", func() {
+		np.Spec.MatchLabels = []networkingv1alpha1.MatchLabel{{Label: "team", Value: "payments"}}
+		np.Spec.MatchExpressions = []networkingv1alpha1.LabelSelectorRequirement{
+			{Key: "example.com/tier", Operator: metav1.LabelSelectorOpExists},
+		}
+		Expect(k8sClient.Update(ctx, np)).To(Succeed())
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+
+		cnp := newCiliumNetworkPolicy()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), cnp)).To(Succeed())
+		selector, _, err := unstructured.NestedMap(cnp.Object, "spec", "endpointSelector")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selector).To(Equal(map[string]interface{}{
+			"matchLabels": map[string]interface{}{"team": "payments"},
+			"matchExpressions": []interface{}{
+				map[string]interface{}{"key": "example.com/tier", "operator": "Exists"},
+			},
+		}))
+	})
+
+	It("should write fromCIDR rules for Ingress rules", func() {
+		np.Spec.Egresses = nil
+		np.Spec.Ingresses = []networkingv1alpha1.IngressRule{{
+			FromFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+			Ports:     []networkingv1alpha1.MultiNetworkPolicyPort{{Protocol: "TCP", Port: 8443}},
+		}}
+		Expect(k8sClient.Update(ctx, np)).To(Succeed())
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+
+		cnp := newCiliumNetworkPolicy()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), cnp)).To(Succeed())
+		_, found, err := unstructured.NestedSlice(cnp.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+		ingress, _, err := unstructured.NestedSlice(cnp.Object, "spec", "ingress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ingress).To(Equal([]interface{}{map[string]interface{}{
+			"fromCIDR": []interface{}{"192.0.2.1/32", "192.0.2.2/32"},
+			"toPorts": []interface{}{map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": "8443", "protocol": "TCP"}},
+			}},
+		}}))
+	})
+
+	It("should write port ranges with endPort", func() {
+		np.Spec.Egresses[0].Ports = []networkingv1alpha1.MultiNetworkPolicyPort{
+			{Protocol: "UDP", Port: 10000, EndPort: ptr.To[int32](20000)},
+		}
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+
+		cnp := newCiliumNetworkPolicy()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), cnp)).To(Succeed())
+		egress, _, err := unstructured.NestedSlice(cnp.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(egress).To(HaveLen(1))
+		Expect(egress[0]).To(HaveKeyWithValue("toPorts", []interface{}{map[string]interface{}{
+			"ports": []interface{}{map[string]interface{}{"port": "10000", "endPort": int64(20000), "protocol": "UDP"}},
+		}}))
+	})
+
+	It("should write a rule per SRV target on the port of its record", func() {
+		np.Spec.Egresses[0].ToSRV = []networkingv1alpha1.SRVName{"_ldap._udp.corp.example.com"}
+		np.Status.FQDNs = append(np.Status.FQDNs, networkingv1alpha1.FQDNStatus{
+			FQDN:           "dc1.corp.example.com",
+			ResolveReason:  networkingv1alpha1.NetworkPolicyResolveSuccess,
+			AddressRecords: addressRecords("198.51.100.1/32"),
+		})
+		np.Status.SRVs = []networkingv1alpha1.SRVStatus{{
+			Name:          "_ldap._udp.corp.example.com",
+			ResolveReason: networkingv1alpha1.NetworkPolicyResolveSuccess,
+			Targets: []networkingv1alpha1.SRVTarget{
+				{Target: "dc1.corp.example.com", Port: 389},
+				{Target: "dc2.corp.example.com", Port: 389},
+			},
+		}}
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+
+		cnp := newCiliumNetworkPolicy()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), cnp)).To(Succeed())
+		egress, _, err := unstructured.NestedSlice(cnp.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(egress).To(HaveLen(2))
+		Expect(egress[1]).To(Equal(map[string]interface{}{
+			"toCIDR": []interface{}{"198.51.100.1/32"},
+			"toPorts": []interface{}{map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": "389", "protocol": "UDP"}},
+			}},
+		}))
+	})
+
+	It("should keep the selected endpoints in default deny without addresses", func() {
+		np.Status.FQDNs = nil
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+
+		cnp := newCiliumNetworkPolicy()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), cnp)).To(Succeed())
+		egress, _, err := unstructured.NestedSlice(cnp.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(egress).To(Equal([]interface{}{map[string]interface{}{}}))
+	})
+
+	It("should delete the CiliumNetworkPolicy", func() {
+		Expect(backend.Apply(ctx, np)).To(Succeed())
+		Expect(backend.Delete(ctx, np)).To(Succeed())
+
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(np), newCiliumNetworkPolicy())
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,393 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// egressFirewallGVK is the kind written by the EgressFirewall backend. The OVN-Kubernetes API is not imported, the
+// objects are written as unstructured objects.
+var egressFirewallGVK = schema.GroupVersionKind{Group: "k8s.ovn.org", Version: "v1", Kind: "EgressFirewall"}
+
+const (
+	// egressFirewallName is the only name OVN-Kubernetes accepts for the EgressFirewall of a namespace
+	egressFirewallName = "default"
+	// maxEgressFirewallRules is the maximum number of rules of an EgressFirewall enforced by its CRD
+	maxEgressFirewallRules = 8000
+	// managedByLabel marks objects written by the operator that are shared by several FQDN network policies
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "fqdn-egress-operator"
+)
+
+// +kubebuilder:rbac:groups=k8s.ovn.org,resources=egressfirewalls,verbs=get;list;watch;create;update;patch;delete
+
+// SizeLimitError is returned by backends when the generated object exceeds the size limit of its kind
+type SizeLimitError struct {
+	Kind string
+	// Items names what is limited, e.g. "rules"
+	Items string
+	Size  int
+	Limit int
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("the generated %s has %d %s, exceeding the limit of %d", e.Kind, e.Size, e.Items, e.Limit)
+}
+
+// UnsupportedRulesError is returned by backends when the FQDN network policy has rules its output kind can not render.
+// The CRD rejects them if the output kind is set, but not if the policy falls back to the default output kind.
+type UnsupportedRulesError struct {
+	Kind string
+	// Policy is the name of the FQDN network policy with the unsupported rules
+	Policy string
+	// Rules describes the unsupported rules, e.g. "port ranges"
+	Rules string
+}
+
+func (e *UnsupportedRulesError) Error() string {
+	return fmt.Sprintf("the network policy %q has %s, which are not supported by %ss", e.Policy, e.Rules, e.Kind)
+}
+
+// egressFirewallBackend merges all FQDN network policies of a namespace with the EgressFirewall output kind into the
+// EgressFirewall of the namespace. The FQDN network policies own the EgressFirewall, it is garbage collected when
+// the last of them is removed.
+//
+// The rules of the other FQDN network policies are rendered from their cached status, which may lag behind their
+// latest reconciliation. Whenever the EgressFirewall changes, the other FQDN network policies are enqueued, so each of
+// them renders the EgressFirewall again from its own latest status and reports its own Ready condition.
+type egressFirewallBackend struct {
+	writer objectWriter
+	// limit is the maximum number of rules of the merged EgressFirewall
+	limit int
+	// settings provide the default output kind of the FQDN network policies
+	settings *OperatorSettings
+	// enqueue requests the reconciliation of FQDN network policies, may be nil
+	enqueue func(ctx context.Context, nps ...*v1alpha1.NetworkPolicy)
+}
+
+func newEgressFirewall() *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(egressFirewallGVK)
+	return object
+}
+
+func (b *egressFirewallBackend) Apply(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	sources, err := b.sources(ctx, np.Namespace, np, false)
+	if err != nil {
+		return err
+	}
+	return b.apply(ctx, np.Namespace, np, sources)
+}
+
+// Delete removes the rules of the FQDN network policy from the EgressFirewall of its namespace. The EgressFirewall
+// is removed if no other FQDN network policy contributes to it.
+func (b *egressFirewallBackend) Delete(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	current := newEgressFirewall()
+	err := b.writer.client.Get(ctx, client.ObjectKey{Name: egressFirewallName, Namespace: np.Namespace}, current)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !ownedBy(current, np) {
+		return nil
+	}
+	sources, err := b.sources(ctx, np.Namespace, np, true)
+	if err != nil {
+		return err
+	}
+	return b.apply(ctx, np.Namespace, np, sources)
+}
+
+// Refresh rebuilds the EgressFirewall of the namespace from the FQDN network policies left in the namespace, e.g.
+// after one of them was removed
+func (b *egressFirewallBackend) Refresh(ctx context.Context, namespace string) error {
+	sources, err := b.sources(ctx, namespace, nil, false)
+	if err != nil {
+		return err
+	}
+	return b.apply(ctx, namespace, nil, sources)
+}
+
+// sources returns the FQDN network policies of the namespace merged into the EgressFirewall, sorted by name. The
+// stored version of np is replaced by np, which holds the latest resolved addresses, or dropped if exclude is true.
+// The settings that the governance forbids are dropped from the stored versions, like the reconciliation does for np.
+func (b *egressFirewallBackend) sources(
+	ctx context.Context, namespace string, np *v1alpha1.NetworkPolicy, exclude bool,
+) ([]*v1alpha1.NetworkPolicy, error) {
+	list := &v1alpha1.NetworkPolicyList{}
+	if err := b.writer.client.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	governance, err := utils.GetFQDNGovernance(ctx, b.writer.client, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defaultKind := b.settings.Spec().DefaultOutputKind
+	var sources []*v1alpha1.NetworkPolicy
+	for i := range list.Items {
+		item := &list.Items[i]
+		if np != nil && item.Name == np.Name {
+			continue
+		}
+		// Policies with unsupported rules report the error in their own status and do not block the others
+		if item.OutputKindOrDefault(defaultKind) == v1alpha1.OutputKindEgressFirewall && item.DeletionTimestamp.IsZero() &&
+			checkEgressFirewallRules(item) == nil {
+			governance.Govern(item)
+			sources = append(sources, item)
+		}
+	}
+	if np != nil && !exclude {
+		sources = append(sources, np)
+	}
+	slices.SortFunc(sources, func(a, c *v1alpha1.NetworkPolicy) int {
+		return cmp.Compare(a.Name, c.Name)
+	})
+	return sources, nil
+}
+
+// apply writes the EgressFirewall of the namespace merged from the sources. Events are recorded on np, the FQDN
+// network policy being reconciled, or on the first source if np is nil. The EgressFirewall is removed if there are
+// no sources.
+func (b *egressFirewallBackend) apply(
+	ctx context.Context, namespace string, np *v1alpha1.NetworkPolicy, sources []*v1alpha1.NetworkPolicy,
+) error {
+	if len(sources) == 0 {
+		return b.deleteEgressFirewall(ctx, np, namespace)
+	}
+	eventTarget := np
+	if eventTarget == nil {
+		eventTarget = sources[0]
+	}
+
+	rules, err := renderEgressFirewallRules(sources)
+	if err != nil {
+		return err
+	}
+	limit := b.limit
+	if limit <= 0 {
+		limit = maxEgressFirewallRules
+	}
+	if len(rules) > limit {
+		sizeErr := &SizeLimitError{Kind: egressFirewallGVK.Kind, Items: "rules", Size: len(rules), Limit: limit}
+		b.writer.recorder.Event(
+			eventTarget, corev1.EventTypeWarning, utils.OperationErrorReason(newEgressFirewall()), sizeErr.Error(),
+		)
+		// The other sources report the size limit in their own status when they are reconciled
+		b.enqueueOthers(ctx, np, sources, func(source *v1alpha1.NetworkPolicy) bool {
+			ready := meta.FindStatusCondition(source.Status.Conditions, string(v1alpha1.NetworkPolicyReadyCondition))
+			return ready == nil || ready.Reason != string(v1alpha1.NetworkPolicySizeLimitExceeded)
+		})
+		return sizeErr
+	}
+
+	desired := newEgressFirewall()
+	desired.SetLabels(map[string]string{managedByLabel: managedByValue})
+	current := newEgressFirewall()
+	current.SetName(egressFirewallName)
+	current.SetNamespace(namespace)
+	spec := map[string]interface{}{"egress": rules}
+
+	op, err := b.writer.createOrUpdateResult(ctx, eventTarget, current, desired, func() error {
+		if current.GetResourceVersion() != "" && current.GetLabels()[managedByLabel] != managedByValue {
+			return fmt.Errorf(
+				"the EgressFirewall %q of namespace %q is not managed by the operator", egressFirewallName, namespace,
+			)
+		}
+		if !equality.Semantic.DeepEqual(current.Object["spec"], spec) {
+			current.Object["spec"] = spec
+		}
+		return b.setOwnerReferences(current, sources)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		b.enqueueOthers(ctx, np, sources, func(*v1alpha1.NetworkPolicy) bool { return true })
+	}
+	return nil
+}
+
+// enqueueOthers requests the reconciliation of the sources other than np that match the filter
+func (b *egressFirewallBackend) enqueueOthers(
+	ctx context.Context, np *v1alpha1.NetworkPolicy, sources []*v1alpha1.NetworkPolicy,
+	filter func(*v1alpha1.NetworkPolicy) bool,
+) {
+	if b.enqueue == nil {
+		return
+	}
+	var others []*v1alpha1.NetworkPolicy
+	for _, source := range sources {
+		if (np == nil || source.Name != np.Name) && filter(source) {
+			others = append(others, source)
+		}
+	}
+	b.enqueue(ctx, others...)
+}
+
+// setOwnerReferences makes exactly the sources owners of the EgressFirewall, owner references to other kinds are kept
+func (b *egressFirewallBackend) setOwnerReferences(current client.Object, sources []*v1alpha1.NetworkPolicy) error {
+	gvk, err := b.writer.client.GroupVersionKindFor(&v1alpha1.NetworkPolicy{})
+	if err != nil {
+		return err
+	}
+	references := slices.DeleteFunc(current.GetOwnerReferences(), func(reference metav1.OwnerReference) bool {
+		return reference.APIVersion == gvk.GroupVersion().String() && reference.Kind == gvk.Kind
+	})
+	current.SetOwnerReferences(references)
+	for _, source := range sources {
+		if err := controllerutil.SetOwnerReference(source, current, b.writer.scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteEgressFirewall removes the EgressFirewall of the namespace if it is managed by the operator. Events are
+// recorded on np if not nil.
+func (b *egressFirewallBackend) deleteEgressFirewall(
+	ctx context.Context, np *v1alpha1.NetworkPolicy, namespace string,
+) error {
+	current := newEgressFirewall()
+	err := b.writer.client.Get(ctx, client.ObjectKey{Name: egressFirewallName, Namespace: namespace}, current)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.GetLabels()[managedByLabel] != managedByValue {
+		return nil
+	}
+	if err := b.writer.client.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if np != nil {
+		b.writer.recorder.Event(
+			np, corev1.EventTypeNormal,
+			utils.DeletionReason(current), utils.DeletionMessage(current),
+		)
+	}
+	return nil
+}
+
+// ownedBy returns true if the object has an owner reference to the FQDN network policy
+func ownedBy(object client.Object, np *v1alpha1.NetworkPolicy) bool {
+	for _, reference := range object.GetOwnerReferences() {
+		if reference.UID == np.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// renderEgressFirewallRules merges the Egress rules of the sources into EgressFirewall rules. Addresses allowed on
+// the same ports are grouped and sorted the same way as RemoveDuplicateCidrsInNetworkPolicy does, so the order of
+// the rules only depends on the addresses and ports. EgressFirewall rules are evaluated in order and allow all
+// traffic not matched by a rule, so the allow rules are followed by rules denying all other destinations. An
+// UnsupportedRulesError is returned if a source has port ranges or Ingress rules.
+func renderEgressFirewallRules(sources []*v1alpha1.NetworkPolicy) ([]interface{}, error) {
+	merged := &mnetv1beta1.MultiNetworkPolicy{}
+	for _, source := range sources {
+		if err := checkEgressFirewallRules(source); err != nil {
+			return nil, err
+		}
+		if networkPolicy := renderMultiNetworkPolicy(source); networkPolicy != nil {
+			merged.Spec.Egress = append(merged.Spec.Egress, networkPolicy.Spec.Egress...)
+		}
+	}
+	utils.RemoveDuplicateCidrsInNetworkPolicy(merged)
+
+	rules := []interface{}{}
+	for _, egressRule := range merged.Spec.Egress {
+		ports := make([]interface{}, 0, len(egressRule.Ports))
+		for _, port := range sortedPorts(egressRule.Ports) {
+			rendered := map[string]interface{}{"protocol": string(corev1.ProtocolTCP)}
+			if port.Protocol != nil {
+				rendered["protocol"] = string(*port.Protocol)
+			}
+			if port.Port != nil {
+				rendered["port"] = int64(port.Port.IntValue())
+			}
+			ports = append(ports, rendered)
+		}
+		for _, peer := range egressRule.To {
+			if peer.IPBlock == nil {
+				continue
+			}
+			// EgressFirewalls do not support except blocks, the allowed range is split around them
+			for _, cidr := range v1alpha1.ExcludeCIDRs(peer.IPBlock.CIDR, peer.IPBlock.Except) {
+				rule := map[string]interface{}{
+					"type": "Allow",
+					"to":   map[string]interface{}{"cidrSelector": cidr},
+				}
+				if len(ports) > 0 {
+					rule["ports"] = ports
+				}
+				rules = append(rules, rule)
+			}
+		}
+	}
+	for _, cidr := range []string{"0.0.0.0/0", "::/0"} {
+		rules = append(rules, map[string]interface{}{
+			"type": "Deny",
+			"to":   map[string]interface{}{"cidrSelector": cidr},
+		})
+	}
+	return rules, nil
+}
+
+// checkEgressFirewallRules returns an UnsupportedRulesError if the FQDN network policy has rules that EgressFirewalls
+// can not express: port ranges, which would be narrowed to their first port, and Ingress rules, which would be dropped
+func checkEgressFirewallRules(np *v1alpha1.NetworkPolicy) error {
+	if len(np.Spec.Ingresses) > 0 {
+		return &UnsupportedRulesError{Kind: egressFirewallGVK.Kind, Policy: np.Name, Rules: "ingress rules"}
+	}
+	for _, rule := range np.Spec.Egresses {
+		for i := range rule.Ports {
+			if rule.Ports[i].RangeEnd() != nil {
+				return &UnsupportedRulesError{Kind: egressFirewallGVK.Kind, Policy: np.Name, Rules: "port ranges"}
+			}
+		}
+	}
+	return nil
+}
+
+// sortedPorts returns the ports sorted by protocol and port
+func sortedPorts(ports []mnetv1beta1.MultiNetworkPolicyPort) []mnetv1beta1.MultiNetworkPolicyPort {
+	sorted := slices.Clone(ports)
+	slices.SortFunc(sorted, func(a, c mnetv1beta1.MultiNetworkPolicyPort) int {
+		var protocolA, protocolC corev1.Protocol
+		if a.Protocol != nil {
+			protocolA = *a.Protocol
+		}
+		if c.Protocol != nil {
+			protocolC = *c.Protocol
+		}
+		var portA, portC int
+		if a.Port != nil {
+			portA = a.Port.IntValue()
+		}
+		if c.Port != nil {
+			portC = c.Port.IntValue()
+		}
+		return cmp.Or(cmp.Compare(protocolA, protocolC), cmp.Compare(portA, portC))
+	})
+	return sorted
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var _ = Describe("EgressFirewall Backend", func() {
+	var first, second *networkingv1alpha1.NetworkPolicy
+	var backend *egressFirewallBackend
+
+	newSource := func(name string, fqdn networkingv1alpha1.FQDN, port int32, addresses ...string) *networkingv1alpha1.NetworkPolicy {
+		np := &networkingv1alpha1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: networkingv1alpha1.NetworkPolicySpec{
+				OutputKind: networkingv1alpha1.OutputKindEgressFirewall,
+				Egresses: []networkingv1alpha1.EgressRule{{
+					ToFQDNs: []networkingv1alpha1.FQDN{fqdn},
+					Ports:   []networkingv1alpha1.MultiNetworkPolicyPort{{Protocol: "TCP", Port: port}},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, np)).To(Succeed())
+		np.Status.FQDNs = []networkingv1alpha1.FQDNStatus{{
+			FQDN:           fqdn,
+			ResolveReason:  networkingv1alpha1.NetworkPolicyResolveSuccess,
+			AddressRecords: addressRecords(addresses...),
+		}}
+		np.SetReadyConditionTrue(networkingv1alpha1.NetworkPolicyReady, "The network policy is ready.")
+		Expect(k8sClient.Status().Update(ctx, np)).To(Succeed())
+		return np
+	}
+
+	getRules := func() []interface{} {
+		firewall := newEgressFirewall()
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: egressFirewallName, Namespace: "default"}, firewall)).
+			To(Succeed())
+		rules, _, err := unstructured.NestedSlice(firewall.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		return rules
+	}
+
+	allow := func(cidr string, port int64) interface{} {
+		return map[string]interface{}{
+			"type":  "Allow",
+			"to":    map[string]interface{}{"cidrSelector": cidr},
+			"ports": []interface{}{map[string]interface{}{"protocol": "TCP", "port": port}},
+		}
+	}
+	deny := []interface{}{
+		map[string]interface{}{"type": "Deny", "to": map[string]interface{}{"cidrSelector": "0.0.0.0/0"}},
+		map[string]interface{}{"type": "Deny", "to": map[string]interface{}{"cidrSelector": "::/0"}},
+	}
+
+	BeforeEach(func() {
+		second = newSource("egress-firewall-b", "api.example.com", 8443, "192.0.2.20/32")
+		first = newSource("egress-firewall-a", "www.example.com", 443, "192.0.2.10/32", "192.0.2.1/32")
+		backend = &egressFirewallBackend{
+			writer: objectWriter{client: k8sClient, scheme: k8sClient.Scheme(), recorder: record.NewFakeRecorder(10)},
+			limit:  maxEgressFirewallRules,
+		}
+	})
+
+	AfterEach(func() {
+		for _, np := range []*networkingv1alpha1.NetworkPolicy{first, second} {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, np))).To(Succeed())
+		}
+		firewall := newEgressFirewall()
+		firewall.SetName(egressFirewallName)
+		firewall.SetNamespace("default")
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, firewall))).To(Succeed())
+	})
+
+	It("should merge the network policies of the namespace in a deterministic order", func() {
+		Expect(backend.Apply(ctx, first)).To(Succeed())
+
+		expected := append([]interface{}{
+			allow("192.0.2.1/32", 443), allow("192.0.2.10/32", 443), allow("192.0.2.20/32", 8443),
+		}, deny...)
+		Expect(getRules()).To(Equal(expected))
+
+		Expect(backend.Apply(ctx, second)).To(Succeed())
+		Expect(getRules()).To(Equal(expected))
+
+		firewall := newEgressFirewall()
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: egressFirewallName, Namespace: "default"}, firewall)).
+			To(Succeed())
+		Expect(firewall.GetOwnerReferences()).To(HaveLen(2))
+	})
+
+	It("should split static CIDRs around their except blocks", func() {
+		first.Spec.Egresses[0].ToCIDRs = []networkingv1alpha1.CIDRPeer{
+			{CIDR: "198.51.100.0/24", Except: []string{"198.51.100.0/25"}},
+		}
+		Expect(backend.Apply(ctx, first)).To(Succeed())
+
+		Expect(getRules()).To(Equal(append([]interface{}{
+			allow("192.0.2.1/32", 443), allow("192.0.2.10/32", 443), allow("198.51.100.128/25", 443),
+			allow("192.0.2.20/32", 8443),
+		}, deny...)))
+	})
+
+	It("should drop the rules of a removed network policy", func() {
+		Expect(backend.Apply(ctx, first)).To(Succeed())
+		Expect(backend.Delete(ctx, first)).To(Succeed())
+		Expect(getRules()).To(Equal(append([]interface{}{allow("192.0.2.20/32", 8443)}, deny...)))
+
+		Expect(backend.Delete(ctx, second)).To(Succeed())
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: egressFirewallName, Namespace: "default"}, newEgressFirewall())
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should reject port ranges and ingress rules and keep the EgressFirewall", func() {
+		Expect(backend.Apply(ctx, first)).To(Succeed())
+		rules := getRules()
+
+		// The CRD only rejects them if the output kind is set, not if the policy uses the default output kind
+		first.Spec.Egresses[0].Ports[0].EndPort = ptr.To[int32](8443)
+		err := backend.Apply(ctx, first)
+		var unsupportedErr *UnsupportedRulesError
+		Expect(err).To(BeAssignableToTypeOf(unsupportedErr))
+		Expect(err.Error()).To(ContainSubstring("port ranges"))
+		Expect(readyReasonForError(err)).To(Equal(networkingv1alpha1.NetworkPolicyUnsupportedRules))
+		Expect(getRules()).To(Equal(rules))
+
+		first.Spec.Egresses[0].Ports[0].EndPort = nil
+		first.Spec.Ingresses = []networkingv1alpha1.IngressRule{{
+			FromFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+		}}
+		err = backend.Apply(ctx, first)
+		Expect(err).To(BeAssignableToTypeOf(unsupportedErr))
+		Expect(err.Error()).To(ContainSubstring("ingress rules"))
+	})
+
+	It("should enqueue the other sources instead of writing their status when the size limit is exceeded", func() {
+		var enqueued []string
+		backend.enqueue = func(_ context.Context, nps ...*networkingv1alpha1.NetworkPolicy) {
+			for _, np := range nps {
+				enqueued = append(enqueued, np.Name)
+			}
+		}
+		backend.limit = 4
+		err := backend.Apply(ctx, first)
+		var sizeErr *SizeLimitError
+		Expect(err).To(BeAssignableToTypeOf(sizeErr))
+		Expect(enqueued).To(Equal([]string{second.Name}))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(second), second)).To(Succeed())
+		condition := meta.FindStatusCondition(second.Status.Conditions, string(networkingv1alpha1.NetworkPolicyReadyCondition))
+		Expect(condition.Reason).To(Equal(string(networkingv1alpha1.NetworkPolicyReady)))
+	})
+
+	It("should only enqueue the other sources when the EgressFirewall changed", func() {
+		var enqueued []string
+		backend.enqueue = func(_ context.Context, nps ...*networkingv1alpha1.NetworkPolicy) {
+			for _, np := range nps {
+				enqueued = append(enqueued, np.Name)
+			}
+		}
+		Expect(backend.Apply(ctx, first)).To(Succeed())
+		Expect(enqueued).To(Equal([]string{second.Name}))
+
+		enqueued = nil
+		Expect(backend.Apply(ctx, first)).To(Succeed())
+		Expect(enqueued).To(BeEmpty())
+	})
+})
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// networkPolicyBackend renders networking.k8s.io/v1 NetworkPolicies for the primary pod network. The rules are the
+// same as the rules of the MultiNetworkPolicy backend.
+type networkPolicyBackend struct {
+	writer objectWriter
+}
+
+func (b *networkPolicyBackend) Apply(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	desired := utils.ToNetworkPolicy(renderMultiNetworkPolicy(np))
+	current := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: np.Name, Namespace: np.Namespace}}
+	return b.writer.createOrUpdate(ctx, np, current, desired, func() error {
+		if !equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+			current.Spec = *desired.Spec.DeepCopy()
+		}
+		return ctrl.SetControllerReference(np, current, b.writer.scheme)
+	})
+}
+
+func (b *networkPolicyBackend) Delete(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	return b.writer.deleteOwned(ctx, np, &networkingv1.NetworkPolicy{})
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
+)
+
+// ClusterNetworkPolicyReconciler reconciles a ClusterNetworkPolicy object
+type ClusterNetworkPolicyReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+	// FQDNResolver resolves the FQDNs of cluster network policies with the resolver settings used for network
+	// policies
+	FQDNResolver *NetworkPolicyReconciler
+}
+
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=clusternetworkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=clusternetworkpolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=clusternetworkpolicies/finalizers,verbs=update
+// +kubebuilder:rbac:groups=policy.networking.k8s.io,resources=adminnetworkpolicies;baselineadminnetworkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile resolves the FQDNs of a ClusterNetworkPolicy and renders them into an AdminNetworkPolicy or the
+// BaselineAdminNetworkPolicy of the cluster
+func (r *ClusterNetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracer.Start(ctx, "ClusterNetworkPolicy.Reconcile", trace.WithAttributes(
+		tracing.PolicyNameKey.String(req.Name),
+	))
+	defer span.End()
+
+	release, err := r.FQDNResolver.Settings.acquire(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer release()
+
+	result, err := r.reconcile(ctx, req)
+	recordSpanError(span, err)
+	return result, err
+}
+
+// reconcile resolves the FQDNs of the cluster network policy and renders its admin network policy
+func (r *ClusterNetworkPolicyReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	cnp := &v1alpha1.ClusterNetworkPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, cnp); err != nil {
+		if apierrors.IsNotFound(err) {
+			deletePolicyMetrics(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// The FQDNs are resolved like the FQDNs of a network policy, the status is copied back afterwards
+	np := cnp.AsNetworkPolicy()
+	results, err := r.FQDNResolver.resolveFQDNs(ctx, np, cnp)
+	if err != nil {
+		np.SetReadyConditionFalse(readyReasonForError(err), err.Error())
+		if isSizeLimitError(err) {
+			logf.FromContext(ctx).Info("Size limit exceeded, will not requeue until updated", "reason", err.Error())
+			return ctrl.Result{}, r.updateStatus(ctx, cnp, np)
+		}
+		return ctrl.Result{}, errors.Join(err, r.updateStatus(ctx, cnp, np))
+	}
+	fqdnStatuses := v1alpha1.FQDNStatusList(np.Status.FQDNs)
+	np.Status.TotalAddressCount = int32(fqdnStatuses.AddressCount())
+	if len(results) > 0 {
+		np.Status.LatestLookupTime = metav1.NewTime(time.Now())
+	}
+	resolveStatus := fqdnStatuses.AggregatedResolveStatus()
+	np.SetResolveCondition(resolveStatus, fqdnStatuses.AggregatedResolveMessage())
+	requeueAfter := r.FQDNResolver.requeueAfter(np)
+
+	logger := logf.FromContext(ctx).WithValues("policy", cnp.GetName(), "status", resolveStatus)
+
+	applied, err := r.reconcileAdminNetworkPolicy(ctx, cnp, np.Status.FQDNs)
+	np.Status.AppliedAddressCount = int32(applied)
+	recordPolicyMetrics(req.NamespacedName, np)
+	trace.SpanFromContext(ctx).SetAttributes(
+		tracing.ReasonKey.String(string(resolveStatus)),
+		tracing.ResolvedAddressCountKey.Int(int(np.Status.TotalAddressCount)),
+		tracing.AddressCountKey.Int(applied),
+	)
+	var conflictErr *OutputConflictError
+	if errors.As(err, &conflictErr) {
+		// Retrying does not help, the policy is reconciled again when the conflicting object changes
+		np.SetReadyConditionFalse(v1alpha1.NetworkPolicyOutputConflict, err.Error())
+		logger.Info("Output conflicts with another cluster network policy", "reason", err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, cnp, np)
+	}
+	if isSizeLimitError(err) {
+		// Retrying does not help either, the admin network policy rendered before is kept
+		np.SetReadyConditionFalse(v1alpha1.NetworkPolicySizeLimitExceeded, err.Error())
+		logger.Info("Size limit exceeded, will not requeue until updated", "reason", err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, cnp, np)
+	}
+	if err != nil {
+		np.SetReadyConditionFalse(readyReasonForError(err), err.Error())
+		return ctrl.Result{}, errors.Join(err, r.updateStatus(ctx, cnp, np))
+	}
+
+	if applied == 0 {
+		np.SetReadyConditionTrue(
+			v1alpha1.NetworkPolicyEmptyRules,
+			"Resolved to an admin network policy without rules.",
+		)
+	} else {
+		np.SetReadyConditionTrue(v1alpha1.NetworkPolicyReady, "The cluster network policy is ready.")
+	}
+	if err := r.updateStatus(ctx, cnp, np); err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("Reconciliation succeeded", "applied", applied, "requeueAfter", requeueAfter)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// updateStatus stores the status of the network policy view as the status of the cluster network policy
+func (r *ClusterNetworkPolicyReconciler) updateStatus(
+	ctx context.Context, cnp *v1alpha1.ClusterNetworkPolicy, np *v1alpha1.NetworkPolicy,
+) error {
+	cnp.Status = np.Status
+	return r.Client.Status().Update(ctx, cnp)
+}
+
+// SetupWithManager sets up the controller with the Manager. All cluster network policies are reconciled when the
+// OperatorConfig changes. The AdminNetworkPolicies and BaselineAdminNetworkPolicies are only watched if their CRDs are
+// installed.
+func (r *ClusterNetworkPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	settings := r.FQDNResolver.Settings
+	maxConcurrentReconciles := defaultMaxConcurrentReconciles
+	if settings != nil {
+		maxConcurrentReconciles = maxConcurrentReconcileWorkers
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterNetworkPolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("fqdn-egress-operator-cluster").
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+		})
+	if settings != nil {
+		b = b.WatchesRawSource(source.Channel(
+			settings.Subscribe(), handler.EnqueueRequestsFromMapFunc(r.allClusterNetworkPolicies),
+		))
+	}
+
+	// The generated objects are watched to correct drift. Any change of the BaselineAdminNetworkPolicy reconciles all
+	// cluster network policies of that kind, so a policy blocked by an OutputConflict takes over once it is released.
+	for _, kind := range []v1alpha1.ClusterOutputKind{
+		v1alpha1.ClusterOutputKindAdminNetworkPolicy, v1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy,
+	} {
+		object := newAdminNetworkPolicy(kind)
+		gvk := object.GroupVersionKind()
+		_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var eventHandler handler.EventHandler
+		if kind == v1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy {
+			eventHandler = handler.EnqueueRequestsFromMapFunc(r.baselineClusterNetworkPolicies)
+		} else {
+			eventHandler = handler.EnqueueRequestForOwner(
+				mgr.GetScheme(), mgr.GetRESTMapper(), &v1alpha1.ClusterNetworkPolicy{}, handler.OnlyControllerOwner(),
+			)
+		}
+		b = b.Watches(object, eventHandler, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	return b.Complete(r)
+}
+
+// baselineClusterNetworkPolicies maps an event to the cluster network policies with the BaselineAdminNetworkPolicy
+// output kind
+func (r *ClusterNetworkPolicyReconciler) baselineClusterNetworkPolicies(
+	ctx context.Context, _ client.Object,
+) []reconcile.Request {
+	policies := &v1alpha1.ClusterNetworkPolicyList{}
+	if err := r.List(ctx, policies); err != nil {
+		logf.FromContext(ctx).Error(err, "unable to list the cluster network policies")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		if policies.Items[i].Spec.OutputKind == v1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+		}
+	}
+	return requests
+}
+
+// allClusterNetworkPolicies maps an event to all cluster network policies
+func (r *ClusterNetworkPolicyReconciler) allClusterNetworkPolicies(
+	ctx context.Context, _ client.Object,
+) []reconcile.Request {
+	policies := &v1alpha1.ClusterNetworkPolicyList{}
+	if err := r.List(ctx, policies); err != nil {
+		logf.FromContext(ctx).Error(err, "unable to list the cluster network policies")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(policies.Items))
+	for i := range policies.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+	}
+	return requests
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var _ = Describe("ClusterNetworkPolicy Controller", func() {
+	var cnp *networkingv1alpha1.ClusterNetworkPolicy
+	var reconciler *ClusterNetworkPolicyReconciler
+	var fqdnStatuses []networkingv1alpha1.FQDNStatus
+
+	BeforeEach(func() {
+		cnp = &networkingv1alpha1.ClusterNetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-egress"},
+			Spec: networkingv1alpha1.ClusterNetworkPolicySpec{
+				OutputKind: networkingv1alpha1.ClusterOutputKindAdminNetworkPolicy,
+				Priority:   ptr.To[int32](10),
+				NamespaceSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"kubernetes.io/metadata.name": "tenant-a"},
+				},
+				Egresses: []networkingv1alpha1.ClusterEgressRule{
+					{
+						Name:   "deny-example",
+						Action: networkingv1alpha1.RuleActionDeny,
+						EgressRule: networkingv1alpha1.EgressRule{
+							ToFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+							Ports:   []networkingv1alpha1.MultiNetworkPolicyPort{{Protocol: "TCP", Port: 443}},
+						},
+					},
+					{
+						EgressRule: networkingv1alpha1.EgressRule{
+							ToFQDNs: []networkingv1alpha1.FQDN{"www.example.org"},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cnp)).To(Succeed())
+		fqdnStatuses = []networkingv1alpha1.FQDNStatus{
+			{
+				FQDN:           "www.example.com",
+				ResolveReason:  networkingv1alpha1.NetworkPolicyResolveSuccess,
+				AddressRecords: addressRecords("192.0.2.2/32", "192.0.2.1/32"),
+			},
+			{
+				FQDN:           "www.example.org",
+				ResolveReason:  networkingv1alpha1.NetworkPolicyResolveSuccess,
+				AddressRecords: addressRecords("192.0.2.1/32"),
+			},
+		}
+		reconciler = &ClusterNetworkPolicyReconciler{
+			Client:        k8sClient,
+			Scheme:        k8sClient.Scheme(),
+			EventRecorder: record.NewFakeRecorder(10),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cnp))).To(Succeed())
+		for _, kind := range []networkingv1alpha1.ClusterOutputKind{
+			networkingv1alpha1.ClusterOutputKindAdminNetworkPolicy,
+			networkingv1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy,
+		} {
+			object := newAdminNetworkPolicy(kind)
+			object.SetName(adminNetworkPolicyName(cnp, kind))
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, object))).To(Succeed())
+		}
+	})
+
+	It("should write an AdminNetworkPolicy with the actions and priority of the rules", func() {
+		applied, err := reconciler.reconcileAdminNetworkPolicy(ctx, cnp, fqdnStatuses)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal(2))
+
+		anp := newAdminNetworkPolicy(networkingv1alpha1.ClusterOutputKindAdminNetworkPolicy)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: cnp.Name}, anp)).To(Succeed())
+		Expect(metav1.IsControlledBy(anp, cnp)).To(BeTrue())
+
+		priority, _, err := unstructured.NestedInt64(anp.Object, "spec", "priority")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(priority).To(Equal(int64(10)))
+		egress, _, err := unstructured.NestedSlice(anp.Object, "spec", "egress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(egress).To(Equal([]interface{}{
+			map[string]interface{}{
+				"name":   "deny-example",
+				"action": "Deny",
+				"to": []interface{}{map[string]interface{}{
+					"networks": []interface{}{"192.0.2.1/32", "192.0.2.2/32"},
+				}},
+				"ports": []interface{}{map[string]interface{}{
+					"portNumber": map[string]interface{}{"protocol": "TCP", "port": int64(443)},
+				}},
+			},
+			map[string]interface{}{
+				"name":   "egress-1",
+				"action": "Allow",
+				"to": []interface{}{map[string]interface{}{
+					"networks": []interface{}{"192.0.2.1/32"},
+				}},
+			},
+		}))
+		namespaces, _, err := unstructured.NestedStringMap(anp.Object, "spec", "subject", "namespaces", "matchLabels")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces).To(Equal(map[string]string{"kubernetes.io/metadata.name": "tenant-a"}))
+	})
+
+	It("should replace the AdminNetworkPolicy by the BaselineAdminNetworkPolicy", func() {
+		_, err := reconciler.reconcileAdminNetworkPolicy(ctx, cnp, fqdnStatuses)
+		Expect(err).NotTo(HaveOccurred())
+
+		cnp.Spec.OutputKind = networkingv1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy
+		_, err = reconciler.reconcileAdminNetworkPolicy(ctx, cnp, fqdnStatuses)
+		Expect(err).NotTo(HaveOccurred())
+
+		banp := newAdminNetworkPolicy(networkingv1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: baselineAdminNetworkPolicyName}, banp)).To(Succeed())
+		Expect(metav1.IsControlledBy(banp, cnp)).To(BeTrue())
+		_, found, err := unstructured.NestedFieldNoCopy(banp.Object, "spec", "priority")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+
+		err = k8sClient.Get(
+			ctx, client.ObjectKey{Name: cnp.Name},
+			newAdminNetworkPolicy(networkingv1alpha1.ClusterOutputKindAdminNetworkPolicy),
+		)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should not take over the BaselineAdminNetworkPolicy of another cluster network policy", func() {
+		other := cnp.DeepCopy()
+		other.ObjectMeta = metav1.ObjectMeta{Name: "cluster-baseline"}
+		other.Spec.OutputKind = networkingv1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy
+		Expect(k8sClient.Create(ctx, other)).To(Succeed())
+		defer func() { Expect(k8sClient.Delete(ctx, other)).To(Succeed()) }()
+		_, err := reconciler.reconcileAdminNetworkPolicy(ctx, other, fqdnStatuses)
+		Expect(err).NotTo(HaveOccurred())
+
+		cnp.Spec.OutputKind = networkingv1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy
+		_, err = reconciler.reconcileAdminNetworkPolicy(ctx, cnp, fqdnStatuses)
+		var conflictErr *OutputConflictError
+		Expect(err).To(BeAssignableToTypeOf(conflictErr))
+		Expect(readyReasonForError(err)).To(Equal(networkingv1alpha1.NetworkPolicyOutputConflict))
+
+		banp := newAdminNetworkPolicy(networkingv1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: baselineAdminNetworkPolicyName}, banp)).To(Succeed())
+		Expect(metav1.IsControlledBy(banp, other)).To(BeTrue())
+	})
+
+	It("should report a size limit error when a rule has too many networks", func() {
+		addresses := make([]string, 0, maxAdminNetworkPolicyNetworks*maxAdminNetworkPolicyPeers+1)
+		for i := range cap(addresses) {
+			addresses = append(addresses, fmt.Sprintf("10.%d.%d.1/32", i/256, i%256))
+		}
+		fqdnStatuses[0].AddressRecords = addressRecords(addresses...)
+
+		_, err := reconciler.reconcileAdminNetworkPolicy(ctx, cnp, fqdnStatuses)
+		var sizeErr *SizeLimitError
+		Expect(err).To(BeAssignableToTypeOf(sizeErr))
+	})
+})
@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The kinds written for cluster network policies. The Network Policy API is not imported, the objects are written as
+// unstructured objects.
+var (
+	adminNetworkPolicyGVK = schema.GroupVersionKind{
+		Group: "policy.networking.k8s.io", Version: "v1alpha1", Kind: "AdminNetworkPolicy",
+	}
+	baselineAdminNetworkPolicyGVK = schema.GroupVersionKind{
+		Group: "policy.networking.k8s.io", Version: "v1alpha1", Kind: "BaselineAdminNetworkPolicy",
+	}
+)
+
+const (
+	// baselineAdminNetworkPolicyName is the only name accepted for the BaselineAdminNetworkPolicy of the cluster
+	baselineAdminNetworkPolicyName = "default"
+	// maxAdminNetworkPolicyNetworks is the maximum number of networks of a peer enforced by the AdminNetworkPolicy CRD
+	maxAdminNetworkPolicyNetworks = 25
+	// maxAdminNetworkPolicyPeers is the maximum number of peers of a rule enforced by the AdminNetworkPolicy CRD
+	maxAdminNetworkPolicyPeers = 100
+)
+
+// OutputConflictError is returned when the object generated for a policy is controlled by another policy. The object
+// is left untouched.
+type OutputConflictError struct {
+	Kind  string
+	Name  string
+	Owner string
+}
+
+func (e *OutputConflictError) Error() string {
+	return fmt.Sprintf("the %s %s is already controlled by %s", e.Kind, e.Name, e.Owner)
+}
+
+func newAdminNetworkPolicy(kind v1alpha1.ClusterOutputKind) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	if kind == v1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy {
+		object.SetGroupVersionKind(baselineAdminNetworkPolicyGVK)
+	} else {
+		object.SetGroupVersionKind(adminNetworkPolicyGVK)
+	}
+	return object
+}
+
+// adminNetworkPolicyName returns the name of the object generated for the cluster network policy
+func adminNetworkPolicyName(cnp *v1alpha1.ClusterNetworkPolicy, kind v1alpha1.ClusterOutputKind) string {
+	if kind == v1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy {
+		return baselineAdminNetworkPolicyName
+	}
+	return cnp.Name
+}
+
+// reconcileAdminNetworkPolicy writes the AdminNetworkPolicy or BaselineAdminNetworkPolicy of the cluster network
+// policy using the addresses in fqdnStatuses and removes the object of the other kind. Returns the number of
+// applied addresses.
+func (r *ClusterNetworkPolicyReconciler) reconcileAdminNetworkPolicy(
+	ctx context.Context, cnp *v1alpha1.ClusterNetworkPolicy, fqdnStatuses []v1alpha1.FQDNStatus,
+) (int, error) {
+	writer := objectWriter{client: r.Client, scheme: r.Scheme, recorder: r.EventRecorder}
+	kind := cnp.Spec.OutputKind
+	if kind == "" {
+		kind = v1alpha1.ClusterOutputKindAdminNetworkPolicy
+	}
+	for _, other := range []v1alpha1.ClusterOutputKind{
+		v1alpha1.ClusterOutputKindAdminNetworkPolicy, v1alpha1.ClusterOutputKindBaselineAdminNetworkPolicy,
+	} {
+		if other == kind {
+			continue
+		}
+		object := newAdminNetworkPolicy(other)
+		object.SetName(adminNetworkPolicyName(cnp, other))
+		if err := writer.deleteOwned(ctx, cnp, object); err != nil {
+			return 0, err
+		}
+	}
+
+	spec, applied, err := renderAdminNetworkPolicySpec(cnp, kind, fqdnStatuses)
+	if err != nil {
+		return 0, err
+	}
+	desired := newAdminNetworkPolicy(kind)
+	desired.SetLabels(cnp.Labels)
+	desired.SetAnnotations(cnp.Annotations)
+
+	current := newAdminNetworkPolicy(kind)
+	current.SetName(adminNetworkPolicyName(cnp, kind))
+	if err := r.checkAdminNetworkPolicyOwner(ctx, cnp, current); err != nil {
+		return 0, err
+	}
+	err = writer.createOrUpdate(ctx, cnp, current, desired, func() error {
+		if !equality.Semantic.DeepEqual(current.Object["spec"], spec) {
+			current.Object["spec"] = spec
+		}
+		return ctrl.SetControllerReference(cnp, current, r.Scheme)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// checkAdminNetworkPolicyOwner returns an OutputConflictError if the object is controlled by another cluster network
+// policy. The BaselineAdminNetworkPolicy is a singleton, the first cluster network policy writing it keeps it until
+// it is deleted or changes its output kind.
+func (r *ClusterNetworkPolicyReconciler) checkAdminNetworkPolicyOwner(
+	ctx context.Context, cnp *v1alpha1.ClusterNetworkPolicy, object *unstructured.Unstructured,
+) error {
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(object.GroupVersionKind())
+	err := r.Get(ctx, client.ObjectKeyFromObject(object), current)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	owner := metav1.GetControllerOf(current)
+	if owner == nil || owner.UID == cnp.UID {
+		return nil
+	}
+	return &OutputConflictError{Kind: current.GetKind(), Name: current.GetName(), Owner: owner.Kind + " " + owner.Name}
+}
+
+// renderAdminNetworkPolicySpec converts the cluster network policy to the spec of an AdminNetworkPolicy or
+// BaselineAdminNetworkPolicy and returns the number of unique addresses in it. The addresses of a rule are split
+// into peers of at most maxAdminNetworkPolicyNetworks networks. Rules without any allowed address are omitted.
+func renderAdminNetworkPolicySpec(
+	cnp *v1alpha1.ClusterNetworkPolicy, kind v1alpha1.ClusterOutputKind, fqdnStatuses []v1alpha1.FQDNStatus,
+) (map[string]interface{}, int, error) {
+	subject, err := renderAdminNetworkPolicySubject(cnp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unique := make(map[string]struct{})
+	egress := []interface{}{}
+	for i, rule := range cnp.Spec.Egresses {
+		addresses := rule.Addresses(fqdnStatuses, cnp.Spec.BlockPrivateIPs)
+		if len(addresses) == 0 {
+			continue
+		}
+		if limit := maxAdminNetworkPolicyNetworks * maxAdminNetworkPolicyPeers; len(addresses) > limit {
+			return nil, 0, &SizeLimitError{
+				Kind: newAdminNetworkPolicy(kind).GetKind(), Items: "networks", Size: len(addresses), Limit: limit,
+			}
+		}
+
+		peers := []interface{}{}
+		for chunk := range slices.Chunk(addresses, maxAdminNetworkPolicyNetworks) {
+			networks := make([]interface{}, 0, len(chunk))
+			for _, address := range chunk {
+				networks = append(networks, address)
+				unique[address] = struct{}{}
+			}
+			peers = append(peers, map[string]interface{}{"networks": networks})
+		}
+
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("egress-%d", i)
+		}
+		action := rule.Action
+		if action == "" {
+			action = v1alpha1.RuleActionAllow
+		}
+		egressRule := map[string]interface{}{
+			"name":   name,
+			"action": string(action),
+			"to":     peers,
+		}
+
+		ports := make([]interface{}, 0, len(rule.Ports))
+		for _, port := range rule.Ports {
+			if endPort := port.RangeEnd(); endPort != nil {
+				ports = append(ports, map[string]interface{}{
+					"portRange": map[string]interface{}{
+						"protocol": string(port.Protocol),
+						"start":    int64(port.Port),
+						"end":      int64(*endPort),
+					},
+				})
+				continue
+			}
+			ports = append(ports, map[string]interface{}{
+				"portNumber": map[string]interface{}{
+					"protocol": string(port.Protocol),
+					"port":     int64(port.Port),
+				},
+			})
+		}
+		if len(ports) > 0 {
+			egressRule["ports"] = ports
+		}
+		egress = append(egress, egressRule)
+	}
+
+	spec := map[string]interface{}{
+		"subject": subject,
+		"egress":  egress,
+	}
+	if kind == v1alpha1.ClusterOutputKindAdminNetworkPolicy && cnp.Spec.Priority != nil {
+		spec["priority"] = int64(*cnp.Spec.Priority)
+	}
+	return spec, len(unique), nil
+}
+
+// renderAdminNetworkPolicySubject selects the namespaces of the cluster network policy or, if a pod selector is
+// specified, the matching pods in these namespaces
+func renderAdminNetworkPolicySubject(cnp *v1alpha1.ClusterNetworkPolicy) (map[string]interface{}, error) {
+	namespaceSelector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cnp.Spec.NamespaceSelector.DeepCopy())
+	if err != nil {
+		return nil, err
+	}
+	if len(cnp.Spec.MatchLabels) == 0 && len(cnp.Spec.MatchExpressions) == 0 {
+		return map[string]interface{}{"namespaces": namespaceSelector}, nil
+	}
+
+	podSelector := cnp.AsNetworkPolicy().PodSelector()
+	selector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&podSelector)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"pods": map[string]interface{}{
+			"namespaceSelector": namespaceSelector,
+			"podSelector":       selector,
+		},
+	}, nil
+}
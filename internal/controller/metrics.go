@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The metrics of the FQDN network policies are labelled by the namespace and name of the policy. The labels are
+// prefixed, so they do not collide with the namespace label of the scrape target. The namespace of cluster network
+// policies is empty.
+var (
+	policyResolvedAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fqdn_egress_policy_resolved_addresses",
+		Help: "Number of addresses resolved from the FQDNs of a network policy before filtering.",
+	}, []string{"policy_namespace", "policy_name"})
+	policyAppliedAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fqdn_egress_policy_applied_addresses",
+		Help: "Number of unique addresses applied in the network policy generated from an FQDN network policy.",
+	}, []string{"policy_namespace", "policy_name"})
+	policyRetryTimeout = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fqdn_egress_policy_retry_timeout_seconds",
+		Help: "Time after the last successful lookup at which the addresses of an FQDN are removed on transient errors.",
+	}, []string{"policy_namespace", "policy_name"})
+	fqdnAddressChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fqdn_egress_fqdn_address_changes_total",
+		Help: "Number of addresses added to or removed from the FQDNs of a network policy when they were refreshed.",
+	}, []string{"policy_namespace", "policy_name", "change"})
+	fqdnRemovedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fqdn_egress_fqdn_removed_total",
+		Help: "Number of times the addresses of an FQDN were removed after failed lookups, by resolve reason.",
+	}, []string{"policy_namespace", "policy_name", "reason"})
+
+	fqdnSecondsSinceLastSuccessDesc = prometheus.NewDesc(
+		"fqdn_egress_fqdn_seconds_since_last_success",
+		"Time since the FQDN of a network policy was last resolved successfully.",
+		[]string{"policy_namespace", "policy_name", "fqdn"}, nil,
+	)
+)
+
+// maxLastSuccessFQDNs bounds the number of FQDNs of a network policy reported by the lastSuccessCollector
+const maxLastSuccessFQDNs = 50
+
+// lastSuccessCollector reports the time since the last successful lookup of each FQDN at scrape time, so the metric
+// keeps growing between two reconciliations of a failing network policy. Only the FQDNs listed by the network policy
+// are reported, as the names discovered from its wildcard patterns are unbounded.
+type lastSuccessCollector struct {
+	mu       sync.Mutex
+	policies map[types.NamespacedName]map[v1alpha1.FQDN]time.Time
+}
+
+var lastSuccess = &lastSuccessCollector{policies: make(map[types.NamespacedName]map[v1alpha1.FQDN]time.Time)}
+
+func (c *lastSuccessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fqdnSecondsSinceLastSuccessDesc
+}
+
+func (c *lastSuccessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, fqdns := range c.policies {
+		for fqdn, lastSuccessfulTime := range fqdns {
+			ch <- prometheus.MustNewConstMetric(
+				fqdnSecondsSinceLastSuccessDesc, prometheus.GaugeValue, now.Sub(lastSuccessfulTime).Seconds(),
+				key.Namespace, key.Name, string(fqdn),
+			)
+		}
+	}
+}
+
+// set replaces the FQDNs of the network policy. At most maxLastSuccessFQDNs FQDNs are kept, those resolved least
+// recently first.
+func (c *lastSuccessCollector) set(key types.NamespacedName, statuses []v1alpha1.FQDNStatus) {
+	listed := make([]v1alpha1.FQDNStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Pattern == "" {
+			listed = append(listed, status)
+		}
+	}
+	slices.SortStableFunc(listed, func(a, b v1alpha1.FQDNStatus) int {
+		return a.LastSuccessfulTime.Compare(b.LastSuccessfulTime.Time)
+	})
+	fqdns := make(map[v1alpha1.FQDN]time.Time, min(len(listed), maxLastSuccessFQDNs))
+	for _, status := range listed[:min(len(listed), maxLastSuccessFQDNs)] {
+		fqdns[status.FQDN] = status.LastSuccessfulTime.Time
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies[key] = fqdns
+}
+
+func (c *lastSuccessCollector) delete(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.policies, key)
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		policyResolvedAddresses, policyAppliedAddresses, policyRetryTimeout,
+		fqdnAddressChangesTotal, fqdnRemovedTotal, lastSuccess,
+	)
+}
+
+// policyKey returns the namespace and name labelling the metrics of a network policy
+func policyKey(object runtime.Object) types.NamespacedName {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return types.NamespacedName{}
+	}
+	return types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+}
+
+// recordPolicyMetrics updates the metrics of a network policy from its status after a reconciliation
+func recordPolicyMetrics(key types.NamespacedName, np *v1alpha1.NetworkPolicy) {
+	policyResolvedAddresses.WithLabelValues(key.Namespace, key.Name).Set(float64(np.Status.TotalAddressCount))
+	policyAppliedAddresses.WithLabelValues(key.Namespace, key.Name).Set(float64(np.Status.AppliedAddressCount))
+	policyRetryTimeout.WithLabelValues(key.Namespace, key.Name).Set(float64(np.Spec.RetryTimeoutSeconds))
+	lastSuccess.set(key, np.Status.FQDNs)
+}
+
+// recordAddressChanges counts the addresses added and removed when an FQDN was refreshed
+func recordAddressChanges(key types.NamespacedName, previous, current []string) {
+	var added, removed int
+	for _, address := range current {
+		if !slices.Contains(previous, address) {
+			added++
+		}
+	}
+	for _, address := range previous {
+		if !slices.Contains(current, address) {
+			removed++
+		}
+	}
+	fqdnAddressChangesTotal.WithLabelValues(key.Namespace, key.Name, "added").Add(float64(added))
+	fqdnAddressChangesTotal.WithLabelValues(key.Namespace, key.Name, "removed").Add(float64(removed))
+}
+
+// deletePolicyMetrics removes the metrics of a deleted network policy
+func deletePolicyMetrics(key types.NamespacedName) {
+	labels := prometheus.Labels{"policy_namespace": key.Namespace, "policy_name": key.Name}
+	policyResolvedAddresses.Delete(labels)
+	policyAppliedAddresses.Delete(labels)
+	policyRetryTimeout.Delete(labels)
+	fqdnAddressChangesTotal.DeletePartialMatch(labels)
+	fqdnRemovedTotal.DeletePartialMatch(labels)
+	lastSuccess.delete(key)
+}
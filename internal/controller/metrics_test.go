@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var _ = Describe("Metrics", func() {
+	key := types.NamespacedName{Namespace: "metrics", Name: "egress"}
+
+	AfterEach(func() {
+		deletePolicyMetrics(key)
+	})
+
+	It("should count the addresses added and removed on refresh", func() {
+		recordAddressChanges(key, []string{"192.0.2.1/32", "192.0.2.2/32"}, []string{"192.0.2.2/32", "192.0.2.3/32"})
+		recordAddressChanges(key, []string{"192.0.2.2/32"}, []string{"192.0.2.4/32"})
+
+		Expect(testutil.ToFloat64(fqdnAddressChangesTotal.WithLabelValues(key.Namespace, key.Name, "added"))).
+			To(Equal(2.0))
+		Expect(testutil.ToFloat64(fqdnAddressChangesTotal.WithLabelValues(key.Namespace, key.Name, "removed"))).
+			To(Equal(2.0))
+	})
+
+	It("should report the address counts and remove them with the network policy", func() {
+		np := &networkingv1alpha1.NetworkPolicy{
+			Spec: networkingv1alpha1.NetworkPolicySpec{RetryTimeoutSeconds: 3600},
+			Status: networkingv1alpha1.NetworkPolicyStatus{
+				TotalAddressCount:   3,
+				AppliedAddressCount: 2,
+				FQDNs: []networkingv1alpha1.FQDNStatus{{
+					FQDN:               "www.example.com",
+					LastSuccessfulTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+				}},
+			},
+		}
+		recordPolicyMetrics(key, np)
+		Expect(testutil.ToFloat64(policyResolvedAddresses.WithLabelValues(key.Namespace, key.Name))).To(Equal(3.0))
+		Expect(testutil.ToFloat64(policyAppliedAddresses.WithLabelValues(key.Namespace, key.Name))).To(Equal(2.0))
+		Expect(lastSuccess.policies).To(HaveKey(key))
+
+		deletePolicyMetrics(key)
+		Expect(policyResolvedAddresses.DeleteLabelValues(key.Namespace, key.Name)).To(BeFalse())
+		Expect(lastSuccess.policies).NotTo(HaveKey(key))
+	})
+
+	It("should only report the listed FQDNs resolved least recently", func() {
+		now := time.Now()
+		var statuses []networkingv1alpha1.FQDNStatus
+		for i := range maxLastSuccessFQDNs + 1 {
+			statuses = append(statuses, networkingv1alpha1.FQDNStatus{
+				FQDN:               networkingv1alpha1.FQDN(fmt.Sprintf("host%d.example.com", i)),
+				LastSuccessfulTime: metav1.NewTime(now.Add(-time.Duration(i) * time.Minute)),
+			})
+		}
+		statuses = append(statuses, networkingv1alpha1.FQDNStatus{
+			FQDN: "api.example.org", Pattern: "*.example.org", LastSuccessfulTime: metav1.NewTime(now.Add(-time.Hour)),
+		})
+		lastSuccess.set(key, statuses)
+
+		Expect(lastSuccess.policies[key]).To(HaveLen(maxLastSuccessFQDNs))
+		Expect(lastSuccess.policies[key]).NotTo(HaveKey(networkingv1alpha1.FQDN("host0.example.com")))
+		Expect(lastSuccess.policies[key]).NotTo(HaveKey(networkingv1alpha1.FQDN("api.example.org")))
+	})
+})
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// caBundleTTL is how long the CA bundle of a resolver is cached before its secret is read again
+const caBundleTTL = 5 * time.Minute
+
+type caBundleKey struct {
+	namespace string
+	name      string
+	key       string
+}
+
+type caBundleEntry struct {
+	caBundle []byte
+	expires  time.Time
+}
+
+// caBundleCache caches the CA bundles read from secrets, so the secret of a resolver is read at most once per
+// caBundleTTL instead of on every reconciliation. The secrets are read with single gets rather than through an
+// informer, so the operator only needs the get permission on secrets and does not keep all secrets of the cluster in
+// memory. A rotated CA bundle is picked up within caBundleTTL.
+type caBundleCache struct {
+	mu      sync.Mutex
+	entries map[caBundleKey]caBundleEntry
+}
+
+// get returns the CA bundle stored under the key of the secret, reading the secret if the cached bundle expired
+func (c *caBundleCache) get(
+	ctx context.Context, reader client.Reader, namespace, name, key string, now time.Time,
+) ([]byte, error) {
+	cacheKey := caBundleKey{namespace: namespace, name: name, key: key}
+	c.mu.Lock()
+	entry, ok := c.entries[cacheKey]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.caBundle, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get the CA secret of the resolver: %w", err)
+	}
+	caBundle, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("the CA secret %q of the resolver has no key %q", name, key)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[caBundleKey]caBundleEntry)
+	}
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[cacheKey] = caBundleEntry{caBundle: caBundle, expires: now.Add(caBundleTTL)}
+	return caBundle, nil
+}
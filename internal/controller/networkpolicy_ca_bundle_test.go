@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// countingReader counts the gets of the wrapped reader
+type countingReader struct {
+	client.Reader
+	gets int
+}
+
+func (r *countingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	r.gets++
+	return r.Reader.Get(ctx, key, obj, opts...)
+}
+
+var _ = Describe("CA bundle cache", func() {
+	var secret *corev1.Secret
+	var reader *countingReader
+
+	BeforeEach(func() {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "resolver-ca", Namespace: "default"},
+			Data:       map[string][]byte{"ca.crt": []byte("first")},
+		}
+		reader = &countingReader{Reader: fake.NewClientBuilder().WithObjects(secret).Build()}
+	})
+
+	It("should read the secret once per TTL", func() {
+		cache := &caBundleCache{}
+		now := time.Now()
+		for range 3 {
+			caBundle, err := cache.get(ctx, reader, "default", "resolver-ca", "ca.crt", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(caBundle)).To(Equal("first"))
+		}
+		Expect(reader.gets).To(Equal(1))
+
+		_, err := cache.get(ctx, reader, "default", "resolver-ca", "ca.crt", now.Add(caBundleTTL))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reader.gets).To(Equal(2))
+	})
+
+	It("should not cache missing secrets or keys", func() {
+		cache := &caBundleCache{}
+		_, err := cache.get(ctx, reader, "default", "missing", "ca.crt", time.Now())
+		Expect(err).To(HaveOccurred())
+		_, err = cache.get(ctx, reader, "default", "resolver-ca", "tls.crt", time.Now())
+		Expect(err).To(MatchError(ContainSubstring(`has no key "tls.crt"`)))
+		_, err = cache.get(ctx, reader, "default", "resolver-ca", "tls.crt", time.Now())
+		Expect(err).To(HaveOccurred())
+		Expect(reader.gets).To(Equal(3))
+	})
+})
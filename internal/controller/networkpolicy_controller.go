@@ -18,15 +18,29 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/mransonwang/fqdn-egress-operator/pkg/network"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
 	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
 
@@ -48,21 +62,79 @@ type DNSResolver interface {
 		networkType v1alpha1.NetworkType,
 		fqdns []v1alpha1.FQDN,
 	) network.DNSResolverResultList
+	// ResolveSRV resolves the SRV records of all the given names to their targets and ports
+	ResolveSRV(
+		ctx context.Context,
+		timeout time.Duration,
+		maxConcurrent int,
+		names []v1alpha1.SRVName,
+	) network.SRVResolverResultList
+}
+
+// NameSource discovers domain names matching a wildcard pattern
+type NameSource interface {
+	// Names returns the known domain names matching the pattern
+	Names(pattern v1alpha1.FQDNPattern) []v1alpha1.FQDN
 }
 
+// maxExpandedFQDNsPerPattern limits how many concrete FQDNs a single wildcard pattern can expand to
+const maxExpandedFQDNsPerPattern = 50
+
+// enqueueBufferSize is the number of network policies enqueued by the backends that are buffered until the
+// controller picks them up
+const enqueueBufferSize = 1024
+
+// defaultDiscoveredNameRetention is how long an FQDN expanded from a wildcard pattern is kept after it was last
+// discovered, if the reconciler has no DiscoveredNameRetention
+const defaultDiscoveredNameRetention = 24 * time.Hour
+
+// maxGovernanceMessages limits how many denied FQDNs are listed in the Governance condition
+const maxGovernanceMessages = 10
+
 // NetworkPolicyReconciler reconciles a NetworkPolicy object
 type NetworkPolicyReconciler struct {
 	client.Client
 	Scheme                *runtime.Scheme
 	EventRecorder         record.EventRecorder
 	DNSResolver           DNSResolver
+	NameSource            NameSource
 	MaxConcurrentResolves int
+	// DiscoveredNameRetention is how long an FQDN expanded from a wildcard pattern is kept after the NameSource last
+	// returned it. Defaults to defaultDiscoveredNameRetention.
+	DiscoveredNameRetention time.Duration
+	// APIReader reads objects that are not cached by the manager, such as the secrets holding resolver CA bundles.
+	// Defaults to the client.
+	APIReader client.Reader
+	// DNSResolverFor returns the DNSResolver for network policies with their own resolver configuration. When nil,
+	// DNSResolver is used for all network policies.
+	DNSResolverFor func(config network.ClientConfig) DNSResolver
+	// MinRefreshInterval is the lower bound for re-resolving an FQDN, regardless of the TTL of its DNS records
+	MinRefreshInterval time.Duration
+	// MaxRefreshInterval is the upper bound for re-resolving an FQDN. Zero disables the upper bound.
+	MaxRefreshInterval time.Duration
+	// Backends render network policies by output kind. When nil, the backends returned by NewBackends are used.
+	Backends map[v1alpha1.OutputKind]Backend
+	// MultiNetworkPlugins are the CNI plugin types enforcing MultiNetworkPolicies. Target networks using another plugin
+	// are reported as NADMismatchedType. When empty, any plugin is accepted.
+	MultiNetworkPlugins []string
+	// Settings are the operator-wide defaults and limits of the OperatorConfig. When nil, the defaults of the fields
+	// above apply.
+	Settings *OperatorSettings
+
+	caBundles caBundleCache
+	// events receives the network policies enqueued by the backends, see enqueue
+	events chan event.GenericEvent
 }
 
 // +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=multi-networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=network-attachment-definitions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
 // +kubebuilder:rbac:groups=networking.turbosimone.com,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking.turbosimone.com,resources=networkpolicies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=networking.turbosimone.com,resources=networkpolicies/finalizers,verbs=update
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=fqdngovernancepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -74,15 +146,49 @@ type NetworkPolicyReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracer.Start(ctx, "NetworkPolicy.Reconcile", trace.WithAttributes(
+		semconv.K8SNamespaceName(req.Namespace), tracing.PolicyNameKey.String(req.Name),
+	))
+	defer span.End()
+
+	release, err := r.Settings.acquire(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer release()
+
+	result, err := r.reconcile(ctx, req)
+	recordSpanError(span, err)
+	return result, err
+}
+
+// reconcile resolves the FQDNs of the network policy and renders the network policy of its output kind
+func (r *NetworkPolicyReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	np := &v1alpha1.NetworkPolicy{}
 	if err := r.Get(ctx, req.NamespacedName, np); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		if apierrors.IsNotFound(err) {
+			deletePolicyMetrics(req.NamespacedName)
+			// Backends merging the FQDN network policies of a namespace drop the rules of the removed policy
+			return ctrl.Result{}, r.refreshNamespace(ctx, req.Namespace)
+		}
+		return ctrl.Result{}, err
 	}
 
+	// The defaulting webhook stores the defaults of the namespace in the spec when the network policy is written, so
+	// later changes of the NetworkPolicyDefaults do not affect it. Only the built-in defaults are applied here, for
+	// network policies written while the webhook was not available.
+	np.ApplyDefaults(nil)
+
 	patch := client.MergeFrom(np.DeepCopy())
 
-	if np.Spec.TargetNetwork != "" {
-		expectedAnnotationValue := fmt.Sprintf("%s/%s", np.Namespace, np.Spec.TargetNetwork)
+	// The policy-for annotation selects the secondary networks of MultiNetworkPolicies as a comma separated list
+	refs := np.TargetNetworkRefs()
+	if len(refs) > 0 && r.outputKind(np) == v1alpha1.OutputKindMultiNetworkPolicy {
+		networks := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			networks = append(networks, ref.String())
+		}
+		expectedAnnotationValue := strings.Join(networks, ",")
 		annotationKey := "k8s.v1.cni.cncf.io/policy-for"
 
 		if np.Annotations == nil || np.Annotations[annotationKey] != expectedAnnotationValue {
@@ -99,31 +205,54 @@ func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 	}
 
-	// Resolve the FQDNs to IP addresses
-	resolveTimeout := time.Duration(np.Spec.ResolveTimeoutSeconds) * time.Second
-	results := r.DNSResolver.Resolve(
-		ctx, resolveTimeout, r.MaxConcurrentResolves, np.Spec.EnabledNetworkType, np.FQDNs(),
-	)
+	// Report whether the target network exists, the network policy is still rendered so it takes effect as soon as the
+	// NetworkAttachmentDefinition is created
+	if err := r.reconcileNetworkAttachment(ctx, np); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	np.Status.FQDNs = updateFQDNStatuses(
-		r.EventRecorder, np, np.Status.FQDNs, results, int(np.Spec.RetryTimeoutSeconds),
-	)
+	// Resolve the FQDNs to IP addresses
+	results, err := r.resolveFQDNs(ctx, np, np)
+	if err != nil {
+		np.SetReadyConditionFalse(readyReasonForError(err), err.Error())
+		if err := r.Client.Status().Update(ctx, np); err != nil {
+			return ctrl.Result{}, err
+		}
+		if isSizeLimitError(err) {
+			logf.FromContext(ctx).Info("Size limit exceeded, will not requeue until updated", "reason", err.Error())
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	fqdnStatuses := v1alpha1.FQDNStatusList(np.Status.FQDNs)
+	srvStatuses := v1alpha1.SRVStatusList(np.Status.SRVs)
 
-	// Generate a network policy from the FQDN based network policy using the resolved addresses
-	networkPolicy := np.ToMultiNetworkPolicy(np.Status.FQDNs)
+	// Generate a network policy from the FQDN based network policy using the resolved addresses. The backends render
+	// the same rules, so the MultiNetworkPolicy representation is used to count the applied addresses.
+	networkPolicy := renderMultiNetworkPolicy(np)
 
-	np.Status.TotalAddressCount = int32(len(results.CIDRs()))
-	utils.RemoveDuplicateCidrsInNetworkPolicy(networkPolicy)
+	np.Status.TotalAddressCount = int32(fqdnStatuses.AddressCount())
 	np.Status.AppliedAddressCount = int32((utils.CountDeDupedAddresses(networkPolicy)))
-	np.Status.LatestLookupTime = metav1.NewTime(time.Now())
-
-	// Set the resolve status condition
-	resolveStatus := results.AggregatedResolveStatus()
-	np.SetResolveCondition(
-		resolveStatus,
-		results.AggregatedResolveMessage(),
+	if len(results) > 0 {
+		np.Status.LatestLookupTime = metav1.NewTime(time.Now())
+	}
+	recordPolicyMetrics(req.NamespacedName, np)
+	trace.SpanFromContext(ctx).SetAttributes(
+		tracing.ReasonKey.String(string(fqdnStatuses.AggregatedResolveStatus())),
+		tracing.ResolvedAddressCountKey.Int(int(np.Status.TotalAddressCount)),
+		tracing.AddressCountKey.Int(int(np.Status.AppliedAddressCount)),
 	)
 
+	// Set the resolve status condition, failed SRV lookups are reported like failed FQDN lookups
+	resolveStatus := fqdnStatuses.AggregatedResolveStatus()
+	resolveMessage := fqdnStatuses.AggregatedResolveMessage()
+	if srvStatus := srvStatuses.AggregatedResolveStatus(); srvStatus.Priority() > resolveStatus.Priority() {
+		resolveStatus = srvStatus
+		resolveMessage = srvStatuses.AggregatedResolveMessage()
+	}
+	np.SetResolveCondition(resolveStatus, resolveMessage)
+	requeueAfter := r.requeueAfter(np)
+
 	logger := logf.FromContext(ctx).WithValues(
 		"policy", np.GetName(), "namespace", np.GetNamespace(),
 		"status", resolveStatus,
@@ -132,26 +261,30 @@ func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	)
 	logf.IntoContext(ctx, logger)
 
-	// The network policy does not define any Egress rules, delete network policy if it exists
+	// The network policy does not define any Egress or Ingress rules, delete network policy if it exists
 	if networkPolicy == nil {
-		np.SetReadyConditionFalse(v1alpha1.NetworkPolicyFailed, "No Egress rules specified")
-		if err := r.Client.Status().Update(ctx, np); err != nil {
+		if err := r.reconcileNetworkPolicyDeletion(ctx, np); err != nil {
 			return ctrl.Result{}, err
 		}
-		if err := r.reconcileNetworkPolicyDeletion(ctx, np); err != nil {
+		np.SetReadyConditionFalse(v1alpha1.NetworkPolicyFailed, "No Egress or Ingress rules specified")
+		if err := r.Client.Status().Update(ctx, np); err != nil {
 			return ctrl.Result{}, err
 		}
-		logger.Info("No Egress rules, will not requeue until updated")
+		logger.Info("No Egress or Ingress rules, will not requeue until updated")
 		return ctrl.Result{}, nil
 	}
 
-	// There are Egress rules defined in our FQDN network policy, we create or update the underlying
+	// There are Egress or Ingress rules defined in our FQDN network policy, we create or update the underlying
 	// network policy, so we create it.
-	if err := r.reconcileNetworkPolicyCreation(ctx, np, networkPolicy); err != nil {
-		np.SetReadyConditionFalse(v1alpha1.NetworkPolicyFailed, err.Error())
+	if err := r.reconcileNetworkPolicyCreation(ctx, np); err != nil {
+		np.SetReadyConditionFalse(readyReasonForError(err), err.Error())
 		if err := r.Client.Status().Update(ctx, np); err != nil {
 			return ctrl.Result{}, err
 		}
+		if isSizeLimitError(err) {
+			logger.Info("Size limit exceeded, will not requeue until updated", "reason", err.Error())
+			return ctrl.Result{}, nil
+		}
 		return ctrl.Result{}, err
 	}
 
@@ -160,13 +293,13 @@ func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if utils.IsEmpty(networkPolicy) {
 		np.SetReadyConditionTrue(
 			v1alpha1.NetworkPolicyEmptyRules,
-			"Resolved to an empty NetworkPolicy. Egress deny-all in effect.",
+			"Resolved to an empty NetworkPolicy. Deny-all in effect for its policy types.",
 		)
 		if err := r.Client.Status().Update(ctx, np); err != nil {
 			return ctrl.Result{}, err
 		}
-		logger.Info("Network policy is empty", "requeueAfter", np.Spec.TTLSeconds)
-		return ctrl.Result{RequeueAfter: time.Duration(np.Spec.TTLSeconds) * time.Second}, nil
+		logger.Info("Network policy is empty", "requeueAfter", requeueAfter)
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
 	// Creation succeeded, update the status and requeue after TTL
@@ -174,17 +307,376 @@ func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if err := r.Client.Status().Update(ctx, np); err != nil {
 		return ctrl.Result{}, err
 	}
-	logger.Info("Reconciliation succeeded", "requeueAfter", np.Spec.TTLSeconds)
-	return ctrl.Result{RequeueAfter: time.Duration(np.Spec.TTLSeconds) * time.Second}, nil
+	logger.Info("Reconciliation succeeded", "requeueAfter", requeueAfter)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// readyReasonForError returns the reason of the Ready condition for a failed reconciliation
+func readyReasonForError(err error) v1alpha1.NetworkPolicyReadyConditionReason {
+	if isSizeLimitError(err) {
+		return v1alpha1.NetworkPolicySizeLimitExceeded
+	}
+	var conflictErr *OutputConflictError
+	if errors.As(err, &conflictErr) {
+		return v1alpha1.NetworkPolicyOutputConflict
+	}
+	var unsupportedErr *UnsupportedRulesError
+	if errors.As(err, &unsupportedErr) {
+		return v1alpha1.NetworkPolicyUnsupportedRules
+	}
+	return v1alpha1.NetworkPolicyFailed
+}
+
+// isSizeLimitError reports whether the reconciliation failed on a size limit. Retrying does not help, so the failure is
+// not requeued and the policy is reconciled again when it or the OperatorConfig changes. The output generated before
+// is kept, deleting it would also drop the deny-all of its policy types.
+func isSizeLimitError(err error) bool {
+	var sizeErr *SizeLimitError
+	return errors.As(err, &sizeErr)
+}
+
+// resolveFQDNs resolves the FQDNs of the network policy whose DNS records expired, including the FQDNs expanded from
+// its wildcard patterns and the targets of its SRV names, and updates the FQDN and SRV statuses. FQDNs whose CNAME
+// chain leaves the allowed CNAME suffixes fail to resolve. The resolver and the CIDRs of the network policy that the
+// FQDNGovernancePolicies of the namespace forbid are removed from np, so they are neither used nor rendered. Events
+// are recorded on object.
+func (r *NetworkPolicyReconciler) resolveFQDNs(
+	ctx context.Context, np *v1alpha1.NetworkPolicy, object runtime.Object,
+) (network.DNSResolverResultList, error) {
+	governance, err := utils.GetFQDNGovernance(ctx, r.Client, np.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	ignored := governance.Govern(np)
+
+	dnsResolver, err := r.dnsResolverFor(ctx, np)
+	if err != nil {
+		return nil, err
+	}
+	resolveTimeout := time.Duration(np.Spec.ResolveTimeoutSeconds) * time.Second
+	// Only resolve the names whose DNS records expired, unless the spec changed since the last reconciliation
+	specChanged := np.Status.ObservedGeneration != np.GetGeneration()
+
+	// Resolve the SRV names first, so their current targets are resolved with the FQDNs
+	srvNames := np.SRVNames()
+	dueSRVs := srvNames
+	if !specChanged {
+		dueSRVs = dueSRVNames(srvNames, np.Status.SRVs, time.Now())
+	}
+	var srvResults network.SRVResolverResultList
+	if len(dueSRVs) > 0 {
+		srvResults = dnsResolver.ResolveSRV(ctx, resolveTimeout, r.MaxConcurrentResolves, dueSRVs)
+	}
+	np.Status.SRVs = updateSRVStatuses(
+		r.EventRecorder, object, srvNames, np.Status.SRVs, srvResults, int(np.Spec.RetryTimeoutSeconds),
+		func(result *network.SRVResolverResult) time.Duration {
+			return r.refreshInterval(np, result.Status, result.TTL)
+		},
+	)
+
+	// Expand the wildcard patterns to the concrete FQDNs discovered so far
+	now := time.Now()
+	discover, discovered := r.discoverFQDNs(np, now)
+	expanded := np.ExpandFQDNPatterns(discover, maxExpandedFQDNsPerPattern)
+	fqdns := np.FQDNs()
+	for _, fqdn := range slices.Sorted(maps.Keys(expanded)) {
+		fqdns = append(fqdns, fqdn)
+	}
+	for _, target := range v1alpha1.SRVStatusList(np.Status.SRVs).Targets() {
+		if !slices.Contains(fqdns, target) {
+			fqdns = append(fqdns, target)
+		}
+	}
+	fqdns = governFQDNs(np, governance, fqdns, ignored)
+	if limit := int(r.Settings.Spec().MaxFQDNsPerPolicy); limit > 0 && len(fqdns) > limit {
+		return nil, &SizeLimitError{Kind: "network policy", Items: "FQDNs", Size: len(fqdns), Limit: limit}
+	}
+
+	due := fqdns
+	if !specChanged {
+		due = dueFQDNs(fqdns, np.Status.FQDNs, time.Now())
+	}
+	results := dnsResolver.Resolve(
+		ctx, resolveTimeout, r.MaxConcurrentResolves, np.Spec.EnabledNetworkType, due,
+	)
+	results.RestrictCNAMEs(np.Spec.AllowedCNAMESuffixes)
+
+	np.Status.FQDNs = updateFQDNStatuses(
+		r.EventRecorder, object, fqdns, np.Status.FQDNs, results, expanded, int(np.Spec.RetryTimeoutSeconds),
+		np.Spec.AddressRetention.Window(),
+		func(result *network.DNSResolverResult) time.Duration {
+			return r.refreshInterval(np, result.Status, result.TTL)
+		},
+	)
+	markDiscoveredFQDNs(np.Status.FQDNs, discovered, now)
+	return results, nil
+}
+
+// governFQDNs returns the FQDNs complying with the FQDNGovernancePolicies of the namespace and updates the Governance
+// condition, listing the denied FQDNs and the ignored settings of the network policy. The denied FQDNs are neither
+// resolved nor allowed, e.g. if a policy changed after the network policy was admitted. Cluster network policies are
+// not governed.
+func governFQDNs(
+	np *v1alpha1.NetworkPolicy, governance *v1alpha1.FQDNGovernance, fqdns []v1alpha1.FQDN, ignored []string,
+) []v1alpha1.FQDN {
+	if governance == nil {
+		np.RemoveGovernanceCondition()
+		return fqdns
+	}
+	allowed, denied := governance.Filter(fqdns)
+	var ignoredMessage string
+	if len(ignored) > 0 {
+		ignoredMessage = fmt.Sprintf("Ignored %s, the namespace is governed.", strings.Join(ignored, ", "))
+	}
+	if len(denied) == 0 {
+		if len(ignored) > 0 {
+			np.SetGovernanceCondition(v1alpha1.NetworkPolicySettingsIgnored, ignoredMessage)
+			return allowed
+		}
+		np.SetGovernanceCondition(v1alpha1.NetworkPolicyGovernanceCompliant, "The FQDNs comply with the governance policies.")
+		return allowed
+	}
+
+	names := slices.Sorted(maps.Keys(denied))
+	messages := make([]string, 0, maxGovernanceMessages)
+	for _, name := range names[:min(len(names), maxGovernanceMessages)] {
+		messages = append(messages, fmt.Sprintf("%s is %s", name, denied[name]))
+	}
+	if len(names) > maxGovernanceMessages {
+		messages = append(messages, fmt.Sprintf("and %d more", len(names)-maxGovernanceMessages))
+	}
+	message := fmt.Sprintf("Dropped %d FQDNs: %s", len(names), strings.Join(messages, "; "))
+	if ignoredMessage != "" {
+		message += ". " + ignoredMessage
+	}
+	np.SetGovernanceCondition(v1alpha1.NetworkPolicyFQDNsDenied, message)
+	return allowed
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// dnsResolverFor returns the DNSResolver matching the resolver configuration of the network policy, or the resolver
+// of the OperatorConfig if the network policy has none. The CA bundle of TLS and HTTPS nameservers is read from the
+// secret referenced in the policy namespace and cached for caBundleTTL.
+func (r *NetworkPolicyReconciler) dnsResolverFor(ctx context.Context, np *v1alpha1.NetworkPolicy) (DNSResolver, error) {
+	if r.DNSResolverFor == nil {
+		return r.DNSResolver, nil
+	}
+	if np.Spec.Resolver == nil {
+		if resolver := r.Settings.Spec().Resolver; resolver != nil {
+			return r.DNSResolverFor(network.ClientConfigFromSpec(resolver)), nil
+		}
+		return r.DNSResolver, nil
+	}
+	config := network.ClientConfigFromSpec(np.Spec.Resolver)
+	if tlsConfig := np.Spec.Resolver.TLS; tlsConfig != nil && tlsConfig.CASecretRef != nil {
+		reader := r.APIReader
+		if reader == nil {
+			reader = r.Client
+		}
+		ref := tlsConfig.CASecretRef
+		caBundle, err := r.caBundles.get(ctx, reader, np.Namespace, ref.Name, ref.Key, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		config.CABundle = caBundle
+	}
+	return r.DNSResolverFor(config), nil
+}
+
+// clampRefreshInterval bounds the interval by MinRefreshInterval and MaxRefreshInterval, or the TTL bounds of the
+// OperatorConfig if set. The interval is never shorter than a second, as a zero RequeueAfter would disable the
+// requeue.
+func (r *NetworkPolicyReconciler) clampRefreshInterval(interval time.Duration) time.Duration {
+	minInterval, maxInterval := r.MinRefreshInterval, r.MaxRefreshInterval
+	spec := r.Settings.Spec()
+	if spec.MinTTLSeconds > 0 {
+		minInterval = time.Duration(spec.MinTTLSeconds) * time.Second
+	}
+	if spec.MaxTTLSeconds > 0 {
+		maxInterval = time.Duration(spec.MaxTTLSeconds) * time.Second
+	}
+	interval = max(interval, minInterval, time.Second)
+	if maxInterval > 0 {
+		interval = min(interval, maxInterval)
+	}
+	return interval
+}
+
+// outputKind returns the output kind of the network policy, defaulting to the default output kind of the
+// OperatorConfig
+func (r *NetworkPolicyReconciler) outputKind(np *v1alpha1.NetworkPolicy) v1alpha1.OutputKind {
+	return np.OutputKindOrDefault(r.Settings.Spec().DefaultOutputKind)
+}
+
+// refreshInterval returns how long the result of a lookup with the given status and TTL stays valid. Successful
+// lookups follow the TTL of the DNS records, failed lookups and resolvers without TTL information fall back to the
+// TTLSeconds of the network policy.
+func (r *NetworkPolicyReconciler) refreshInterval(
+	np *v1alpha1.NetworkPolicy, status v1alpha1.NetworkPolicyResolvedConditionReason, ttl time.Duration,
+) time.Duration {
+	interval := time.Duration(np.Spec.TTLSeconds) * time.Second
+	if status == v1alpha1.NetworkPolicyResolveSuccess && ttl > 0 {
+		interval = ttl
+	}
+	return r.clampRefreshInterval(interval)
+}
+
+// requeueAfter returns the time until the earliest scheduled refresh of the FQDNs and SRV names in the network policy
+func (r *NetworkPolicyReconciler) requeueAfter(np *v1alpha1.NetworkPolicy) time.Duration {
+	next, ok := v1alpha1.FQDNStatusList(np.Status.FQDNs).NextRefreshTime()
+	nextSRV, srvOK := v1alpha1.SRVStatusList(np.Status.SRVs).NextRefreshTime()
+	if srvOK && (!ok || nextSRV.Before(next)) {
+		next, ok = nextSRV, true
+	}
+	if !ok {
+		return r.clampRefreshInterval(time.Duration(np.Spec.TTLSeconds) * time.Second)
+	}
+	return r.clampRefreshInterval(time.Until(next))
+}
+
+// discoverFQDNs returns a function listing the candidate FQDNs for a wildcard pattern, and the set of FQDNs it
+// discovered: the KnownFQDNs of the rules with the pattern and the names returned by the NameSource. Names expanded
+// during previous reconciliations are kept as candidates as long as they did not fail permanently and were discovered
+// within the DiscoveredNameRetention, so they do not disappear as soon as the NameSource forgets them.
+func (r *NetworkPolicyReconciler) discoverFQDNs(
+	np *v1alpha1.NetworkPolicy, now time.Time,
+) (func(v1alpha1.FQDNPattern) []v1alpha1.FQDN, map[v1alpha1.FQDN]struct{}) {
+	retention := r.DiscoveredNameRetention
+	if retention <= 0 {
+		retention = defaultDiscoveredNameRetention
+	}
+	discovered := make(map[v1alpha1.FQDN]struct{})
+	return func(pattern v1alpha1.FQDNPattern) []v1alpha1.FQDN {
+		var fqdns []v1alpha1.FQDN
+		for _, status := range np.Status.FQDNs {
+			// Statuses written before the discovery time was recorded are kept until they are discovered again
+			fresh := status.LastDiscoveredTime.IsZero() || now.Sub(status.LastDiscoveredTime.Time) <= retention
+			if status.Pattern == pattern && status.ResolveReason.Transient() && fresh {
+				fqdns = append(fqdns, status.FQDN)
+			}
+		}
+		for _, rule := range np.Spec.Egresses {
+			if slices.Contains(rule.ToFQDNPatterns, pattern) {
+				for _, fqdn := range rule.KnownFQDNs {
+					discovered[fqdn.Normalize()] = struct{}{}
+				}
+			}
+		}
+		if r.NameSource != nil {
+			for _, fqdn := range r.NameSource.Names(pattern) {
+				discovered[fqdn.Normalize()] = struct{}{}
+				fqdns = append(fqdns, fqdn)
+			}
+		}
+		return fqdns
+	}, discovered
+}
+
+// markDiscoveredFQDNs sets the LastDiscoveredTime of the FQDN statuses expanded from a pattern that were discovered,
+// or that have no discovery time yet, to now. FQDNs listed in ToFQDNs have no discovery time.
+func markDiscoveredFQDNs(statuses []v1alpha1.FQDNStatus, discovered map[v1alpha1.FQDN]struct{}, now time.Time) {
+	for i := range statuses {
+		status := &statuses[i]
+		if status.Pattern == "" {
+			status.LastDiscoveredTime = metav1.Time{}
+			continue
+		}
+		if _, ok := discovered[status.FQDN]; ok || status.LastDiscoveredTime.IsZero() {
+			status.LastDiscoveredTime = metav1.NewTime(now)
+		}
+	}
+}
+
+// enqueue requests the reconciliation of the network policies, e.g. by backends merging several network policies into
+// a shared object. It does nothing before the controller is set up.
+func (r *NetworkPolicyReconciler) enqueue(ctx context.Context, nps ...*v1alpha1.NetworkPolicy) {
+	if r.events == nil {
+		return
+	}
+	for _, np := range nps {
+		select {
+		case r.events <- event.GenericEvent{Object: np}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager. NetworkAttachmentDefinitions are only watched if their
+// CRD is installed. All network policies are reconciled when the OperatorConfig or an FQDNGovernancePolicy changes, and
+// the network policies of a namespace when its labels change.
 func (r *NetworkPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	maxConcurrentReconciles := defaultMaxConcurrentReconciles
+	if r.Settings != nil {
+		maxConcurrentReconciles = maxConcurrentReconcileWorkers
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.NetworkPolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Named("fqdn-egress-operator").
 		WithOptions(controller.Options{
-			MaxConcurrentReconciles: 5,
+			MaxConcurrentReconciles: maxConcurrentReconciles,
 		}).
-		Complete(r)
+		Watches(
+			&v1alpha1.FQDNGovernancePolicy{},
+			handler.EnqueueRequestsFromMapFunc(r.allNetworkPolicies),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.networkPoliciesInNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		)
+	if r.Settings != nil {
+		b = b.WatchesRawSource(source.Channel(
+			r.Settings.Subscribe(), handler.EnqueueRequestsFromMapFunc(r.allNetworkPolicies),
+		))
+	}
+	r.events = make(chan event.GenericEvent, enqueueBufferSize)
+	b = b.WatchesRawSource(source.Channel(r.events, &handler.EnqueueRequestForObject{}))
+
+	gvk := utils.NetworkAttachmentDefinitionGVK
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	switch {
+	case err == nil:
+		err := mgr.GetFieldIndexer().IndexField(
+			context.Background(), &v1alpha1.NetworkPolicy{}, targetNetworkIndex, indexTargetNetworks,
+		)
+		if err != nil {
+			return err
+		}
+		b = b.Watches(
+			utils.NewNetworkAttachmentDefinition(),
+			handler.EnqueueRequestsFromMapFunc(r.networkPoliciesForNetworkAttachment),
+		)
+	case !meta.IsNoMatchError(err):
+		return err
+	}
+	return b.Complete(r)
+}
+
+// networkPoliciesInNamespace maps a namespace to its network policies
+func (r *NetworkPolicyReconciler) networkPoliciesInNamespace(ctx context.Context, ns client.Object) []reconcile.Request {
+	policies := &v1alpha1.NetworkPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(ns.GetName())); err != nil {
+		logf.FromContext(ctx).Error(err, "unable to list the network policies", "namespace", ns.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(policies.Items))
+	for i := range policies.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+	}
+	return requests
+}
+
+// allNetworkPolicies maps an event to all network policies of the cluster
+func (r *NetworkPolicyReconciler) allNetworkPolicies(ctx context.Context, _ client.Object) []reconcile.Request {
+	policies := &v1alpha1.NetworkPolicyList{}
+	if err := r.List(ctx, policies); err != nil {
+		logf.FromContext(ctx).Error(err, "unable to list the network policies")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(policies.Items))
+	for i := range policies.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+	}
+	return requests
 }
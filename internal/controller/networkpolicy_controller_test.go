@@ -21,13 +21,21 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/network"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
 )
 
 var _ = Describe("NetworkPolicy Controller", func() {
@@ -81,4 +89,299 @@ var _ = Describe("NetworkPolicy Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When checking the target network", func() {
+		ctx := context.Background()
+		var np *networkingv1alpha1.NetworkPolicy
+		var reconciler *NetworkPolicyReconciler
+
+		BeforeEach(func() {
+			np = &networkingv1alpha1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "attached", Namespace: "default"},
+				Spec:       networkingv1alpha1.NetworkPolicySpec{TargetNetwork: "storage"},
+			}
+			reconciler = &NetworkPolicyReconciler{
+				Client:              k8sClient,
+				Scheme:              k8sClient.Scheme(),
+				MultiNetworkPlugins: []string{"ovn-k8s-cni-overlay"},
+			}
+		})
+
+		AfterEach(func() {
+			nad := utils.NewNetworkAttachmentDefinition()
+			nad.SetName("storage")
+			nad.SetNamespace("default")
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, nad))).To(Succeed())
+		})
+
+		createNetworkAttachment := func(config string) {
+			nad := utils.NewNetworkAttachmentDefinition()
+			nad.SetName("storage")
+			nad.SetNamespace("default")
+			Expect(unstructured.SetNestedField(nad.Object, config, "spec", "config")).To(Succeed())
+			Expect(k8sClient.Create(ctx, nad)).To(Succeed())
+		}
+		networkAttached := func() *metav1.Condition {
+			return meta.FindStatusCondition(
+				np.Status.Conditions, string(networkingv1alpha1.NetworkPolicyNetworkAttachedCondition),
+			)
+		}
+
+		It("should report a missing NetworkAttachmentDefinition", func() {
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			Expect(networkAttached().Status).To(Equal(metav1.ConditionFalse))
+			Expect(networkAttached().Reason).To(Equal(string(networkingv1alpha1.NetworkPolicyNADNotFound)))
+		})
+
+		It("should report a NetworkAttachmentDefinition of another CNI plugin", func() {
+			createNetworkAttachment(`{"cniVersion": "0.4.0", "plugins": [{"type": "macvlan"}]}`)
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			Expect(networkAttached().Status).To(Equal(metav1.ConditionFalse))
+			Expect(networkAttached().Reason).To(Equal(string(networkingv1alpha1.NetworkPolicyNADMismatchedType)))
+		})
+
+		It("should report an attached target network and map it to the network policy", func() {
+			createNetworkAttachment(`{"cniVersion": "0.4.0", "type": "ovn-k8s-cni-overlay", "topology": "localnet"}`)
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			Expect(networkAttached().Status).To(Equal(metav1.ConditionTrue))
+
+			By("looking up the network policies by the index of their target networks")
+			other := &networkingv1alpha1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+				Spec:       networkingv1alpha1.NetworkPolicySpec{TargetNetwork: "kube-system/storage"},
+			}
+			reconciler.Client = fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(np, other).
+				WithIndex(&networkingv1alpha1.NetworkPolicy{}, targetNetworkIndex, indexTargetNetworks).
+				Build()
+			nad := utils.NewNetworkAttachmentDefinition()
+			nad.SetName("storage")
+			nad.SetNamespace("default")
+			Expect(reconciler.networkPoliciesForNetworkAttachment(ctx, nad)).To(ConsistOf(
+				reconcile.Request{NamespacedName: client.ObjectKeyFromObject(np)},
+			))
+		})
+
+		It("should accept a NetworkAttachmentDefinition without config", func() {
+			createNetworkAttachment("")
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			Expect(networkAttached().Status).To(Equal(metav1.ConditionTrue))
+			Expect(networkAttached().Reason).To(Equal(string(networkingv1alpha1.NetworkPolicyNADFound)))
+		})
+
+		It("should report the first target network that is not attached", func() {
+			createNetworkAttachment(`{"cniVersion": "0.4.0", "type": "ovn-k8s-cni-overlay", "topology": "localnet"}`)
+			np.Spec.TargetNetwork = ""
+			np.Spec.TargetNetworks = []string{"default/storage", "kube-system/missing"}
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			Expect(networkAttached().Reason).To(Equal(string(networkingv1alpha1.NetworkPolicyNADNotFound)))
+			Expect(networkAttached().Message).To(ContainSubstring("kube-system/missing"))
+		})
+
+		It("should remove the condition for output kinds without target network", func() {
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			np.Spec.OutputKind = networkingv1alpha1.OutputKindNetworkPolicy
+			Expect(reconciler.reconcileNetworkAttachment(ctx, np)).To(Succeed())
+			Expect(networkAttached()).To(BeNil())
+		})
+	})
+
+	Context("When governing the FQDNs", func() {
+		ctx := context.Background()
+		var np *networkingv1alpha1.NetworkPolicy
+		var policy *networkingv1alpha1.FQDNGovernancePolicy
+		fqdns := []networkingv1alpha1.FQDN{"www.example.com", "admin.example.com", "www.example.org"}
+
+		BeforeEach(func() {
+			np = &networkingv1alpha1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "governed", Namespace: "default"},
+			}
+			policy = &networkingv1alpha1.FQDNGovernancePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenants"},
+				Spec: networkingv1alpha1.FQDNGovernancePolicySpec{
+					NamespaceSelector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"kubernetes.io/metadata.name": "default"},
+					},
+					AllowedSuffixes: []networkingv1alpha1.FQDN{"example.com"},
+					DeniedRegexes:   []string{`admin\..*`},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, policy))).To(Succeed())
+		})
+
+		governance := func() *metav1.Condition {
+			return meta.FindStatusCondition(np.Status.Conditions, string(networkingv1alpha1.NetworkPolicyGovernanceCondition))
+		}
+		govern := func(fqdns []networkingv1alpha1.FQDN) []networkingv1alpha1.FQDN {
+			fqdnGovernance, err := utils.GetFQDNGovernance(ctx, k8sClient, np.Namespace)
+			Expect(err).NotTo(HaveOccurred())
+			return governFQDNs(np, fqdnGovernance, fqdns, fqdnGovernance.Govern(np))
+		}
+
+		It("should keep all FQDNs without governance policies", func() {
+			allowed := govern(fqdns)
+			Expect(allowed).To(Equal(fqdns))
+			Expect(governance()).To(BeNil())
+		})
+
+		It("should drop the FQDNs breaking the governance policies of the namespace", func() {
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			allowed := govern(fqdns)
+			Expect(allowed).To(ConsistOf(networkingv1alpha1.FQDN("www.example.com")))
+			Expect(governance().Status).To(Equal(metav1.ConditionFalse))
+			Expect(governance().Reason).To(Equal(string(networkingv1alpha1.NetworkPolicyFQDNsDenied)))
+			Expect(governance().Message).To(ContainSubstring("admin.example.com is denied by a regex"))
+			Expect(governance().Message).To(ContainSubstring("www.example.org is not allowed"))
+
+			By("reporting compliant FQDNs")
+			allowed = govern(fqdns[:1])
+			Expect(allowed).To(HaveLen(1))
+			Expect(governance().Status).To(Equal(metav1.ConditionTrue))
+		})
+
+		It("should ignore the resolver and the CIDRs of network policies in namespaces forbidding them", func() {
+			np.Spec.Resolver = &networkingv1alpha1.ResolverConfig{Nameservers: []string{"192.0.2.53"}}
+			np.Spec.Egresses = []networkingv1alpha1.EgressRule{
+				{ToFQDNs: fqdns[:1]},
+				{ToCIDRs: []networkingv1alpha1.CIDRPeer{{CIDR: "192.0.2.0/24"}}},
+			}
+			policy.Spec.ForbidCIDRs = true
+			policy.Spec.ForbidCustomResolvers = true
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			allowed := govern(fqdns[:1])
+			Expect(allowed).To(HaveLen(1))
+			Expect(np.Spec.Resolver).To(BeNil())
+			Expect(np.Spec.Egresses[1].ToCIDRs).To(BeEmpty())
+			Expect(governance().Status).To(Equal(metav1.ConditionFalse))
+			Expect(governance().Reason).To(Equal(string(networkingv1alpha1.NetworkPolicySettingsIgnored)))
+			Expect(governance().Message).To(ContainSubstring("spec.resolver, spec.egress[1].toCIDRs"))
+		})
+
+		It("should ignore governance policies not selecting the namespace", func() {
+			policy.Spec.NamespaceSelector.MatchLabels = map[string]string{"kubernetes.io/metadata.name": "tenant"}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			allowed := govern(fqdns)
+			Expect(allowed).To(Equal(fqdns))
+			Expect(governance()).To(BeNil())
+		})
+
+		It("should deny all FQDNs of a governance policy with an invalid regex", func() {
+			policy.Spec.DeniedRegexes = []string{"("}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			allowed := govern(fqdns)
+			Expect(allowed).To(BeEmpty())
+			Expect(governance().Message).To(ContainSubstring("invalid regex"))
+		})
+	})
+
+	Context("When switching the output kind", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "switched", Namespace: "default"}
+		var reconciler *NetworkPolicyReconciler
+
+		BeforeEach(func() {
+			reconciler = &NetworkPolicyReconciler{
+				Client:        k8sClient,
+				Scheme:        k8sClient.Scheme(),
+				EventRecorder: record.NewFakeRecorder(10),
+				DNSResolver: &network.FakeDNSResolver{Results: network.DNSResolverResultList{{
+					Domain: "www.example.com",
+					Status: networkingv1alpha1.NetworkPolicyResolveSuccess,
+					CIDRs:  []*networkingv1alpha1.CIDR{networkingv1alpha1.MustCIDR("192.0.2.10/32")},
+				}}},
+			}
+			np := &networkingv1alpha1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: networkingv1alpha1.NetworkPolicySpec{
+					OutputKind: networkingv1alpha1.OutputKindNetworkPolicy,
+					Egresses: []networkingv1alpha1.EgressRule{{
+						ToFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, np)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			np := &networkingv1alpha1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, key, np)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, np)).To(Succeed())
+		})
+
+		It("should replace the generated network policy by the one of the new output kind", func() {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			generated := &netv1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, key, generated)).To(Succeed())
+			Expect(generated.Spec.Egress).To(HaveLen(1))
+			Expect(generated.Spec.Egress[0].To[0].IPBlock.CIDR).To(Equal("192.0.2.10/32"))
+
+			By("switching to CiliumNetworkPolicies")
+			np := &networkingv1alpha1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, key, np)).To(Succeed())
+			np.Spec.OutputKind = networkingv1alpha1.OutputKindCiliumNetworkPolicy
+			Expect(k8sClient.Update(ctx, np)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, newCiliumNetworkPolicy())).To(Succeed())
+			err = k8sClient.Get(ctx, key, &netv1.NetworkPolicy{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, key, np)).To(Succeed())
+			Expect(np.Status.AppliedOutputKind).To(Equal(networkingv1alpha1.OutputKindCiliumNetworkPolicy))
+		})
+
+		It("should only query the backend of the previous output kind", func() {
+			deleted := map[networkingv1alpha1.OutputKind]int{}
+			reconciler.Backends = map[networkingv1alpha1.OutputKind]Backend{}
+			for _, kind := range []networkingv1alpha1.OutputKind{
+				networkingv1alpha1.OutputKindMultiNetworkPolicy, networkingv1alpha1.OutputKindNetworkPolicy,
+				networkingv1alpha1.OutputKindCiliumNetworkPolicy, networkingv1alpha1.OutputKindEgressFirewall,
+			} {
+				reconciler.Backends[kind] = &recordingBackend{deleted: deleted, kind: kind}
+			}
+			np := &networkingv1alpha1.NetworkPolicy{Spec: networkingv1alpha1.NetworkPolicySpec{
+				OutputKind: networkingv1alpha1.OutputKindNetworkPolicy,
+			}}
+
+			By("removing the outputs of all other backends once if the applied output kind is unknown")
+			Expect(reconciler.reconcileNetworkPolicyCreation(ctx, np)).To(Succeed())
+			Expect(deleted).To(HaveLen(3))
+			Expect(np.Status.AppliedOutputKind).To(Equal(networkingv1alpha1.OutputKindNetworkPolicy))
+
+			By("not querying the other backends while the output kind is unchanged")
+			clear(deleted)
+			Expect(reconciler.reconcileNetworkPolicyCreation(ctx, np)).To(Succeed())
+			Expect(deleted).To(BeEmpty())
+
+			By("removing the output of the previous output kind only")
+			np.Spec.OutputKind = networkingv1alpha1.OutputKindEgressFirewall
+			Expect(reconciler.reconcileNetworkPolicyCreation(ctx, np)).To(Succeed())
+			Expect(deleted).To(Equal(map[networkingv1alpha1.OutputKind]int{networkingv1alpha1.OutputKindNetworkPolicy: 1}))
+			Expect(np.Status.AppliedOutputKind).To(Equal(networkingv1alpha1.OutputKindEgressFirewall))
+		})
+	})
 })
+
+// recordingBackend counts the deletions by output kind
+type recordingBackend struct {
+	deleted map[networkingv1alpha1.OutputKind]int
+	kind    networkingv1alpha1.OutputKind
+}
+
+func (b *recordingBackend) Apply(context.Context, *networkingv1alpha1.NetworkPolicy) error {
+	return nil
+}
+
+func (b *recordingBackend) Delete(context.Context, *networkingv1alpha1.NetworkPolicy) error {
+	b.deleted[b.kind]++
+	return nil
+}
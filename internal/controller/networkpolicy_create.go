@@ -2,57 +2,59 @@ package controller
 
 import (
 	"context"
-	"maps"
+	"fmt"
 
-	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
 	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
-	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/equality"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// reconcileNetworkPolicyCreation Creates the underlying network policy
-func (r *NetworkPolicyReconciler) reconcileNetworkPolicyCreation(
-	ctx context.Context, np *v1alpha1.NetworkPolicy, networkPolicy *mnetv1beta1.MultiNetworkPolicy,
-) error {
-	current := &mnetv1beta1.MultiNetworkPolicy{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      np.Name,
-			Namespace: np.Namespace,
-		},
+// reconcileNetworkPolicyCreation Creates the underlying network policy with the backend of the output kind. The
+// network policy of the previously applied output kind is removed when the output kind changes, the other backends are
+// not queried. Network policies applying more addresses than allowed by the OperatorConfig are not applied.
+func (r *NetworkPolicyReconciler) reconcileNetworkPolicyCreation(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	if limit := r.Settings.Spec().MaxAddressesPerPolicy; limit > 0 && np.Status.AppliedAddressCount > limit {
+		return &SizeLimitError{
+			Kind: "network policy", Items: "addresses", Size: int(np.Status.AppliedAddressCount), Limit: int(limit),
+		}
+	}
+	outputKind := r.outputKind(np)
+	backends := r.backends()
+	backend, ok := backends[outputKind]
+	if !ok {
+		return fmt.Errorf("no backend for output kind %q", outputKind)
+	}
+	if err := backend.Apply(ctx, np); err != nil {
+		return err
 	}
-	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, current, func() error {
-		if !utils.MapContains(current.Labels, networkPolicy.Labels) {
-			current.Labels = networkPolicy.Labels
+	if np.Status.AppliedOutputKind != outputKind {
+		if err := r.deleteOutputs(ctx, np, backends, outputKind); err != nil {
+			return err
 		}
+	}
+	np.Status.AppliedOutputKind = outputKind
+	return nil
+}
 
-		if !utils.MapContains(current.Annotations, networkPolicy.Annotations) {
-			current.Annotations = maps.Clone(networkPolicy.Annotations)
+// deleteOutputs removes the network policies generated by the backends other than the one of keep. Only the backend
+// of the applied output kind is used if it is known, policies reconciled before the applied output kind was recorded
+// are removed from all other backends once.
+func (r *NetworkPolicyReconciler) deleteOutputs(
+	ctx context.Context, np *v1alpha1.NetworkPolicy, backends map[v1alpha1.OutputKind]Backend, keep v1alpha1.OutputKind,
+) error {
+	for kind, backend := range backends {
+		if kind == keep || (np.Status.AppliedOutputKind != "" && kind != np.Status.AppliedOutputKind) {
+			continue
 		}
-
-		if !equality.Semantic.DeepEqual(current.Spec, networkPolicy.Spec) {
-			current.Spec = *networkPolicy.Spec.DeepCopy()
+		if err := backend.Delete(ctx, np); err != nil {
+			return err
 		}
-		return ctrl.SetControllerReference(np, current, r.Scheme)
-	})
-	if err != nil {
-		r.EventRecorder.Event(
-			np,
-			corev1.EventTypeWarning,
-			utils.OperationErrorReason(networkPolicy),
-			err.Error(),
-		)
-		return err
-	}
-	if op != controllerutil.OperationResultNone {
-		r.EventRecorder.Event(
-			np,
-			corev1.EventTypeNormal,
-			utils.OperationReason(networkPolicy, op),
-			utils.OperationMessage(networkPolicy, op))
 	}
 	return nil
 }
+
+// backends returns the configured backends or the built-in backends
+func (r *NetworkPolicyReconciler) backends() map[v1alpha1.OutputKind]Backend {
+	if r.Backends != nil {
+		return r.Backends
+	}
+	return NewBackends(r.Client, r.Scheme, r.EventRecorder, r.Settings, r.enqueue)
+}
@@ -3,28 +3,28 @@ package controller
 import (
 	"context"
 
-	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
 	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
-	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// reconcileNetworkPolicyCreation Removes the underlying network policy
+// reconcileNetworkPolicyDeletion Removes the underlying network policy of the applied output kind, or of all backends
+// if the applied output kind is not known
 func (r *NetworkPolicyReconciler) reconcileNetworkPolicyDeletion(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
-	networkPolicy := &mnetv1beta1.MultiNetworkPolicy{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      np.Name,
-			Namespace: np.Namespace,
-		},
-	}
-	if err := r.Delete(ctx, networkPolicy); err != nil && !errors.IsNotFound(err) {
+	if err := r.deleteOutputs(ctx, np, r.backends(), ""); err != nil {
 		return err
 	}
-	r.EventRecorder.Event(
-		np, corev1.EventTypeNormal,
-		utils.DeletionReason(networkPolicy), utils.DeletionMessage(networkPolicy),
-	)
+	np.Status.AppliedOutputKind = ""
+	return nil
+}
+
+// refreshNamespace rebuilds the objects shared by the FQDN network policies of the namespace after one of them was
+// removed
+func (r *NetworkPolicyReconciler) refreshNamespace(ctx context.Context, namespace string) error {
+	for _, backend := range r.backends() {
+		if backend, ok := backend.(namespaceBackend); ok {
+			if err := backend.Refresh(ctx, namespace); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
@@ -7,26 +7,81 @@ import (
 	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
 	"github.com/mransonwang/fqdn-egress-operator/pkg/network"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 )
 
+// refreshSlack is added to the current time when selecting due FQDNs, so a requeue firing slightly early does not
+// skip the FQDNs it was scheduled for
+const refreshSlack = time.Second
+
+// dueFQDNs returns the FQDNs that have to be resolved at the given time. FQDNs without a previous status are always
+// due.
+func dueFQDNs(fqdns []v1alpha1.FQDN, previous []v1alpha1.FQDNStatus, now time.Time) []v1alpha1.FQDN {
+	previousLookup := v1alpha1.FQDNStatusList(previous).LookupTable()
+
+	var due []v1alpha1.FQDN
+	for _, fqdn := range fqdns {
+		if status, ok := previousLookup[fqdn]; !ok || status.Due(now.Add(refreshSlack)) {
+			due = append(due, fqdn)
+		}
+	}
+	return due
+}
+
+// dueSRVNames returns the SRV names that have to be resolved at the given time. SRV names without a previous status
+// are always due.
+func dueSRVNames(names []v1alpha1.SRVName, previous []v1alpha1.SRVStatus, now time.Time) []v1alpha1.SRVName {
+	previousLookup := v1alpha1.SRVStatusList(previous).LookupTable()
+
+	var due []v1alpha1.SRVName
+	for _, name := range names {
+		if status, ok := previousLookup[name]; !ok || status.Due(now.Add(refreshSlack)) {
+			due = append(due, name)
+		}
+	}
+	return due
+}
+
 // updateFQDNStatuses updates the status of each FQDN in the network policy according to the results and the previous
-// status
+// status. FQDNs which were not resolved keep their previous status. FQDNs found in patterns are tagged with the
+// wildcard pattern they were expanded from. The next refresh of each resolved FQDN is scheduled after refreshInterval.
+// Addresses seen within retentionWindow are kept when an FQDN is resolved again.
 func updateFQDNStatuses(
 	recorder record.EventRecorder, object runtime.Object,
-	previous []v1alpha1.FQDNStatus, results network.DNSResolverResultList,
-	retryTimeoutSeconds int,
+	fqdns []v1alpha1.FQDN, previous []v1alpha1.FQDNStatus, results network.DNSResolverResultList,
+	patterns map[v1alpha1.FQDN]v1alpha1.FQDNPattern, retryTimeoutSeconds int, retentionWindow time.Duration,
+	refreshInterval func(*network.DNSResolverResult) time.Duration,
 ) []v1alpha1.FQDNStatus {
 	var newFQDNStatuses []v1alpha1.FQDNStatus
 	previousLookup := v1alpha1.FQDNStatusList(previous).LookupTable()
+	resultLookup := results.LookupTable()
+
+	for _, fqdn := range fqdns {
+		result, resolved := resultLookup[fqdn]
+		if !resolved {
+			if status, ok := previousLookup[fqdn]; ok {
+				status.Pattern = patterns[fqdn]
+				newFQDNStatuses = append(newFQDNStatuses, *status)
+			}
+			continue
+		}
 
-	for _, result := range results {
+		nextRefreshTime := metav1.NewTime(time.Now().Add(refreshInterval(result)))
 		if status, ok := previousLookup[result.Domain]; ok {
-			cleared := status.Update(result.CIDRs, result.Status, result.Message, retryTimeoutSeconds)
+			previousAddresses := status.ResolvedAddresses()
+			cleared := status.Update(
+				result.CIDRs, result.CNAMEs, result.Status, result.Message, retryTimeoutSeconds, retentionWindow,
+			)
+			recordAddressChanges(policyKey(object), previousAddresses, status.ResolvedAddresses())
+			status.Pattern = patterns[result.Domain]
+			status.NextRefreshTime = nextRefreshTime
 			newFQDNStatuses = append(newFQDNStatuses, *status)
 
 			if cleared {
+				key := policyKey(object)
+				fqdnRemovedTotal.WithLabelValues(key.Namespace, key.Name, string(status.ResolveReason)).Inc()
 				timeNow := time.Now()
 				recorder.Event(
 					object, corev1.EventTypeWarning, "FQDNRemoved",
@@ -41,13 +96,67 @@ func updateFQDNStatuses(
 				)
 			}
 		} else {
-			newFQDNStatuses = append(newFQDNStatuses, v1alpha1.NewFQDNStatus(
+			status := v1alpha1.NewFQDNStatus(
 				result.Domain,
 				result.CIDRs,
+				result.CNAMEs,
 				result.Status,
 				result.Message,
-			))
+			)
+			status.Pattern = patterns[result.Domain]
+			status.NextRefreshTime = nextRefreshTime
+			newFQDNStatuses = append(newFQDNStatuses, status)
 		}
 	}
 	return newFQDNStatuses
 }
+
+// updateSRVStatuses updates the status of each SRV name in the network policy according to the results and the
+// previous status. SRV names which were not resolved keep their previous status. The next refresh of each resolved SRV
+// name is scheduled after refreshInterval.
+func updateSRVStatuses(
+	recorder record.EventRecorder, object runtime.Object,
+	names []v1alpha1.SRVName, previous []v1alpha1.SRVStatus, results network.SRVResolverResultList,
+	retryTimeoutSeconds int, refreshInterval func(*network.SRVResolverResult) time.Duration,
+) []v1alpha1.SRVStatus {
+	var newSRVStatuses []v1alpha1.SRVStatus
+	previousLookup := v1alpha1.SRVStatusList(previous).LookupTable()
+	resultLookup := results.LookupTable()
+
+	for _, name := range names {
+		result, resolved := resultLookup[name]
+		if !resolved {
+			if status, ok := previousLookup[name]; ok {
+				newSRVStatuses = append(newSRVStatuses, *status)
+			}
+			continue
+		}
+
+		nextRefreshTime := metav1.NewTime(time.Now().Add(refreshInterval(result)))
+		if status, ok := previousLookup[name]; ok {
+			cleared := status.Update(result.Targets, result.Status, result.Message, retryTimeoutSeconds)
+			status.NextRefreshTime = nextRefreshTime
+			newSRVStatuses = append(newSRVStatuses, *status)
+
+			if cleared {
+				timeNow := time.Now()
+				recorder.Event(
+					object, corev1.EventTypeWarning, "SRVTargetsRemoved",
+					fmt.Sprintf(
+						"Targets of SRV name %s removed after being stale for %s. "+
+							"Resolve status at removal time was %s (for %s). "+
+							"Last successful resolve time was %s ago.",
+						status.Name, (time.Duration(retryTimeoutSeconds)*time.Second).String(),
+						status.ResolveReason, timeNow.Sub(status.LastTransitionTime.Time).String(),
+						timeNow.Sub(status.LastSuccessfulTime.Time).String(),
+					),
+				)
+			}
+		} else {
+			status := v1alpha1.NewSRVStatus(name, result.Targets, result.Status, result.Message)
+			status.NextRefreshTime = nextRefreshTime
+			newSRVStatuses = append(newSRVStatuses, status)
+		}
+	}
+	return newSRVStatuses
+}
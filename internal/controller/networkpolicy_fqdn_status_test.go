@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/network"
+)
+
+var _ = Describe("FQDN statuses", func() {
+	var previous []networkingv1alpha1.FQDNStatus
+	var results network.DNSResolverResultList
+	np := &networkingv1alpha1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "retention", Namespace: "default"}}
+	fqdns := []networkingv1alpha1.FQDN{"pool.example.com"}
+	refreshInterval := func(*network.DNSResolverResult) time.Duration { return time.Minute }
+
+	BeforeEach(func() {
+		now := time.Now()
+		previous = []networkingv1alpha1.FQDNStatus{{
+			FQDN:               "pool.example.com",
+			ResolveReason:      networkingv1alpha1.NetworkPolicyResolveSuccess,
+			LastSuccessfulTime: metav1.NewTime(now.Add(-time.Minute)),
+			AddressRecords: []networkingv1alpha1.AddressRecord{{
+				Address:   "192.0.2.1/32",
+				FirstSeen: metav1.NewTime(now.Add(-time.Hour)),
+				LastSeen:  metav1.NewTime(now.Add(-10 * time.Minute)),
+			}, {
+				Address:   "192.0.2.2/32",
+				FirstSeen: metav1.NewTime(now.Add(-3 * time.Hour)),
+				LastSeen:  metav1.NewTime(now.Add(-2 * time.Hour)),
+			}},
+		}}
+		results = network.DNSResolverResultList{network.NewDNSResolverResult(
+			"pool.example.com",
+			[]*networkingv1alpha1.CIDR{{IP: net.ParseIP("192.0.2.3").To4(), Prefix: 32}},
+			time.Minute, nil,
+		)}
+	})
+
+	It("should replace the addresses by default", func() {
+		statuses := updateFQDNStatuses(
+			record.NewFakeRecorder(10), np, fqdns, previous, results, nil, 3600, 0, refreshInterval,
+		)
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].Addresses).To(Equal([]string{"192.0.2.3/32"}))
+	})
+
+	It("should keep the addresses seen within the retention window", func() {
+		retention := &networkingv1alpha1.AddressRetention{
+			Mode: networkingv1alpha1.AddressRetentionSticky, WindowSeconds: 3600,
+		}
+		statuses := updateFQDNStatuses(
+			record.NewFakeRecorder(10), np, fqdns, previous, results, nil, 3600, retention.Window(), refreshInterval,
+		)
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].Addresses).To(Equal([]string{"192.0.2.1/32", "192.0.2.3/32"}))
+		Expect(statuses[0].AddressRecords[0].LastSeen).To(Equal(previous[0].AddressRecords[0].LastSeen))
+		Expect(statuses[0].AddressRecords[1].LastSeen).To(Equal(statuses[0].LastSuccessfulTime))
+	})
+
+	It("should keep the first seen time of addresses returned again", func() {
+		results[0].CIDRs = append(results[0].CIDRs, &networkingv1alpha1.CIDR{IP: net.ParseIP("192.0.2.1").To4(), Prefix: 32})
+		statuses := updateFQDNStatuses(
+			record.NewFakeRecorder(10), np, fqdns, previous, results, nil, 3600, 0, refreshInterval,
+		)
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].AddressRecords).To(HaveLen(2))
+		Expect(statuses[0].AddressRecords[0].FirstSeen).To(Equal(previous[0].AddressRecords[0].FirstSeen))
+		Expect(statuses[0].AddressRecords[1].FirstSeen).To(Equal(statuses[0].LastSuccessfulTime))
+	})
+
+	It("should record the CNAME chain the addresses were found through", func() {
+		results[0].CNAMEs = []string{"pool.cdn.example.net", "edge.cdn.example.net"}
+		statuses := updateFQDNStatuses(
+			record.NewFakeRecorder(10), np, fqdns, nil, results, nil, 3600, 0, refreshInterval,
+		)
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].AddressRecords).To(HaveLen(1))
+		Expect(statuses[0].AddressRecords[0].Source).To(Equal(networkingv1alpha1.FQDN("edge.cdn.example.net")))
+		Expect(statuses[0].CNAMEs).To(Equal(results[0].CNAMEs))
+		Expect(statuses[0].Addresses).To(Equal([]string{statuses[0].AddressRecords[0].Address}))
+	})
+
+	It("should remove the addresses when the CNAME chain leaves the allowed suffixes", func() {
+		results[0].CNAMEs = []string{"pool.cdn.example.net", "pool.example.org"}
+		results.RestrictCNAMEs([]networkingv1alpha1.FQDN{"example.com", "example.net"})
+		statuses := updateFQDNStatuses(
+			record.NewFakeRecorder(10), np, fqdns, previous, results, nil, 3600, 0, refreshInterval,
+		)
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].ResolveReason).To(Equal(networkingv1alpha1.NetworkPolicyResolveCNAMENotAllowed))
+		Expect(statuses[0].AddressRecords).To(BeEmpty())
+		Expect(statuses[0].CNAMEs).To(Equal([]string{"pool.cdn.example.net", "pool.example.org"}))
+	})
+})
+
+var _ = Describe("SRV statuses", func() {
+	np := &networkingv1alpha1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "srv", Namespace: "default"}}
+	names := []networkingv1alpha1.SRVName{"_ldap._tcp.corp.example.com"}
+	refreshInterval := func(*network.SRVResolverResult) time.Duration { return time.Minute }
+	previous := []networkingv1alpha1.SRVStatus{{
+		Name:               "_ldap._tcp.corp.example.com",
+		ResolveReason:      networkingv1alpha1.NetworkPolicyResolveSuccess,
+		LastSuccessfulTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+		Targets:            []networkingv1alpha1.SRVTarget{{Target: "dc1.corp.example.com", Port: 389}},
+	}}
+
+	It("should replace the targets of a successful lookup", func() {
+		results := network.SRVResolverResultList{network.NewSRVResolverResult(
+			"_ldap._tcp.corp.example.com",
+			[]*net.SRV{{Target: "DC2.corp.example.com.", Port: 636}, {Target: ".", Port: 389}},
+			time.Minute, nil,
+		)}
+		statuses := updateSRVStatuses(record.NewFakeRecorder(10), np, names, previous, results, 3600, refreshInterval)
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].Targets).To(Equal([]networkingv1alpha1.SRVTarget{{Target: "dc2.corp.example.com", Port: 636}}))
+		Expect(statuses[0].NextRefreshTime.IsZero()).To(BeFalse())
+	})
+
+	It("should keep the targets on transient errors until the retry timeout", func() {
+		results := network.SRVResolverResultList{network.NewSRVResolverResult(
+			"_ldap._tcp.corp.example.com", nil, 0, &net.DNSError{IsTimeout: true},
+		)}
+		statuses := updateSRVStatuses(record.NewFakeRecorder(10), np, names, previous, results, 3600, refreshInterval)
+		Expect(statuses[0].Targets).To(Equal(previous[0].Targets))
+
+		statuses = updateSRVStatuses(record.NewFakeRecorder(10), np, names, previous, results, 30, refreshInterval)
+		Expect(statuses[0].Targets).To(BeEmpty())
+	})
+
+	It("should render each target with its port and the protocol of the SRV name", func() {
+		np := &networkingv1alpha1.NetworkPolicy{Spec: networkingv1alpha1.NetworkPolicySpec{
+			Egresses: []networkingv1alpha1.EgressRule{{ToSRV: names}},
+		}}
+		fqdnStatuses := []networkingv1alpha1.FQDNStatus{{
+			FQDN:           "dc1.corp.example.com",
+			ResolveReason:  networkingv1alpha1.NetworkPolicyResolveSuccess,
+			AddressRecords: addressRecords("192.0.2.1/32"),
+		}}
+		networkPolicy := np.ToMultiNetworkPolicy(fqdnStatuses, previous)
+		Expect(networkPolicy.Spec.Egress).To(HaveLen(1))
+		Expect(networkPolicy.Spec.Egress[0].To[0].IPBlock.CIDR).To(Equal("192.0.2.1/32"))
+		Expect(networkPolicy.Spec.Egress[0].Ports).To(HaveLen(1))
+		Expect(*networkPolicy.Spec.Egress[0].Ports[0].Protocol).To(Equal(corev1.ProtocolTCP))
+		Expect(networkPolicy.Spec.Egress[0].Ports[0].Port.IntValue()).To(Equal(389))
+	})
+})
+
+// addressRecords returns the records of addresses last seen now
+func addressRecords(addresses ...string) []networkingv1alpha1.AddressRecord {
+	now := metav1.Now()
+	records := make([]networkingv1alpha1.AddressRecord, 0, len(addresses))
+	for _, address := range addresses {
+		records = append(records, networkingv1alpha1.AddressRecord{Address: address, FirstSeen: now, LastSeen: now})
+	}
+	return records
+}
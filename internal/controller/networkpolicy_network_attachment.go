@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// targetNetworks returns the namespaced names of the NetworkAttachmentDefinitions selected by the network policy.
+// Only MultiNetworkPolicies are bound to a target network.
+func (r *NetworkPolicyReconciler) targetNetworks(np *v1alpha1.NetworkPolicy) []client.ObjectKey {
+	if r.outputKind(np) != v1alpha1.OutputKindMultiNetworkPolicy {
+		return nil
+	}
+	return np.TargetNetworkRefs()
+}
+
+// reconcileNetworkAttachment sets the NetworkAttached condition of the network policy from the
+// NetworkAttachmentDefinitions of its target networks, reporting the first target network that is not attached. The
+// condition is removed for the output kinds without target network.
+func (r *NetworkPolicyReconciler) reconcileNetworkAttachment(ctx context.Context, np *v1alpha1.NetworkPolicy) error {
+	if r.outputKind(np) != v1alpha1.OutputKindMultiNetworkPolicy {
+		np.RemoveNetworkAttachedCondition()
+		return nil
+	}
+	keys := r.targetNetworks(np)
+	if len(keys) == 0 {
+		np.SetNetworkAttachedCondition(v1alpha1.NetworkPolicyNADNotFound, "No target network specified")
+		return nil
+	}
+
+	for _, key := range keys {
+		reason, message, err := r.checkNetworkAttachmentDefinition(ctx, key)
+		if err != nil {
+			return err
+		}
+		if reason != v1alpha1.NetworkPolicyNADFound {
+			np.SetNetworkAttachedCondition(reason, message)
+			return nil
+		}
+	}
+	np.SetNetworkAttachedCondition(v1alpha1.NetworkPolicyNADFound, "The target networks are attached.")
+	return nil
+}
+
+// checkNetworkAttachmentDefinition returns the NetworkAttached reason and message of a single target network
+func (r *NetworkPolicyReconciler) checkNetworkAttachmentDefinition(
+	ctx context.Context, key client.ObjectKey,
+) (v1alpha1.NetworkPolicyNetworkAttachedConditionReason, string, error) {
+	nad := utils.NewNetworkAttachmentDefinition()
+	err := r.Get(ctx, key, nad)
+	switch {
+	case apierrors.IsNotFound(err):
+		return v1alpha1.NetworkPolicyNADNotFound,
+			fmt.Sprintf("NetworkAttachmentDefinition %s not found", key), nil
+	case meta.IsNoMatchError(err):
+		return v1alpha1.NetworkPolicyNADNotFound,
+			fmt.Sprintf("NetworkAttachmentDefinition %s not found, the CRD is not installed", key), nil
+	case err != nil:
+		return "", "", err
+	}
+
+	cniType, err := networkAttachmentType(nad)
+	if err != nil {
+		return v1alpha1.NetworkPolicyNADInvalidConfig,
+			fmt.Sprintf("NetworkAttachmentDefinition %s has an invalid config: %s", key, err), nil
+	}
+	// Without config, Multus reads the config from a file on the nodes, so its CNI plugin cannot be checked
+	if cniType != "" && len(r.MultiNetworkPlugins) > 0 && !slices.Contains(r.MultiNetworkPlugins, cniType) {
+		return v1alpha1.NetworkPolicyNADMismatchedType, fmt.Sprintf(
+			"NetworkAttachmentDefinition %s uses the CNI plugin %q, MultiNetworkPolicies are only enforced for %v",
+			key, cniType, r.MultiNetworkPlugins,
+		), nil
+	}
+	return v1alpha1.NetworkPolicyNADFound, "", nil
+}
+
+// networkAttachmentType returns the type of the CNI plugin configured by the NetworkAttachmentDefinition. For plugin
+// lists, the type of the first plugin is returned. Returns an empty type if spec.config is empty, Multus then uses
+// the config file named like the NetworkAttachmentDefinition.
+func networkAttachmentType(nad *unstructured.Unstructured) (string, error) {
+	config, _, err := unstructured.NestedString(nad.Object, "spec", "config")
+	if err != nil || config == "" {
+		return "", err
+	}
+	var cniConfig struct {
+		Type    string `json:"type"`
+		Plugins []struct {
+			Type string `json:"type"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal([]byte(config), &cniConfig); err != nil {
+		return "", err
+	}
+	if cniConfig.Type == "" && len(cniConfig.Plugins) > 0 {
+		cniConfig.Type = cniConfig.Plugins[0].Type
+	}
+	if cniConfig.Type == "" {
+		return "", errors.New("spec.config has no CNI plugin type")
+	}
+	return cniConfig.Type, nil
+}
+
+// targetNetworkIndex indexes the network policies by the namespaced names of their target networks
+const targetNetworkIndex = "spec.targetNetworkRefs"
+
+// indexTargetNetworks returns the target networks of a network policy for targetNetworkIndex. The target networks of
+// all output kinds are indexed, as the default output kind can change at runtime.
+func indexTargetNetworks(object client.Object) []string {
+	np, ok := object.(*v1alpha1.NetworkPolicy)
+	if !ok {
+		return nil
+	}
+	refs := np.TargetNetworkRefs()
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, ref.String())
+	}
+	return keys
+}
+
+// networkPoliciesForNetworkAttachment maps a NetworkAttachmentDefinition to the network policies targeting it, so
+// they are reconciled when the NetworkAttachmentDefinition is created, updated or deleted
+func (r *NetworkPolicyReconciler) networkPoliciesForNetworkAttachment(
+	ctx context.Context, nad client.Object,
+) []reconcile.Request {
+	policies := &v1alpha1.NetworkPolicyList{}
+	err := r.List(ctx, policies, client.MatchingFields{targetNetworkIndex: client.ObjectKeyFromObject(nad).String()})
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "unable to list the network policies of a NetworkAttachmentDefinition")
+		return nil
+	}
+	key := client.ObjectKeyFromObject(nad)
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		np := &policies.Items[i]
+		if slices.Contains(r.targetNetworks(np), key) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(np)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+const (
+	// defaultMaxConcurrentReconciles is the number of policies reconciled in parallel if the OperatorConfig does not
+	// specify it
+	defaultMaxConcurrentReconciles = 5
+	// maxConcurrentReconcileWorkers is the number of workers of the policy controllers. The workers are started once,
+	// the number of workers reconciling at the same time is limited by the OperatorConfig.
+	maxConcurrentReconcileWorkers = 50
+)
+
+// OperatorSettings holds the operator-wide defaults and limits of the OperatorConfig. The settings are shared by the
+// controllers and updated without restarting them. A nil OperatorSettings behaves like an empty OperatorConfig.
+type OperatorSettings struct {
+	mu          sync.RWMutex
+	spec        v1alpha1.OperatorConfigSpec
+	limiter     *concurrencyLimiter
+	subscribers []chan event.GenericEvent
+}
+
+// NewOperatorSettings returns the settings of an empty OperatorConfig
+func NewOperatorSettings() *OperatorSettings {
+	return &OperatorSettings{limiter: newConcurrencyLimiter(defaultMaxConcurrentReconciles)}
+}
+
+// Spec returns a copy of the current OperatorConfig spec
+func (s *OperatorSettings) Spec() v1alpha1.OperatorConfigSpec {
+	if s == nil {
+		return v1alpha1.OperatorConfigSpec{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return *s.spec.DeepCopy()
+}
+
+// Update replaces the OperatorConfig spec. The subscribers are notified if the spec changed. Returns whether the spec
+// changed.
+func (s *OperatorSettings) Update(spec v1alpha1.OperatorConfigSpec) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if equality.Semantic.DeepEqual(s.spec, spec) {
+		return false
+	}
+	s.spec = *spec.DeepCopy()
+
+	limit := int(spec.MaxConcurrentReconciles)
+	if limit <= 0 {
+		limit = defaultMaxConcurrentReconciles
+	}
+	s.limiter.setLimit(limit)
+
+	for _, subscriber := range s.subscribers {
+		// A pending notification already triggers the reconciliation of all policies
+		select {
+		case subscriber <- event.GenericEvent{Object: &v1alpha1.OperatorConfig{}}:
+		default:
+		}
+	}
+	return true
+}
+
+// Load applies the OperatorConfig read with reader. It is called before the manager starts, so that the policies are
+// not reconciled with the defaults until the OperatorConfig controller catches up: their default output kind would
+// differ from the applied one and the limits would not be enforced. A missing OperatorConfig keeps the defaults.
+func (s *OperatorSettings) Load(ctx context.Context, reader client.Reader) error {
+	config := &v1alpha1.OperatorConfig{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: v1alpha1.OperatorConfigName}, config); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	s.Update(config.Spec)
+	return nil
+}
+
+// Subscribe returns a channel receiving an event whenever the OperatorConfig spec changes
+func (s *OperatorSettings) Subscribe() <-chan event.GenericEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subscriber := make(chan event.GenericEvent, 1)
+	s.subscribers = append(s.subscribers, subscriber)
+	return subscriber
+}
+
+// acquire blocks until the number of running reconciliations is below MaxConcurrentReconciles. The returned function
+// must be called when the reconciliation is done.
+func (s *OperatorSettings) acquire(ctx context.Context) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	if err := s.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return s.limiter.release, nil
+}
+
+// concurrencyLimiter is a semaphore whose limit can be changed while it is held
+type concurrencyLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	// changed is closed and replaced whenever a slot is released or the limit changes
+	changed chan struct{}
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	return &concurrencyLimiter{limit: limit, changed: make(chan struct{})}
+}
+
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.notify()
+}
+
+func (l *concurrencyLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.notify()
+}
+
+// notify wakes up the waiting acquirers, l.mu must be held
+func (l *concurrencyLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// OperatorConfigReconciler reconciles the OperatorConfig named cluster into the OperatorSettings
+type OperatorConfigReconciler struct {
+	client.Client
+	Settings *OperatorSettings
+}
+
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=operatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=operatorconfigs/status,verbs=get;update;patch
+
+// Reconcile applies the OperatorConfig to the OperatorSettings. The settings are reset to the defaults when the
+// OperatorConfig is removed.
+func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if req.Name != v1alpha1.OperatorConfigName {
+		return ctrl.Result{}, nil
+	}
+	config := &v1alpha1.OperatorConfig{}
+	if err := r.Get(ctx, req.NamespacedName, config); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if r.Settings.Update(v1alpha1.OperatorConfigSpec{}) {
+			logf.FromContext(ctx).Info("Operator configuration removed, using the defaults")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if r.Settings.Update(config.Spec) {
+		logf.FromContext(ctx).Info("Operator configuration updated", "generation", config.Generation)
+	}
+	if config.Status.ObservedGeneration == config.Generation {
+		return ctrl.Result{}, nil
+	}
+	config.Status.ObservedGeneration = config.Generation
+	return ctrl.Result{}, r.Status().Update(ctx, config)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.OperatorConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("fqdn-egress-operator-config").
+		Complete(r)
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/network"
+)
+
+var _ = Describe("OperatorConfig Controller", func() {
+	var settings *OperatorSettings
+
+	BeforeEach(func() {
+		settings = NewOperatorSettings()
+	})
+
+	It("should apply the operator configuration and reset it when removed", func() {
+		config := &networkingv1alpha1.OperatorConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: networkingv1alpha1.OperatorConfigName},
+			Spec: networkingv1alpha1.OperatorConfigSpec{
+				MinTTLSeconds:     10,
+				DefaultOutputKind: networkingv1alpha1.OutputKindNetworkPolicy,
+			},
+		}
+		Expect(k8sClient.Create(ctx, config)).To(Succeed())
+		defer func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, config))).To(Succeed())
+		}()
+		events := settings.Subscribe()
+		reconciler := &OperatorConfigReconciler{Client: k8sClient, Settings: settings}
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(config)}
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(settings.Spec().MinTTLSeconds).To(Equal(int32(10)))
+		Expect(settings.Spec().DefaultOutputKind).To(Equal(networkingv1alpha1.OutputKindNetworkPolicy))
+		Expect(events).To(Receive())
+
+		Expect(k8sClient.Get(ctx, request.NamespacedName, config)).To(Succeed())
+		Expect(config.Status.ObservedGeneration).To(Equal(config.Generation))
+
+		By("not notifying the subscribers if the configuration is unchanged")
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).NotTo(Receive())
+
+		Expect(k8sClient.Delete(ctx, config)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(settings.Spec()).To(Equal(networkingv1alpha1.OperatorConfigSpec{}))
+		Expect(events).To(Receive())
+	})
+
+	It("should keep the applied output kind of policies without output kind across a restart", func() {
+		config := &networkingv1alpha1.OperatorConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: networkingv1alpha1.OperatorConfigName},
+			Spec:       networkingv1alpha1.OperatorConfigSpec{DefaultOutputKind: networkingv1alpha1.OutputKindNetworkPolicy},
+		}
+		Expect(k8sClient.Create(ctx, config)).To(Succeed())
+		defer func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, config))).To(Succeed())
+		}()
+
+		Expect(settings.Load(ctx, k8sClient)).To(Succeed())
+		deleted := map[networkingv1alpha1.OutputKind]int{}
+		reconciler := &NetworkPolicyReconciler{Settings: settings, Backends: map[networkingv1alpha1.OutputKind]Backend{}}
+		for _, kind := range []networkingv1alpha1.OutputKind{
+			networkingv1alpha1.OutputKindMultiNetworkPolicy, networkingv1alpha1.OutputKindNetworkPolicy,
+			networkingv1alpha1.OutputKindCiliumNetworkPolicy, networkingv1alpha1.OutputKindEgressFirewall,
+		} {
+			reconciler.Backends[kind] = &recordingBackend{deleted: deleted, kind: kind}
+		}
+		np := &networkingv1alpha1.NetworkPolicy{Status: networkingv1alpha1.NetworkPolicyStatus{
+			AppliedOutputKind: networkingv1alpha1.OutputKindNetworkPolicy,
+		}}
+		Expect(reconciler.reconcileNetworkPolicyCreation(ctx, np)).To(Succeed())
+		Expect(deleted).To(BeEmpty())
+		Expect(np.Status.AppliedOutputKind).To(Equal(networkingv1alpha1.OutputKindNetworkPolicy))
+
+		By("keeping the defaults without operator configuration")
+		Expect(k8sClient.Delete(ctx, config)).To(Succeed())
+		settings = NewOperatorSettings()
+		Expect(settings.Load(ctx, k8sClient)).To(Succeed())
+		Expect(settings.Spec()).To(Equal(networkingv1alpha1.OperatorConfigSpec{}))
+	})
+
+	It("should reject operator configurations not named cluster", func() {
+		config := &networkingv1alpha1.OperatorConfig{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+		Expect(k8sClient.Create(ctx, config)).NotTo(Succeed())
+	})
+
+	It("should resize the limit of concurrent reconciliations", func() {
+		settings.Update(networkingv1alpha1.OperatorConfigSpec{MaxConcurrentReconciles: 1})
+		release, err := settings.acquire(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = settings.acquire(timeoutCtx)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+
+		acquired := make(chan func())
+		go func() {
+			defer GinkgoRecover()
+			release, err := settings.acquire(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			acquired <- release
+		}()
+		Consistently(acquired, 50*time.Millisecond).ShouldNot(Receive())
+		settings.Update(networkingv1alpha1.OperatorConfigSpec{MaxConcurrentReconciles: 2})
+		var second func()
+		Eventually(acquired).Should(Receive(&second))
+
+		release()
+		second()
+	})
+
+	It("should apply the TTL bounds and the default output kind", func() {
+		reconciler := &NetworkPolicyReconciler{
+			MinRefreshInterval: 5 * time.Second,
+			MaxRefreshInterval: 30 * time.Minute,
+			Settings:           settings,
+		}
+		np := &networkingv1alpha1.NetworkPolicy{}
+		Expect(reconciler.clampRefreshInterval(time.Second)).To(Equal(5 * time.Second))
+		Expect(reconciler.clampRefreshInterval(time.Hour)).To(Equal(30 * time.Minute))
+		Expect(reconciler.outputKind(np)).To(Equal(networkingv1alpha1.OutputKindMultiNetworkPolicy))
+
+		settings.Update(networkingv1alpha1.OperatorConfigSpec{
+			MinTTLSeconds:     60,
+			MaxTTLSeconds:     600,
+			DefaultOutputKind: networkingv1alpha1.OutputKindCiliumNetworkPolicy,
+		})
+		Expect(reconciler.clampRefreshInterval(time.Second)).To(Equal(time.Minute))
+		Expect(reconciler.clampRefreshInterval(time.Hour)).To(Equal(10 * time.Minute))
+		Expect(reconciler.outputKind(np)).To(Equal(networkingv1alpha1.OutputKindCiliumNetworkPolicy))
+
+		np.Spec.OutputKind = networkingv1alpha1.OutputKindNetworkPolicy
+		Expect(reconciler.outputKind(np)).To(Equal(networkingv1alpha1.OutputKindNetworkPolicy))
+	})
+
+	It("should enforce the FQDN and address limits per policy", func() {
+		reconciler := &NetworkPolicyReconciler{
+			Client:        k8sClient,
+			Scheme:        k8sClient.Scheme(),
+			EventRecorder: record.NewFakeRecorder(10),
+			DNSResolver:   &network.FakeDNSResolver{},
+			Settings:      settings,
+		}
+		settings.Update(networkingv1alpha1.OperatorConfigSpec{MaxFQDNsPerPolicy: 1, MaxAddressesPerPolicy: 2})
+		np := &networkingv1alpha1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "limited", Namespace: "default"},
+			Spec: networkingv1alpha1.NetworkPolicySpec{
+				Egresses: []networkingv1alpha1.EgressRule{{
+					ToFQDNs: []networkingv1alpha1.FQDN{"www.example.com", "www.example.org"},
+				}},
+			},
+		}
+
+		_, err := reconciler.resolveFQDNs(ctx, np, np)
+		var sizeErr *SizeLimitError
+		Expect(errors.As(err, &sizeErr)).To(BeTrue())
+		Expect(sizeErr.Items).To(Equal("FQDNs"))
+		Expect(readyReasonForError(err)).To(Equal(networkingv1alpha1.NetworkPolicySizeLimitExceeded))
+
+		np.Status.AppliedAddressCount = 3
+		err = reconciler.reconcileNetworkPolicyCreation(ctx, np)
+		Expect(errors.As(err, &sizeErr)).To(BeTrue())
+		Expect(sizeErr.Items).To(Equal("addresses"))
+
+		By("reporting the size limit without requeueing")
+		np.Status = networkingv1alpha1.NetworkPolicyStatus{}
+		Expect(k8sClient.Create(ctx, np)).To(Succeed())
+		defer func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, np))).To(Succeed())
+		}()
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(np)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(reconcile.Result{}))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(np), np)).To(Succeed())
+		ready := meta.FindStatusCondition(np.Status.Conditions, string(networkingv1alpha1.NetworkPolicyReadyCondition))
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Reason).To(Equal(string(networkingv1alpha1.NetworkPolicySizeLimitExceeded)))
+	})
+})
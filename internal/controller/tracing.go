@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the reconciliations. Spans are only exported if a tracer provider is installed.
+var tracer = otel.Tracer("github.com/mransonwang/fqdn-egress-operator/internal/controller")
+
+// recordSpanError marks the span as failed if err is not nil
+func recordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var fqdngovernancepolicylog = logf.Log.WithName("fqdngovernancepolicy-resource")
+
+// SetupFQDNGovernancePolicyWebhookWithManager registers the webhook for FQDNGovernancePolicy in the manager.
+func SetupFQDNGovernancePolicyWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&networkingv1alpha1.FQDNGovernancePolicy{}).
+		WithValidator(&FQDNGovernancePolicyCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-networking-turbosimone-com-v1alpha1-fqdngovernancepolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=networking.turbosimone.com,resources=fqdngovernancepolicies,verbs=create;update,versions=v1alpha1,name=vfqdngovernancepolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// FQDNGovernancePolicyCustomValidator rejects FQDNGovernancePolicies whose regexes do not compile or whose namespace
+// selector is invalid. The controller denies all FQDNs of such a policy, rejecting it at admission reports the mistake
+// before it blocks the governed namespaces.
+type FQDNGovernancePolicyCustomValidator struct{}
+
+var _ webhook.CustomValidator = &FQDNGovernancePolicyCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type FQDNGovernancePolicy.
+func (v *FQDNGovernancePolicyCustomValidator) ValidateCreate(
+	_ context.Context, obj runtime.Object,
+) (admission.Warnings, error) {
+	policy, ok := obj.(*networkingv1alpha1.FQDNGovernancePolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a FQDNGovernancePolicy object but got %T", obj)
+	}
+	fqdngovernancepolicylog.Info("Validation for FQDNGovernancePolicy upon creation", "name", policy.GetName())
+
+	return nil, validateFQDNGovernancePolicy(policy)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type FQDNGovernancePolicy.
+func (v *FQDNGovernancePolicyCustomValidator) ValidateUpdate(
+	_ context.Context, _, newObj runtime.Object,
+) (admission.Warnings, error) {
+	policy, ok := newObj.(*networkingv1alpha1.FQDNGovernancePolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a FQDNGovernancePolicy object for the newObj but got %T", newObj)
+	}
+	fqdngovernancepolicylog.Info("Validation for FQDNGovernancePolicy upon update", "name", policy.GetName())
+
+	if !policy.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, validateFQDNGovernancePolicy(policy)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type FQDNGovernancePolicy.
+func (v *FQDNGovernancePolicyCustomValidator) ValidateDelete(
+	_ context.Context, _ runtime.Object,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateFQDNGovernancePolicy returns an error for each regex of the policy that does not compile, compiled the way
+// the controller compiles it, and for an invalid namespace selector
+func validateFQDNGovernancePolicy(policy *networkingv1alpha1.FQDNGovernancePolicy) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	compile := func(path *field.Path, exprs []string) {
+		for i, expr := range exprs {
+			if _, err := networkingv1alpha1.CompileFQDNRegex(expr); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Index(i), expr, err.Error()))
+			}
+		}
+	}
+	compile(specPath.Child("allowedRegexes"), policy.Spec.AllowedRegexes)
+	compile(specPath.Child("deniedRegexes"), policy.Spec.DeniedRegexes)
+
+	if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector); err != nil {
+		allErrs = append(allErrs, field.Invalid(
+			specPath.Child("namespaceSelector"), policy.Spec.NamespaceSelector, err.Error(),
+		))
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(
+			networkingv1alpha1.GroupVersion.WithKind("FQDNGovernancePolicy").GroupKind(), policy.Name, allErrs,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var _ = Describe("FQDNGovernancePolicy Webhook", func() {
+	var (
+		obj       *networkingv1alpha1.FQDNGovernancePolicy
+		validator FQDNGovernancePolicyCustomValidator
+	)
+
+	BeforeEach(func() {
+		obj = &networkingv1alpha1.FQDNGovernancePolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "governance-webhook"},
+			Spec: networkingv1alpha1.FQDNGovernancePolicySpec{
+				AllowedRegexes: []string{`[a-z]+\.example\.com`},
+				DeniedRegexes:  []string{`admin\..*`},
+			},
+		}
+		validator = FQDNGovernancePolicyCustomValidator{}
+	})
+
+	Context("When creating or updating FQDNGovernancePolicy under Validating Webhook", func() {
+		It("Should admit a valid governance policy", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny regexes that do not compile", func() {
+			obj.Spec.DeniedRegexes = append(obj.Spec.DeniedRegexes, "(")
+			_, err := validator.ValidateUpdate(ctx, obj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("spec.deniedRegexes[1]")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.allowedRegexes")))
+		})
+
+		It("Should deny an invalid namespace selector", func() {
+			obj.Spec.NamespaceSelector.MatchExpressions = []metav1.LabelSelectorRequirement{
+				{Key: "tenant", Operator: "Matches"},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.namespaceSelector")))
+		})
+
+		It("Should be registered for the FQDNGovernancePolicy resource", func() {
+			obj.Spec.AllowedRegexes = []string{"["}
+			Expect(apierrors.IsInvalid(k8sClient.Create(ctx, obj))).To(BeTrue())
+		})
+	})
+})
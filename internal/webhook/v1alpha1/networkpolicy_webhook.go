@@ -0,0 +1,401 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var networkpolicylog = logf.Log.WithName("networkpolicy-resource")
+
+// SetupNetworkPolicyWebhookWithManager registers the webhook for NetworkPolicy in the manager.
+func SetupNetworkPolicyWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&networkingv1alpha1.NetworkPolicy{}).
+		WithValidator(&NetworkPolicyCustomValidator{Client: mgr.GetClient()}).
+		WithDefaulter(&NetworkPolicyCustomDefaulter{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-networking-turbosimone-com-v1alpha1-networkpolicy,mutating=true,failurePolicy=fail,sideEffects=None,groups=networking.turbosimone.com,resources=networkpolicies,verbs=create;update,versions=v1alpha1,name=mnetworkpolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=networkpolicydefaults,verbs=get;list;watch
+
+// NetworkPolicyCustomDefaulter sets the resolve settings that NetworkPolicies do not specify from the
+// NetworkPolicyDefaults of their namespace, or from the built-in defaults. The resolver of the NetworkPolicyDefaults
+// is not applied in namespaces whose FQDNGovernancePolicies forbid custom resolvers.
+type NetworkPolicyCustomDefaulter struct {
+	// Client reads the NetworkPolicyDefaults of the namespaces and the FQDNGovernancePolicies
+	Client client.Reader
+}
+
+var _ webhook.CustomDefaulter = &NetworkPolicyCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type NetworkPolicy.
+func (d *NetworkPolicyCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	networkpolicy, ok := obj.(*networkingv1alpha1.NetworkPolicy)
+	if !ok {
+		return fmt.Errorf("expected a NetworkPolicy object but got %T", obj)
+	}
+	networkpolicylog.Info("Defaulting for NetworkPolicy", "name", networkpolicy.GetName())
+
+	defaults, err := utils.GetNetworkPolicyDefaults(ctx, d.Client, networkpolicy.Namespace)
+	if err != nil {
+		return err
+	}
+	if defaults != nil && defaults.Resolver != nil {
+		// The resolver of NetworkPolicyDefaults written before custom resolvers were forbidden is not applied
+		governance, err := utils.GetFQDNGovernance(ctx, d.Client, networkpolicy.Namespace)
+		if err != nil {
+			return err
+		}
+		if governance.ForbidsCustomResolvers() {
+			defaults = defaults.DeepCopy()
+			defaults.Resolver = nil
+		}
+	}
+	networkpolicy.ApplyDefaults(defaults)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-networking-turbosimone-com-v1alpha1-networkpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=networking.turbosimone.com,resources=networkpolicies,verbs=create;update,versions=v1alpha1,name=vnetworkpolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=network-attachment-definitions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=operatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.turbosimone.com,resources=fqdngovernancepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// NetworkPolicyCustomValidator validates the cross-field rules of NetworkPolicies that cannot be expressed in the
+// CRD schema, that the NetworkAttachmentDefinition of the target network exists and that the FQDNs comply with the
+// FQDNGovernancePolicies of the namespace.
+type NetworkPolicyCustomValidator struct {
+	// Client reads the NetworkAttachmentDefinitions of the target networks, the OperatorConfig holding the default
+	// output kind and the FQDNGovernancePolicies
+	Client client.Reader
+}
+
+var _ webhook.CustomValidator = &NetworkPolicyCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type NetworkPolicy.
+func (v *NetworkPolicyCustomValidator) ValidateCreate(
+	ctx context.Context, obj runtime.Object,
+) (admission.Warnings, error) {
+	networkpolicy, ok := obj.(*networkingv1alpha1.NetworkPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a NetworkPolicy object but got %T", obj)
+	}
+	networkpolicylog.Info("Validation for NetworkPolicy upon creation", "name", networkpolicy.GetName())
+
+	return v.validate(ctx, networkpolicy, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type NetworkPolicy.
+func (v *NetworkPolicyCustomValidator) ValidateUpdate(
+	ctx context.Context, oldObj, newObj runtime.Object,
+) (admission.Warnings, error) {
+	networkpolicy, ok := newObj.(*networkingv1alpha1.NetworkPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a NetworkPolicy object for the newObj but got %T", newObj)
+	}
+	oldNetworkPolicy, ok := oldObj.(*networkingv1alpha1.NetworkPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a NetworkPolicy object for the oldObj but got %T", oldObj)
+	}
+	networkpolicylog.Info("Validation for NetworkPolicy upon update", "name", networkpolicy.GetName())
+
+	// Do not block the removal of a network policy, e.g. by the garbage collector
+	if !networkpolicy.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return v.validate(ctx, networkpolicy, oldNetworkPolicy)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type NetworkPolicy.
+func (v *NetworkPolicyCustomValidator) ValidateDelete(
+	_ context.Context, _ runtime.Object,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns the warnings and the errors for the network policy. old is the previous version of the network
+// policy on updates, the target network is only looked up if it changed so that removing a
+// NetworkAttachmentDefinition does not prevent updates of the network policies using it.
+func (v *NetworkPolicyCustomValidator) validate(
+	ctx context.Context, np, old *networkingv1alpha1.NetworkPolicy,
+) (admission.Warnings, error) {
+	var allErrs field.ErrorList
+	var warnings admission.Warnings
+	specPath := field.NewPath("spec")
+
+	if np.Spec.ResolveTimeoutSeconds > 0 && np.Spec.TTLSeconds > 0 &&
+		np.Spec.ResolveTimeoutSeconds >= np.Spec.TTLSeconds {
+		allErrs = append(allErrs, field.Invalid(
+			specPath.Child("resolveTimeoutSeconds"), np.Spec.ResolveTimeoutSeconds,
+			fmt.Sprintf("must be less than ttlSeconds (%d)", np.Spec.TTLSeconds),
+		))
+	}
+
+	defaultKind, err := v.defaultOutputKind(ctx)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+	outputKind := np.OutputKindOrDefault(defaultKind)
+	if outputKind == networkingv1alpha1.OutputKindMultiNetworkPolicy {
+		allErrs = append(allErrs, v.validateTargetNetworks(ctx, np, old)...)
+	}
+	if outputKind == networkingv1alpha1.OutputKindEgressFirewall && np.Spec.OutputKind == "" {
+		allErrs = append(allErrs, validateEgressFirewall(np)...)
+	}
+
+	allErrs = append(allErrs, validateFQDNs(np)...)
+
+	governanceErrs, err := v.validateGovernance(ctx, np)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+	allErrs = append(allErrs, governanceErrs...)
+
+	if outputKind != networkingv1alpha1.OutputKindEgressFirewall &&
+		len(np.Spec.MatchLabels) == 0 && len(np.Spec.MatchExpressions) == 0 {
+		warnings = append(warnings,
+			"spec.matchLabels and spec.matchExpressions are empty, the network policy applies to all pods of the namespace")
+	}
+	for i, rule := range np.Spec.Egresses {
+		for j, peer := range rule.ToCIDRs {
+			if peer.CIDR == "0.0.0.0/0" || peer.CIDR == "::/0" {
+				warnings = append(warnings, fmt.Sprintf(
+					"%s allows traffic to all destinations", specPath.Child("egress").Index(i).Child("toCIDRs").Index(j),
+				))
+			}
+		}
+	}
+
+	if len(allErrs) > 0 {
+		return warnings, apierrors.NewInvalid(
+			networkingv1alpha1.GroupVersion.WithKind("NetworkPolicy").GroupKind(), np.Name, allErrs,
+		)
+	}
+	return warnings, nil
+}
+
+// defaultOutputKind returns the default output kind of the OperatorConfig, or an empty kind if there is no
+// OperatorConfig
+func (v *NetworkPolicyCustomValidator) defaultOutputKind(ctx context.Context) (networkingv1alpha1.OutputKind, error) {
+	if v.Client == nil {
+		return "", nil
+	}
+	config := &networkingv1alpha1.OperatorConfig{}
+	err := v.Client.Get(ctx, client.ObjectKey{Name: networkingv1alpha1.OperatorConfigName}, config)
+	switch {
+	case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	return config.Spec.DefaultOutputKind, nil
+}
+
+// validateEgressFirewall returns an error for the rules of a network policy that EgressFirewalls cannot enforce. The
+// CRD schema only rejects them if the output kind is set explicitly, not if it defaults to EgressFirewall.
+func validateEgressFirewall(np *networkingv1alpha1.NetworkPolicy) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if len(np.Spec.Ingresses) > 0 {
+		allErrs = append(allErrs, field.Forbidden(
+			specPath.Child("ingress"), "ingress rules are not supported by EgressFirewalls",
+		))
+	}
+	for i, rule := range np.Spec.Egresses {
+		for j, port := range rule.Ports {
+			if port.EndPort != nil && *port.EndPort != port.Port {
+				allErrs = append(allErrs, field.Forbidden(
+					specPath.Child("egress").Index(i).Child("ports").Index(j).Child("endPort"),
+					"port ranges are not supported by EgressFirewalls",
+				))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateTargetNetworks returns an error for each target network that is not a valid reference or whose
+// NetworkAttachmentDefinition does not exist. Target networks already used by the old network policy are not looked up.
+func (v *NetworkPolicyCustomValidator) validateTargetNetworks(
+	ctx context.Context, np, old *networkingv1alpha1.NetworkPolicy,
+) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if np.Spec.TargetNetwork == "" && len(np.Spec.TargetNetworks) == 0 {
+		return append(allErrs, field.Required(
+			specPath.Child("targetNetwork"),
+			"targetNetwork or targetNetworks is required when outputKind is MultiNetworkPolicy",
+		))
+	}
+
+	var known []types.NamespacedName
+	if old != nil {
+		known = old.TargetNetworkRefs()
+	}
+	check := func(path *field.Path, name string) {
+		errCount := len(allErrs)
+		ref := types.NamespacedName{Namespace: np.Namespace, Name: name}
+		if namespace, name, ok := strings.Cut(name, "/"); ok {
+			ref = types.NamespacedName{Namespace: namespace, Name: name}
+			for _, msg := range validation.IsDNS1123Label(namespace) {
+				allErrs = append(allErrs, field.Invalid(path, ref.String(), "invalid namespace: "+msg))
+			}
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(path, ref.String(), "invalid name: "+msg))
+		}
+		if len(allErrs) > errCount || slices.Contains(known, ref) {
+			return
+		}
+		if err := v.validateTargetNetwork(ctx, ref, path); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	if np.Spec.TargetNetwork != "" {
+		check(specPath.Child("targetNetwork"), np.Spec.TargetNetwork)
+	}
+	for i, name := range np.Spec.TargetNetworks {
+		check(specPath.Child("targetNetworks").Index(i), name)
+	}
+	return allErrs
+}
+
+// validateTargetNetwork returns an error if the NetworkAttachmentDefinition of the target network does not exist
+func (v *NetworkPolicyCustomValidator) validateTargetNetwork(
+	ctx context.Context, ref types.NamespacedName, path *field.Path,
+) *field.Error {
+	if v.Client == nil {
+		return nil
+	}
+	nad := utils.NewNetworkAttachmentDefinition()
+	err := v.Client.Get(ctx, ref, nad)
+	switch {
+	case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+		return field.NotFound(path, ref.String())
+	case err != nil:
+		return field.InternalError(path, err)
+	}
+	return nil
+}
+
+// validateFQDNs returns an error for each FQDN, wildcard pattern and SRV name of the network policy that is not valid
+func validateFQDNs(np *networkingv1alpha1.NetworkPolicy) field.ErrorList {
+	var allErrs field.ErrorList
+	invalid := func(path *field.Path, fqdns []networkingv1alpha1.FQDN) {
+		for i, fqdn := range fqdns {
+			if !fqdn.Valid() {
+				allErrs = append(allErrs, field.Invalid(path.Index(i), fqdn, "must be a valid FQDN"))
+			}
+		}
+	}
+
+	for i, rule := range np.Spec.Egresses {
+		rulePath := field.NewPath("spec", "egress").Index(i)
+		invalid(rulePath.Child("toFQDNs"), rule.ToFQDNs)
+		invalid(rulePath.Child("knownFQDNs"), rule.KnownFQDNs)
+		for j, pattern := range rule.ToFQDNPatterns {
+			if !pattern.Valid() {
+				allErrs = append(allErrs, field.Invalid(
+					rulePath.Child("toFQDNPatterns").Index(j), pattern, "must be a valid wildcard pattern",
+				))
+			}
+		}
+		for j, name := range rule.ToSRV {
+			if !name.Valid() {
+				allErrs = append(allErrs, field.Invalid(
+					rulePath.Child("toSRV").Index(j), name, "must be a valid SRV name",
+				))
+			}
+		}
+	}
+	for i, rule := range np.Spec.Ingresses {
+		invalid(field.NewPath("spec", "ingress").Index(i).Child("fromFQDNs"), rule.FromFQDNs)
+	}
+	return allErrs
+}
+
+// validateGovernance returns an error for each FQDN of the network policy that breaks the FQDNGovernancePolicies of
+// its namespace. Wildcard patterns are checked by their base domain and SRV names by their domain, the FQDNs they
+// expand to are checked again when they are resolved. The FQDNGovernancePolicies may also forbid a resolver of their
+// own and CIDRs, which would allow destinations the FQDNGovernancePolicies do not govern.
+func (v *NetworkPolicyCustomValidator) validateGovernance(
+	ctx context.Context, np *networkingv1alpha1.NetworkPolicy,
+) (field.ErrorList, error) {
+	governance, err := utils.GetFQDNGovernance(ctx, v.Client, np.Namespace)
+	if err != nil || governance == nil {
+		return nil, err
+	}
+	var allErrs field.ErrorList
+	check := func(path *field.Path, name string, fqdn networkingv1alpha1.FQDN) {
+		if violation := governance.Violation(fqdn); violation != "" {
+			allErrs = append(allErrs, field.Forbidden(path, fmt.Sprintf("%s is %s", name, violation)))
+		}
+	}
+	denied := func(path *field.Path, fqdns []networkingv1alpha1.FQDN) {
+		for i, fqdn := range fqdns {
+			check(path.Index(i), string(fqdn), fqdn)
+		}
+	}
+
+	if np.Spec.Resolver != nil && governance.ForbidsCustomResolvers() {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath("spec", "resolver"),
+			"the FQDNGovernancePolicies of the namespace forbid custom resolvers, the cluster resolver must be used",
+		))
+	}
+	for i, rule := range np.Spec.Egresses {
+		rulePath := field.NewPath("spec", "egress").Index(i)
+		denied(rulePath.Child("toFQDNs"), rule.ToFQDNs)
+		for j, pattern := range rule.ToFQDNPatterns {
+			check(rulePath.Child("toFQDNPatterns").Index(j), string(pattern), pattern.Base())
+		}
+		for j, name := range rule.ToSRV {
+			check(rulePath.Child("toSRV").Index(j), string(name), name.Domain())
+		}
+		if len(rule.ToCIDRs) > 0 && governance.ForbidsCIDRs() {
+			allErrs = append(allErrs, field.Forbidden(
+				rulePath.Child("toCIDRs"), "the FQDNGovernancePolicies of the namespace forbid CIDRs, only FQDNs may be used",
+			))
+		}
+	}
+	for i, rule := range np.Spec.Ingresses {
+		denied(field.NewPath("spec", "ingress").Index(i).Child("fromFQDNs"), rule.FromFQDNs)
+	}
+	return allErrs, nil
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+)
+
+var _ = Describe("NetworkPolicy Webhook", func() {
+	var (
+		obj       *networkingv1alpha1.NetworkPolicy
+		oldObj    *networkingv1alpha1.NetworkPolicy
+		validator NetworkPolicyCustomValidator
+	)
+
+	BeforeEach(func() {
+		obj = &networkingv1alpha1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+			Spec: networkingv1alpha1.NetworkPolicySpec{
+				TargetNetwork: "localnet",
+				MatchLabels: []networkingv1alpha1.MatchLabel{
+					{Label: networkingv1alpha1.LabelWithKubernetesAppName, Value: "web"},
+				},
+				Egresses: []networkingv1alpha1.EgressRule{{
+					ToFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+					Ports:   []networkingv1alpha1.MultiNetworkPolicyPort{{Protocol: "TCP", Port: 443}},
+				}},
+				ResolveTimeoutSeconds: 3,
+				TTLSeconds:            60,
+			},
+		}
+		oldObj = obj.DeepCopy()
+		validator = NetworkPolicyCustomValidator{Client: k8sClient}
+
+		nad := utils.NewNetworkAttachmentDefinition()
+		nad.SetName("localnet")
+		nad.SetNamespace("default")
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, nad))).To(Succeed())
+	})
+
+	Context("When creating or updating NetworkPolicy under Validating Webhook", func() {
+		It("Should admit a valid network policy", func() {
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("Should deny a resolve timeout not less than the TTL", func() {
+			obj.Spec.ResolveTimeoutSeconds = 60
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.resolveTimeoutSeconds"))
+		})
+
+		It("Should deny an empty or missing target network", func() {
+			obj.Spec.TargetNetwork = ""
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.targetNetwork: Required value")))
+
+			obj.Spec.TargetNetwork = "missing"
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.targetNetwork: Not found")))
+		})
+
+		It("Should not look up an unchanged target network on update", func() {
+			obj.Spec.TargetNetwork = "removed"
+			oldObj.Spec.TargetNetwork = "removed"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should validate each entry of the target networks", func() {
+			obj.Spec.TargetNetwork = ""
+			obj.Spec.TargetNetworks = []string{"localnet", "default/localnet", "storage/vlan", "Invalid_Name"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.targetNetworks[2]: Not found")))
+			Expect(err).To(MatchError(ContainSubstring("spec.targetNetworks[3]: Invalid value")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.targetNetworks[0]")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.targetNetworks[1]")))
+		})
+
+		It("Should deny invalid FQDNs", func() {
+			obj.Spec.Egresses[0].ToFQDNs = append(obj.Spec.Egresses[0].ToFQDNs, "-invalid-.example.com")
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.egress[0].toFQDNs[1]")))
+		})
+
+		It("Should deny invalid SRV names", func() {
+			obj.Spec.Egresses[0].ToSRV = []networkingv1alpha1.SRVName{
+				"_ldap._tcp.corp.example.com", "_ldap._icmp.corp.example.com",
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.egress[0].toSRV[1]")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.egress[0].toSRV[0]")))
+		})
+
+		It("Should warn about network policies selecting all pods", func() {
+			obj.Spec.MatchLabels = nil
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("Should validate the default output kind of the operator configuration", func() {
+			config := &networkingv1alpha1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: networkingv1alpha1.OperatorConfigName},
+				Spec:       networkingv1alpha1.OperatorConfigSpec{DefaultOutputKind: networkingv1alpha1.OutputKindEgressFirewall},
+			}
+			Expect(k8sClient.Create(ctx, config)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, config)).To(Succeed())
+			}()
+
+			obj.Spec.TargetNetwork = ""
+			obj.Spec.Ingresses = []networkingv1alpha1.IngressRule{{
+				FromFQDNs: []networkingv1alpha1.FQDN{"www.example.com"},
+			}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.ingress: Forbidden")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.targetNetwork")))
+
+			obj.Spec.OutputKind = networkingv1alpha1.OutputKindNetworkPolicy
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny FQDNs breaking the governance policies of the namespace", func() {
+			policy := &networkingv1alpha1.FQDNGovernancePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "webhook"},
+				Spec: networkingv1alpha1.FQDNGovernancePolicySpec{
+					DeniedSuffixes: []networkingv1alpha1.FQDN{"example.org"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+			}()
+
+			obj.Spec.Egresses[0].ToFQDNs = append(obj.Spec.Egresses[0].ToFQDNs, "api.example.org")
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.egress[0].toFQDNs[1]: Forbidden")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.egress[0].toFQDNs[0]")))
+
+			By("checking the base domain of wildcard patterns and the domain of SRV names")
+			obj.Spec.Egresses[0].ToFQDNs = obj.Spec.Egresses[0].ToFQDNs[:1]
+			obj.Spec.Egresses[0].ToFQDNPatterns = []networkingv1alpha1.FQDNPattern{"*.example.com", "**.example.org"}
+			obj.Spec.Egresses[0].ToSRV = []networkingv1alpha1.SRVName{"_ldap._tcp.example.org"}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.egress[0].toFQDNPatterns[1]: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.egress[0].toSRV[0]: Forbidden")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.egress[0].toFQDNPatterns[0]")))
+
+			By("admitting the resolver and the CIDRs of the network policy unless forbidden")
+			obj.Spec.Egresses[0].ToFQDNPatterns = nil
+			obj.Spec.Egresses[0].ToSRV = nil
+			obj.Spec.Egresses[0].ToCIDRs = []networkingv1alpha1.CIDRPeer{{CIDR: "192.0.2.0/24"}}
+			obj.Spec.Resolver = &networkingv1alpha1.ResolverConfig{Nameservers: []string{"192.0.2.53"}}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("denying the resolver and the CIDRs of the network policy if forbidden")
+			policy.Spec.ForbidCIDRs = true
+			policy.Spec.ForbidCustomResolvers = true
+			Expect(k8sClient.Update(ctx, policy)).To(Succeed())
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.resolver: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.egress[0].toCIDRs: Forbidden")))
+
+			By("ignoring governance policies not selecting the namespace")
+			policy.Spec.NamespaceSelector.MatchLabels = map[string]string{"tenant": "true"}
+			Expect(k8sClient.Update(ctx, policy)).To(Succeed())
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny values for the Exists and DoesNotExist operators", func() {
+			for _, operator := range []metav1.LabelSelectorOperator{
+				metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist,
+			} {
+				obj.Spec.MatchExpressions = []networkingv1alpha1.LabelSelectorRequirement{
+					{Key: "example.com/tier", Operator: operator, Values: []networkingv1alpha1.LabelValue{"api"}},
+				}
+				err := k8sClient.Create(ctx, obj.DeepCopy())
+				Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				Expect(err).To(MatchError(ContainSubstring("values must be non-empty for the In and NotIn operators")))
+			}
+
+			By("admitting them without values")
+			obj.Spec.MatchExpressions[0].Values = nil
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, obj)).To(Succeed())
+		})
+
+		It("Should deny the In and NotIn operators without values", func() {
+			obj.Spec.MatchExpressions = []networkingv1alpha1.LabelSelectorRequirement{
+				{Key: "example.com/tier", Operator: metav1.LabelSelectorOpNotIn},
+			}
+			err := k8sClient.Create(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("values must be non-empty for the In and NotIn operators")))
+		})
+
+		It("Should be registered for the NetworkPolicy resource", func() {
+			obj.Spec.ResolveTimeoutSeconds = 60
+			Expect(apierrors.IsInvalid(k8sClient.Create(ctx, obj))).To(BeTrue())
+		})
+	})
+
+	Context("When creating or updating NetworkPolicy under Defaulting Webhook", func() {
+		var defaulter NetworkPolicyCustomDefaulter
+
+		BeforeEach(func() {
+			defaulter = NetworkPolicyCustomDefaulter{Client: k8sClient}
+		})
+
+		It("Should apply the built-in defaults without NetworkPolicyDefaults", func() {
+			obj.Spec.TTLSeconds = 0
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.EnabledNetworkType).To(Equal(networkingv1alpha1.IPv4))
+			Expect(obj.Spec.TTLSeconds).To(Equal(networkingv1alpha1.DefaultTTLSeconds))
+			Expect(obj.Spec.RetryTimeoutSeconds).To(Equal(networkingv1alpha1.DefaultRetryTimeoutSeconds))
+			Expect(obj.Spec.BlockPrivateIPs).To(BeNil())
+			Expect(obj.Spec.Resolver).To(BeNil())
+		})
+
+		It("Should apply the NetworkPolicyDefaults of the namespace to unset fields", func() {
+			defaults := &networkingv1alpha1.NetworkPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: networkingv1alpha1.NetworkPolicyDefaultsName, Namespace: "default"},
+				Spec: networkingv1alpha1.NetworkPolicyDefaultsSpec{
+					EnabledNetworkType: networkingv1alpha1.All,
+					TTLSeconds:         300,
+					BlockPrivateIPs:    ptr.To(true),
+					Resolver:           &networkingv1alpha1.ResolverConfig{Nameservers: []string{"192.0.2.53"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, defaults)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, defaults)).To(Succeed())
+			}()
+
+			obj.Spec.TTLSeconds = 0
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.EnabledNetworkType).To(Equal(networkingv1alpha1.All))
+			Expect(obj.Spec.TTLSeconds).To(Equal(int32(300)))
+			Expect(obj.Spec.RetryTimeoutSeconds).To(Equal(networkingv1alpha1.DefaultRetryTimeoutSeconds))
+			Expect(obj.Spec.BlockPrivateIPs).To(Equal(ptr.To(true)))
+			Expect(obj.Spec.Resolver.Nameservers).To(ConsistOf("192.0.2.53"))
+
+			By("keeping fields set explicitly")
+			other := oldObj.DeepCopy()
+			other.Spec.BlockPrivateIPs = ptr.To(false)
+			Expect(defaulter.Default(ctx, other)).To(Succeed())
+			Expect(other.Spec.BlockPrivateIPs).To(Equal(ptr.To(false)))
+			Expect(other.Spec.TTLSeconds).To(Equal(int32(60)))
+		})
+
+		It("Should not apply the resolver of the NetworkPolicyDefaults in namespaces forbidding custom resolvers", func() {
+			defaults := &networkingv1alpha1.NetworkPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: networkingv1alpha1.NetworkPolicyDefaultsName, Namespace: "default"},
+				Spec: networkingv1alpha1.NetworkPolicyDefaultsSpec{
+					TTLSeconds: 300,
+					Resolver:   &networkingv1alpha1.ResolverConfig{Nameservers: []string{"192.0.2.53"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, defaults)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, defaults)).To(Succeed())
+			}()
+			// The governance policy is created after the NetworkPolicyDefaults, which are then no longer admitted
+			policy := &networkingv1alpha1.FQDNGovernancePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaulter"},
+				Spec: networkingv1alpha1.FQDNGovernancePolicySpec{
+					AllowedSuffixes:       []networkingv1alpha1.FQDN{"example.com"},
+					ForbidCustomResolvers: true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+			}()
+
+			obj.Spec.TTLSeconds = 0
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.TTLSeconds).To(Equal(int32(300)))
+			Expect(obj.Spec.Resolver).To(BeNil())
+		})
+
+		It("Should reject NetworkPolicyDefaults with another name", func() {
+			defaults := &networkingv1alpha1.NetworkPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "default"},
+			}
+			Expect(apierrors.IsInvalid(k8sClient.Create(ctx, defaults))).To(BeTrue())
+		})
+	})
+})
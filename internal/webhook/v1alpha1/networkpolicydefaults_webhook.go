@@ -0,0 +1,116 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/utils"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var networkpolicydefaultslog = logf.Log.WithName("networkpolicydefaults-resource")
+
+// SetupNetworkPolicyDefaultsWebhookWithManager registers the webhook for NetworkPolicyDefaults in the manager.
+func SetupNetworkPolicyDefaultsWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&networkingv1alpha1.NetworkPolicyDefaults{}).
+		WithValidator(&NetworkPolicyDefaultsCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-networking-turbosimone-com-v1alpha1-networkpolicydefaults,mutating=false,failurePolicy=fail,sideEffects=None,groups=networking.turbosimone.com,resources=networkpolicydefaults,verbs=create;update,versions=v1alpha1,name=vnetworkpolicydefaults-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// NetworkPolicyDefaultsCustomValidator rejects NetworkPolicyDefaults setting a resolver in namespaces whose
+// FQDNGovernancePolicies forbid custom resolvers. The NetworkPolicyDefaults may be edited by the users of the namespace, a resolver of their
+// own could answer the governed FQDNs with any address.
+type NetworkPolicyDefaultsCustomValidator struct {
+	// Client reads the FQDNGovernancePolicies and the namespaces they select
+	Client client.Reader
+}
+
+var _ webhook.CustomValidator = &NetworkPolicyDefaultsCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type NetworkPolicyDefaults.
+func (v *NetworkPolicyDefaultsCustomValidator) ValidateCreate(
+	ctx context.Context, obj runtime.Object,
+) (admission.Warnings, error) {
+	defaults, ok := obj.(*networkingv1alpha1.NetworkPolicyDefaults)
+	if !ok {
+		return nil, fmt.Errorf("expected a NetworkPolicyDefaults object but got %T", obj)
+	}
+	networkpolicydefaultslog.Info("Validation for NetworkPolicyDefaults upon creation", "name", defaults.GetName())
+
+	return nil, v.validate(ctx, defaults)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type NetworkPolicyDefaults.
+func (v *NetworkPolicyDefaultsCustomValidator) ValidateUpdate(
+	ctx context.Context, _, newObj runtime.Object,
+) (admission.Warnings, error) {
+	defaults, ok := newObj.(*networkingv1alpha1.NetworkPolicyDefaults)
+	if !ok {
+		return nil, fmt.Errorf("expected a NetworkPolicyDefaults object for the newObj but got %T", newObj)
+	}
+	networkpolicydefaultslog.Info("Validation for NetworkPolicyDefaults upon update", "name", defaults.GetName())
+
+	if !defaults.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, defaults)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type NetworkPolicyDefaults.
+func (v *NetworkPolicyDefaultsCustomValidator) ValidateDelete(
+	_ context.Context, _ runtime.Object,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns an error if the NetworkPolicyDefaults set a resolver in a namespace forbidding custom resolvers
+func (v *NetworkPolicyDefaultsCustomValidator) validate(
+	ctx context.Context, defaults *networkingv1alpha1.NetworkPolicyDefaults,
+) error {
+	if defaults.Spec.Resolver == nil {
+		return nil
+	}
+	governance, err := utils.GetFQDNGovernance(ctx, v.Client, defaults.Namespace)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	if !governance.ForbidsCustomResolvers() {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		networkingv1alpha1.GroupVersion.WithKind("NetworkPolicyDefaults").GroupKind(), defaults.Name,
+		field.ErrorList{field.Forbidden(
+			field.NewPath("spec", "resolver"),
+			"the FQDNGovernancePolicies of the namespace forbid custom resolvers, the cluster resolver must be used",
+		)},
+	)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkingv1alpha1 "github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var _ = Describe("NetworkPolicyDefaults Webhook", func() {
+	var (
+		obj       *networkingv1alpha1.NetworkPolicyDefaults
+		validator NetworkPolicyDefaultsCustomValidator
+	)
+
+	BeforeEach(func() {
+		obj = &networkingv1alpha1.NetworkPolicyDefaults{
+			ObjectMeta: metav1.ObjectMeta{Name: networkingv1alpha1.NetworkPolicyDefaultsName, Namespace: "default"},
+			Spec: networkingv1alpha1.NetworkPolicyDefaultsSpec{
+				Resolver: &networkingv1alpha1.ResolverConfig{Nameservers: []string{"192.0.2.53"}},
+			},
+		}
+		validator = NetworkPolicyDefaultsCustomValidator{Client: k8sClient}
+	})
+
+	Context("When creating or updating NetworkPolicyDefaults under Validating Webhook", func() {
+		It("Should admit a resolver in namespaces without governance policies", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny a resolver in namespaces whose governance policies forbid custom resolvers", func() {
+			policy := &networkingv1alpha1.FQDNGovernancePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults-webhook"},
+				Spec: networkingv1alpha1.FQDNGovernancePolicySpec{
+					AllowedSuffixes: []networkingv1alpha1.FQDN{"example.com"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+			}()
+
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+
+			policy.Spec.ForbidCustomResolvers = true
+			Expect(k8sClient.Update(ctx, policy)).To(Succeed())
+			_, err = validator.ValidateUpdate(ctx, obj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("spec.resolver: Forbidden")))
+
+			By("admitting the other defaults")
+			obj.Spec.Resolver = nil
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,221 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOptions configures a Cache
+type CacheOptions struct {
+	// DefaultTTL is how long answers are cached when the upstream resolver does not report a TTL
+	DefaultTTL time.Duration
+	// MaxTTL bounds how long answers are cached, regardless of their TTL. Zero disables the bound.
+	MaxTTL time.Duration
+	// NegativeTTL is how long answers for names that do not exist are cached. Zero disables negative caching.
+	NegativeTTL time.Duration
+	// LookupTimeout bounds a lookup shared by concurrent callers. Defaults to defaultCacheLookupTimeout.
+	LookupTimeout time.Duration
+}
+
+// defaultCacheLookupTimeout bounds a shared lookup if the CacheOptions do not
+const defaultCacheLookupTimeout = 30 * time.Second
+
+// cacheSweepInterval is the minimum interval between two sweeps of expired cache entries
+const cacheSweepInterval = time.Minute
+
+type cacheKey struct {
+	scope   string
+	network string
+	host    string
+}
+
+type cacheEntry struct {
+	records *Records
+	err     error
+	// ttlKnown is false if the upstream resolver did not report a TTL for the records
+	ttlKnown bool
+	expires  time.Time
+}
+
+// Cache is a DNS cache shared by all network policies. Answers are served until their TTL expires and concurrent
+// lookups of the same name are deduplicated, so policies listing the same FQDNs do not query the nameservers once
+// each.
+type Cache struct {
+	options   CacheOptions
+	mu        sync.Mutex
+	entries   map[cacheKey]*cacheEntry
+	lastSweep time.Time
+	group     singleflight.Group
+	now       func() time.Time
+}
+
+// NewCache returns an empty Cache
+func NewCache(options CacheOptions) *Cache {
+	return &Cache{
+		options: options,
+		entries: make(map[cacheKey]*cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// Resolver returns a Resolver answering from the cache and querying the upstream resolver on misses. Entries are
+// partitioned by scope, resolvers querying different nameservers must use different scopes.
+func (c *Cache) Resolver(scope string, upstream Resolver) Resolver {
+	return &cachingResolver{cache: c, scope: scope, upstream: upstream}
+}
+
+// lookup returns the cached entry for the key or queries it with the given function. Concurrent lookups of the same
+// key share one query, which runs on a context detached from the callers so that a caller giving up does not fail the
+// lookup of the others. Each caller stops waiting when its own context is done.
+func (c *Cache) lookup(
+	ctx context.Context, key cacheKey, query func(context.Context) (*Records, bool, error),
+) (*Records, error) {
+	if records, ok, err := c.get(key); ok {
+		cacheHitsTotal.Inc()
+		return records, err
+	}
+
+	timeout := c.options.LookupTimeout
+	if timeout <= 0 {
+		timeout = defaultCacheLookupTimeout
+	}
+	leader := false
+	ch := c.group.DoChan(key.scope+"/"+key.network+"/"+key.host, func() (interface{}, error) {
+		leader = true
+		cacheMissesTotal.Inc()
+		queryCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		records, ttlKnown, err := query(queryCtx)
+		c.set(key, records, ttlKnown, err)
+		return records, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-ch:
+		if !leader {
+			cacheDeduplicatedTotal.Inc()
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*Records), nil
+	}
+}
+
+// get returns the records of an unexpired entry with their remaining TTL
+func (c *Cache) get(key cacheKey) (*Records, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	now := c.now()
+	if !ok || !now.Before(entry.expires) {
+		return nil, false, nil
+	}
+	if entry.err != nil {
+		return nil, true, entry.err
+	}
+	records := *entry.records
+	records.TTL = 0
+	if entry.ttlKnown {
+		records.TTL = entry.expires.Sub(now).Truncate(time.Second)
+	}
+	return &records, true, nil
+}
+
+// set stores the outcome of a lookup. Only answers and names that do not exist are cached, other errors such as
+// timeouts are retried on the next lookup.
+func (c *Cache) set(key cacheKey, records *Records, ttlKnown bool, err error) {
+	var ttl time.Duration
+	switch {
+	case err == nil && ttlKnown:
+		ttl = records.TTL
+	case err == nil:
+		ttl = c.options.DefaultTTL
+	case isNotFound(err):
+		ttl = c.options.NegativeTTL
+	}
+	if c.options.MaxTTL > 0 {
+		ttl = min(ttl, c.options.MaxTTL)
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+	c.entries[key] = &cacheEntry{records: records, err: err, ttlKnown: ttlKnown, expires: now.Add(ttl)}
+	cacheEntries.Set(float64(len(c.entries)))
+}
+
+// sweep removes expired entries at most once per cacheSweepInterval. Must be called with the lock held.
+func (c *Cache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < cacheSweepInterval {
+		return
+	}
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// srvNetwork is the network of the cache keys holding SRV records
+const srvNetwork = "srv"
+
+// cachingResolver is a Resolver backed by a Cache
+type cachingResolver struct {
+	cache    *Cache
+	scope    string
+	upstream Resolver
+}
+
+// LookupIP resolves the host to its addresses, see LookupRecords
+func (r *cachingResolver) LookupIP(ctx context.Context, network string, host string) ([]net.IP, error) {
+	records, err := r.LookupRecords(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	return records.IPs, nil
+}
+
+// LookupRecords resolves the host from the cache or the upstream resolver. The TTL of cached records is the time
+// remaining until they expire, or zero if the upstream resolver did not report a TTL.
+func (r *cachingResolver) LookupRecords(ctx context.Context, network string, host string) (*Records, error) {
+	key := cacheKey{scope: r.scope, network: network, host: strings.ToLower(strings.TrimSuffix(host, "."))}
+	return r.cache.lookup(ctx, key, func(ctx context.Context) (*Records, bool, error) {
+		if recordResolver, ok := r.upstream.(RecordResolver); ok {
+			records, err := recordResolver.LookupRecords(ctx, network, host)
+			return records, true, err
+		}
+		ips, err := r.upstream.LookupIP(ctx, network, host)
+		if err != nil {
+			return nil, false, err
+		}
+		return &Records{IPs: ips}, false, nil
+	})
+}
+
+// LookupSRVRecords resolves the SRV records of the name from the cache or the upstream resolver, see LookupRecords
+func (r *cachingResolver) LookupSRVRecords(ctx context.Context, name string) (*Records, error) {
+	key := cacheKey{scope: r.scope, network: srvNetwork, host: strings.ToLower(strings.TrimSuffix(name, "."))}
+	return r.cache.lookup(ctx, key, func(ctx context.Context) (*Records, bool, error) {
+		return lookupSRVRecords(ctx, r.upstream, name)
+	})
+}
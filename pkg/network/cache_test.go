@@ -0,0 +1,144 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver answers every lookup with the same records after an optional delay and counts the lookups
+type countingResolver struct {
+	lookups atomic.Int32
+	delay   time.Duration
+	records *Records
+	err     error
+}
+
+func (r *countingResolver) LookupIP(ctx context.Context, network string, host string) ([]net.IP, error) {
+	records, err := r.LookupRecords(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	return records.IPs, nil
+}
+
+func (r *countingResolver) LookupRecords(ctx context.Context, _ string, _ string) (*Records, error) {
+	r.lookups.Add(1)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(r.delay):
+		return r.records, r.err
+	}
+}
+
+func TestCacheServesAnswersUntilTheirTTLExpires(t *testing.T) {
+	upstream := &countingResolver{records: &Records{IPs: []net.IP{net.ParseIP("192.0.2.1")}, TTL: 30 * time.Second}}
+	cache := NewCache(CacheOptions{})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	resolver := cache.Resolver("test", upstream).(RecordResolver)
+
+	for range 3 {
+		if _, err := resolver.LookupRecords(context.Background(), "ip4", "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups := upstream.lookups.Load(); lookups != 1 {
+		t.Errorf("expected 1 upstream lookup, got %d", lookups)
+	}
+
+	now = now.Add(20 * time.Second)
+	records, err := resolver.LookupRecords(context.Background(), "ip4", "EXAMPLE.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records.TTL != 10*time.Second {
+		t.Errorf("expected remaining TTL of 10s, got %s", records.TTL)
+	}
+
+	now = now.Add(10 * time.Second)
+	if _, err := resolver.LookupRecords(context.Background(), "ip4", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups := upstream.lookups.Load(); lookups != 2 {
+		t.Errorf("expected 2 upstream lookups after expiry, got %d", lookups)
+	}
+}
+
+func TestCacheBoundsNegativeAnswers(t *testing.T) {
+	upstream := &countingResolver{err: &net.DNSError{Err: "no such host", IsNotFound: true}}
+	cache := NewCache(CacheOptions{NegativeTTL: 5 * time.Second})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	resolver := cache.Resolver("test", upstream)
+
+	for range 2 {
+		if _, err := resolver.LookupIP(context.Background(), "ip4", "missing.example.com"); !isNotFound(err) {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+	}
+	now = now.Add(5 * time.Second)
+	_, _ = resolver.LookupIP(context.Background(), "ip4", "missing.example.com")
+	if lookups := upstream.lookups.Load(); lookups != 2 {
+		t.Errorf("expected 2 upstream lookups, got %d", lookups)
+	}
+}
+
+func TestCacheDeduplicatesConcurrentLookups(t *testing.T) {
+	upstream := &countingResolver{
+		delay:   100 * time.Millisecond,
+		records: &Records{IPs: []net.IP{net.ParseIP("192.0.2.1")}},
+	}
+	resolver := NewCache(CacheOptions{}).Resolver("test", upstream)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := resolver.LookupIP(context.Background(), "ip4", "example.com"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if lookups := upstream.lookups.Load(); lookups != 1 {
+		t.Errorf("expected 1 upstream lookup, got %d", lookups)
+	}
+}
+
+func TestCacheSharedLookupOutlivesTheFirstCaller(t *testing.T) {
+	upstream := &countingResolver{
+		delay:   100 * time.Millisecond,
+		records: &Records{IPs: []net.IP{net.ParseIP("192.0.2.1")}},
+	}
+	resolver := NewCache(CacheOptions{}).Resolver("test", upstream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := resolver.LookupIP(ctx, "ip4", "example.com"); err == nil {
+			t.Error("expected the first caller to give up when its context is done")
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	ips, err := resolver.LookupIP(context.Background(), "ip4", "example.com")
+	if err != nil {
+		t.Fatalf("expected the second caller to get the shared answer, got %v", err)
+	}
+	if len(ips) != 1 {
+		t.Errorf("expected 1 address, got %v", ips)
+	}
+	wg.Wait()
+	if lookups := upstream.lookups.Load(); lookups != 1 {
+		t.Errorf("expected 1 upstream lookup, got %d", lookups)
+	}
+}
@@ -0,0 +1,612 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+// maxUDPPayloadSize is the EDNS0 payload size advertised to nameservers, see https://www.dnsflagday.net/2020/
+const maxUDPPayloadSize = 1232
+
+// Records is the answer of a DNS lookup for a host
+type Records struct {
+	// IPs are the addresses of the host
+	IPs []net.IP
+	// TTL is the lowest TTL of the records leading to the addresses, including CNAMEs
+	TTL time.Duration
+	// CNAMEs is the chain of canonical names followed from the host to the addresses, in order
+	CNAMEs []string
+	// SRVs are the service records of the host, only set by SRV lookups
+	SRVs []*net.SRV
+}
+
+// RecordResolver is implemented by Resolvers that report the TTL of the records they resolved
+type RecordResolver interface {
+	LookupRecords(ctx context.Context, network string, host string) (*Records, error)
+}
+
+// ClientConfig configures the nameservers a Client queries
+type ClientConfig struct {
+	// Servers are the nameservers to query in order, as host:port
+	Servers []string
+	// Protocol is the transport used for queries, defaults to UDP with TCP fallback for truncated responses
+	Protocol v1alpha1.DNSProtocol
+	// Search is the list of domains appended to hosts with fewer than NDots dots, see resolv.conf(5)
+	Search []string
+	// NDots is the number of dots a host must contain to be queried as-is before the search domains are tried
+	NDots int
+	// Path is the URL path of DNS-over-HTTPS endpoints, defaults to /dns-query
+	Path string
+	// ServerName is used as SNI and to verify the certificates of TLS and HTTPS nameservers
+	ServerName string
+	// CABundle holds PEM encoded certificates used to verify TLS and HTTPS nameservers, defaults to the system roots
+	CABundle []byte
+}
+
+// ClientConfigFromSpec converts the resolver configuration of a network policy to a ClientConfig. The CA bundle
+// referenced by the TLS configuration has to be set by the caller.
+func ClientConfigFromSpec(spec *v1alpha1.ResolverConfig) ClientConfig {
+	port := spec.Port
+	if port == 0 {
+		port = spec.Protocol.DefaultPort()
+	}
+	config := ClientConfig{
+		Protocol: spec.Protocol,
+		NDots:    int(spec.NDots),
+		Path:     spec.Path,
+	}
+	if spec.TLS != nil {
+		config.ServerName = spec.TLS.ServerName
+	}
+	for _, server := range spec.Nameservers {
+		config.Servers = append(config.Servers, net.JoinHostPort(server, strconv.Itoa(int(port))))
+	}
+	for _, domain := range spec.Search {
+		config.Search = append(config.Search, string(domain))
+	}
+	return config
+}
+
+// Key returns a string identifying the configuration, two configurations with the same key resolve names the same
+// way
+func (c ClientConfig) Key() string {
+	return fmt.Sprintf("%s|%s|%s|%d|%s|%s|%x",
+		strings.Join(c.Servers, ","), c.Protocol, strings.Join(c.Search, ","), c.NDots,
+		c.Path, c.ServerName, sha256.Sum256(c.CABundle),
+	)
+}
+
+// Client is a Resolver that queries nameservers directly, which gives access to record TTLs and CNAME chains that
+// net.Resolver does not expose. Queries are sent over UDP and retried over TCP when the response is truncated, unless
+// the config selects TCP, DNS-over-TLS or DNS-over-HTTPS.
+type Client struct {
+	config     ClientConfig
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+	// configErr is returned by all lookups if the config is invalid
+	configErr error
+}
+
+// NewClient returns a Client querying the nameservers of the config
+func NewClient(config ClientConfig) *Client {
+	c := &Client{config: config}
+	c.tlsConfig = &tls.Config{
+		ServerName: config.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(config.CABundle) > 0 {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(config.CABundle) {
+			c.configErr = errors.New("the CA bundle of the resolver does not contain any PEM encoded certificate")
+		}
+		c.tlsConfig.RootCAs = roots
+	}
+	if config.Protocol == v1alpha1.DNSProtocolHTTPS {
+		c.httpClient = &http.Client{
+			Timeout: defaultExchangeTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig:   c.tlsConfig,
+				ForceAttemptHTTP2: true,
+				IdleConnTimeout:   90 * time.Second,
+			},
+		}
+	}
+	return c
+}
+
+// maxNDots is the upper bound of the ndots option, see resolv.conf(5)
+const maxNDots = 15
+
+// ClientConfigFromResolvConf reads the nameservers, the search domains and the ndots option from a resolv.conf file.
+// The last search or domain line wins, as with the system resolver. NDots defaults to 1.
+func ClientConfigFromResolvConf(path string) (ClientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClientConfig{}, err
+	}
+	config := ClientConfig{NDots: 1}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			config.Servers = append(config.Servers, net.JoinHostPort(fields[1], "53"))
+		case "search":
+			config.Search = fields[1:]
+		case "domain":
+			config.Search = fields[1:2]
+		case "options":
+			for _, option := range fields[1:] {
+				value, ok := strings.CutPrefix(option, "ndots:")
+				if !ok {
+					continue
+				}
+				if ndots, err := strconv.Atoi(value); err == nil && ndots >= 0 {
+					config.NDots = min(ndots, maxNDots)
+				}
+			}
+		}
+	}
+	if len(config.Servers) == 0 {
+		return ClientConfig{}, fmt.Errorf("no nameservers found in %s", path)
+	}
+	return config, nil
+}
+
+// CloseIdleConnections closes the idle connections to DNS-over-HTTPS nameservers
+func (c *Client) CloseIdleConnections() {
+	if c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+	}
+}
+
+// LookupIP resolves the host to its addresses. Network is one of 'ip', 'ip4' or 'ip6'.
+func (c *Client) LookupIP(ctx context.Context, network string, host string) ([]net.IP, error) {
+	records, err := c.LookupRecords(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	return records.IPs, nil
+}
+
+// LookupCNAME returns the canonical name of the host
+func (c *Client) LookupCNAME(ctx context.Context, host string) (string, error) {
+	records, err := c.LookupRecords(ctx, "ip4", host)
+	if err != nil {
+		return "", err
+	}
+	if len(records.CNAMEs) == 0 {
+		return absolute(host), nil
+	}
+	return records.CNAMEs[len(records.CNAMEs)-1], nil
+}
+
+// LookupSRVRecords resolves the SRV records of the name and reports their TTL and the CNAME chain of the answer. The
+// name is always queried as-is, search domains are not applied.
+func (c *Client) LookupSRVRecords(ctx context.Context, name string) (*Records, error) {
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+	records, err := c.query(ctx, name, dnsmessage.TypeSRV)
+	if err != nil {
+		return nil, err
+	}
+	if len(records.SRVs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// names returns the names to query for the host in order, following the search semantics of resolv.conf(5)
+func (c *Client) names(host string) []string {
+	if strings.HasSuffix(host, ".") || len(c.config.Search) == 0 {
+		return []string{host}
+	}
+	searched := make([]string, 0, len(c.config.Search))
+	for _, domain := range c.config.Search {
+		searched = append(searched, host+"."+strings.TrimSuffix(domain, "."))
+	}
+	if strings.Count(host, ".") >= c.config.NDots {
+		return append([]string{host}, searched...)
+	}
+	return append(searched, host)
+}
+
+// LookupRecords resolves the host to its addresses and reports the TTL and CNAME chain of the answer. If search
+// domains are configured, the candidate names are tried in order until one of them exists.
+// Network is one of 'ip', 'ip4' or 'ip6'.
+func (c *Client) LookupRecords(ctx context.Context, network string, host string) (*Records, error) {
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+	var err error
+	for _, name := range c.names(host) {
+		var records *Records
+		records, err = c.lookupName(ctx, network, name)
+		if err == nil {
+			return records, nil
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		dnsErr.Name = host
+	}
+	return nil, err
+}
+
+// lookupName resolves a single name without applying the search domains
+func (c *Client) lookupName(ctx context.Context, network string, host string) (*Records, error) {
+	var types []dnsmessage.Type
+	switch network {
+	case "ip":
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	case "ip4":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "ip6":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	result := &Records{TTL: math.MaxInt64}
+	for _, qtype := range types {
+		records, err := c.query(ctx, host, qtype)
+		if err != nil {
+			return nil, err
+		}
+		if len(records.IPs) == 0 {
+			// No records of this type (NODATA), the TTL of the empty answer is irrelevant
+			continue
+		}
+		result.IPs = append(result.IPs, records.IPs...)
+		result.TTL = min(result.TTL, records.TTL)
+		if len(records.CNAMEs) > len(result.CNAMEs) {
+			result.CNAMEs = records.CNAMEs
+		}
+	}
+	if len(result.IPs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return result, nil
+}
+
+// query sends a question for the host to each nameserver until one answers
+func (c *Client) query(ctx context.Context, host string, qtype dnsmessage.Type) (*Records, error) {
+	name, err := dnsmessage.NewName(absolute(host))
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	var lastErr error = &net.DNSError{Err: "no nameservers configured", Name: host}
+	for _, server := range c.config.Servers {
+		response, err := c.exchange(ctx, server, name, qtype)
+		if err != nil {
+			lastErr = toDNSError(err, host, server)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		records, err := parseResponse(response, name, qtype)
+		if err != nil {
+			lastErr = toDNSError(err, host, server)
+			var dnsErr *net.DNSError
+			if errors.As(lastErr, &dnsErr) && dnsErr.IsNotFound {
+				// The name does not exist, other nameservers will not know better
+				return nil, lastErr
+			}
+			continue
+		}
+		return records, nil
+	}
+	return nil, lastErr
+}
+
+// exchange sends a single question to the nameserver using the configured protocol. UDP falls back to TCP for
+// truncated responses.
+func (c *Client) exchange(
+	ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type,
+) (*dnsmessage.Message, error) {
+	// RFC 8484 4.1 recommends an ID of 0 for DNS-over-HTTPS, so responses can be cached by HTTP caches
+	var id uint16
+	if c.config.Protocol != v1alpha1.DNSProtocolHTTPS {
+		var idBytes [2]byte
+		if _, err := rand.Read(idBytes[:]); err != nil {
+			return nil, err
+		}
+		id = binary.BigEndian.Uint16(idBytes[:])
+	}
+	query, err := newQuery(name, qtype, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.config.Protocol {
+	case v1alpha1.DNSProtocolTCP:
+		return exchangeTCP(ctx, server, query, id)
+	case v1alpha1.DNSProtocolTLS:
+		return c.exchangeTLS(ctx, server, query, id)
+	case v1alpha1.DNSProtocolHTTPS:
+		return c.exchangeHTTPS(ctx, server, query)
+	}
+
+	response, err := exchangeUDP(ctx, server, query, id)
+	if err == nil && !response.Truncated {
+		return response, nil
+	}
+	if err != nil && !errors.Is(err, errTruncated) {
+		return nil, err
+	}
+	return exchangeTCP(ctx, server, query, id)
+}
+
+var errTruncated = errors.New("truncated response")
+
+func exchangeUDP(ctx context.Context, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	setDeadline(ctx, conn)
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxUDPPayloadSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var response dnsmessage.Message
+		if err := response.Unpack(buf[:n]); err != nil || response.ID != id || !response.Response {
+			// Ignore responses that do not belong to the query
+			continue
+		}
+		if response.Truncated {
+			return nil, errTruncated
+		}
+		return &response, nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	setDeadline(ctx, conn)
+	return exchangeStream(conn, query, id)
+}
+
+// exchangeTLS sends the query over DNS-over-TLS (RFC 7858), which uses the TCP framing
+func (c *Client) exchangeTLS(ctx context.Context, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	dialer := tls.Dialer{Config: c.tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	setDeadline(ctx, conn)
+	return exchangeStream(conn, query, id)
+}
+
+// defaultDoHPath is the URL path of DNS-over-HTTPS endpoints used when the config does not specify one
+const defaultDoHPath = "/dns-query"
+
+// exchangeHTTPS posts the query to the DNS-over-HTTPS (RFC 8484) endpoint of the server
+func (c *Client) exchangeHTTPS(ctx context.Context, server string, query []byte) (*dnsmessage.Message, error) {
+	path := c.config.Path
+	if path == "" {
+		path = defaultDoHPath
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+server+path, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", dnsMessageMediaType)
+	request.Header.Set("Accept", dnsMessageMediaType)
+	if c.config.ServerName != "" {
+		request.Host = c.config.ServerName
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %q", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, math.MaxUint16))
+	if err != nil {
+		return nil, err
+	}
+	var message dnsmessage.Message
+	if err := message.Unpack(body); err != nil {
+		return nil, err
+	}
+	if !message.Response {
+		return nil, errors.New("received mismatched DNS response")
+	}
+	return &message, nil
+}
+
+// dnsMessageMediaType is the media type of DNS wire format messages sent over HTTPS
+const dnsMessageMediaType = "application/dns-message"
+
+// defaultExchangeTimeout bounds exchanges when the context has no deadline
+const defaultExchangeTimeout = 5 * time.Second
+
+// setDeadline applies the deadline of the context to the connection
+func setDeadline(ctx context.Context, conn net.Conn) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultExchangeTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+}
+
+// exchangeStream sends the query over a stream connection using the two byte length prefix of RFC 1035 4.2.2
+func exchangeStream(conn io.ReadWriter, query []byte, id uint16) (*dnsmessage.Message, error) {
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	var response dnsmessage.Message
+	if err := response.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if response.ID != id || !response.Response {
+		return nil, errors.New("received mismatched DNS response")
+	}
+	return &response, nil
+}
+
+// newQuery builds a recursive query for the name with an EDNS0 record
+func newQuery(name dnsmessage.Name, qtype dnsmessage.Type, id uint16) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(maxUDPPayloadSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := builder.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return builder.Finish()
+}
+
+// parseResponse follows the CNAME chain starting at name and collects the addresses or service records of the
+// requested type
+func parseResponse(response *dnsmessage.Message, name dnsmessage.Name, qtype dnsmessage.Type) (*Records, error) {
+	switch response.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	case dnsmessage.RCodeServerFailure:
+		return nil, &net.DNSError{Err: "server misbehaving", IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: fmt.Sprintf("unexpected response code %s", response.RCode)}
+	}
+
+	records := &Records{TTL: math.MaxInt64}
+	current := name
+	// Bound the number of CNAMEs followed to avoid loops
+	for range 16 {
+		next, found := dnsmessage.Name{}, false
+		for _, answer := range response.Answers {
+			if !strings.EqualFold(answer.Header.Name.String(), current.String()) {
+				continue
+			}
+			ttl := time.Duration(answer.Header.TTL) * time.Second
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				if qtype == dnsmessage.TypeA {
+					records.IPs = append(records.IPs, net.IP(body.A[:]))
+					records.TTL = min(records.TTL, ttl)
+				}
+			case *dnsmessage.AAAAResource:
+				if qtype == dnsmessage.TypeAAAA {
+					records.IPs = append(records.IPs, net.IP(body.AAAA[:]))
+					records.TTL = min(records.TTL, ttl)
+				}
+			case *dnsmessage.SRVResource:
+				if qtype == dnsmessage.TypeSRV {
+					records.SRVs = append(records.SRVs, &net.SRV{
+						Target: body.Target.String(), Port: body.Port, Priority: body.Priority, Weight: body.Weight,
+					})
+					records.TTL = min(records.TTL, ttl)
+				}
+			case *dnsmessage.CNAMEResource:
+				next, found = body.CNAME, true
+				records.TTL = min(records.TTL, ttl)
+			}
+		}
+		if !found || len(records.IPs) > 0 || len(records.SRVs) > 0 {
+			break
+		}
+		records.CNAMEs = append(records.CNAMEs, next.String())
+		current = next
+	}
+	if len(records.IPs) == 0 && len(records.SRVs) == 0 {
+		records.TTL = 0
+	}
+	return records, nil
+}
+
+// toDNSError converts errors of an exchange into a net.DNSError, so callers can classify them like errors returned by
+// net.Resolver
+func toDNSError(err error, host, server string) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		dnsErr.Name = host
+		dnsErr.Server = server
+		return dnsErr
+	}
+	var netErr net.Error
+	timeout := errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	return &net.DNSError{
+		Err:         err.Error(),
+		Name:        host,
+		Server:      server,
+		IsTimeout:   timeout,
+		IsTemporary: true,
+	}
+}
+
+// absolute returns the host as a fully qualified name ending with a dot
+func absolute(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
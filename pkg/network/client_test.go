@@ -0,0 +1,232 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+func TestClientLookupRecords(t *testing.T) {
+	server := startTestServer(t, testZone{
+		"www.example.com.": {cnameRecord("www.example.com.", 300, "edge.example.net.")},
+		"edge.example.net.": {
+			aRecord("edge.example.net.", 20, "192.0.2.1"),
+			aRecord("edge.example.net.", 20, "192.0.2.2"),
+		},
+		"static.example.com.": {aRecord("static.example.com.", 3600, "192.0.2.10")},
+	})
+	client := NewClient(ClientConfig{Servers: []string{server}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("follows CNAMEs and reports the lowest TTL", func(t *testing.T) {
+		records, err := client.LookupRecords(ctx, "ip4", "www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if records.TTL != 20*time.Second {
+			t.Errorf("expected TTL of 20s, got %s", records.TTL)
+		}
+		if !slices.Equal(records.CNAMEs, []string{"edge.example.net."}) {
+			t.Errorf("unexpected CNAME chain %v", records.CNAMEs)
+		}
+		if len(records.IPs) != 2 {
+			t.Errorf("expected 2 addresses, got %v", records.IPs)
+		}
+	})
+
+	t.Run("ignores missing record types", func(t *testing.T) {
+		records, err := client.LookupRecords(ctx, "ip", "static.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if records.TTL != time.Hour || len(records.IPs) != 1 {
+			t.Errorf("unexpected records %+v", records)
+		}
+	})
+
+	t.Run("reports unknown names as not found", func(t *testing.T) {
+		_, err := client.LookupRecords(ctx, "ip4", "missing.example.com")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Errorf("expected not found error, got %v", err)
+		}
+	})
+}
+
+func TestClientLookupSRVRecords(t *testing.T) {
+	server := startTestServer(t, testZone{
+		"_ldap._tcp.corp.example.com.": {
+			srvRecord("_ldap._tcp.corp.example.com.", 600, "dc1.corp.example.com.", 389),
+			srvRecord("_ldap._tcp.corp.example.com.", 300, "dc2.corp.example.com.", 3268),
+		},
+	})
+	client := NewClient(ClientConfig{Servers: []string{server}, Search: []string{"example.org"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	records, err := client.LookupSRVRecords(ctx, "_ldap._tcp.corp.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records.TTL != 300*time.Second {
+		t.Errorf("expected TTL of 300s, got %s", records.TTL)
+	}
+	result := NewSRVResolverResult("_ldap._tcp.corp.example.com", records.SRVs, records.TTL, nil)
+	expected := []v1alpha1.SRVTarget{
+		{Target: "dc1.corp.example.com", Port: 389},
+		{Target: "dc2.corp.example.com", Port: 3268},
+	}
+	if !slices.Equal(result.Targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, result.Targets)
+	}
+
+	_, err = client.LookupSRVRecords(ctx, "_ldap._udp.corp.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestClientSearchDomains(t *testing.T) {
+	server := startTestServer(t, testZone{
+		"vm01.lab.corp.example.": {aRecord("vm01.lab.corp.example.", 60, "10.0.0.1")},
+		"www.example.com.":       {aRecord("www.example.com.", 60, "192.0.2.1")},
+	})
+	client := NewClient(ClientConfig{
+		Servers:  []string{server},
+		Protocol: "TCP",
+		Search:   []string{"corp.example"},
+		NDots:    2,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for host, expected := range map[string]string{"vm01.lab": "10.0.0.1", "www.example.com": "192.0.2.1"} {
+		ips, err := client.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			t.Fatalf("%s: %v", host, err)
+		}
+		if len(ips) != 1 || ips[0].String() != expected {
+			t.Errorf("%s: expected %s, got %v", host, expected, ips)
+		}
+	}
+}
+
+func TestClientConfigFromResolvConf(t *testing.T) {
+	config, err := ClientConfigFromResolvConf("testdata/resolv.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ClientConfig{
+		Servers: []string{"10.96.0.10:53", "[fd00::10]:53"},
+		Search:  []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		NDots:   5,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v, got %+v", expected, config)
+	}
+
+	t.Run("domain line and default ndots", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resolv.conf")
+		if err := os.WriteFile(path, []byte("nameserver 192.0.2.53\ndomain corp.example\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := ClientConfigFromResolvConf(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(config.Search, []string{"corp.example"}) || config.NDots != 1 {
+			t.Errorf("expected the search domain corp.example and ndots 1, got %+v", config)
+		}
+	})
+
+	t.Run("no nameservers", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resolv.conf")
+		if err := os.WriteFile(path, []byte("search corp.example\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ClientConfigFromResolvConf(path); err == nil {
+			t.Error("expected an error without nameservers")
+		}
+	})
+}
+
+func TestParseResponseStopsAtCNAMELoops(t *testing.T) {
+	response := &dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true},
+		Answers: []dnsmessage.Resource{
+			cnameRecord("a.example.com.", 60, "b.example.com."),
+			cnameRecord("b.example.com.", 60, "a.example.com."),
+		},
+	}
+	records, err := parseResponse(response, dnsmessage.MustNewName("a.example.com."), dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.IPs) != 0 || records.TTL != 0 {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestClientEncryptedTransports(t *testing.T) {
+	zone := testZone{
+		"www.example.com.": {aRecord("www.example.com.", 60, "192.0.2.1")},
+	}
+	tlsServer, tlsCA := startTestTLSServer(t, zone)
+	httpsServer, httpsCA := startTestHTTPSServer(t, zone)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for name, config := range map[string]ClientConfig{
+		"TLS": {Servers: []string{tlsServer}, Protocol: "TLS", CABundle: tlsCA},
+		"TLS with server name": {
+			Servers: []string{tlsServer}, Protocol: "TLS", CABundle: tlsCA, ServerName: "example.com",
+		},
+		"HTTPS": {Servers: []string{httpsServer}, Protocol: "HTTPS", CABundle: httpsCA},
+		"HTTPS with server name": {
+			Servers: []string{httpsServer}, Protocol: "HTTPS", CABundle: httpsCA, ServerName: "example.com",
+		},
+		"HTTPS with explicit path": {
+			Servers: []string{httpsServer}, Protocol: "HTTPS", CABundle: httpsCA, Path: "/dns-query",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ips, err := NewClient(config).LookupIP(ctx, "ip4", "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+				t.Errorf("expected 192.0.2.1, got %v", ips)
+			}
+		})
+	}
+
+	for name, config := range map[string]ClientConfig{
+		"TLS with untrusted certificate": {Servers: []string{tlsServer}, Protocol: "TLS"},
+		"TLS with mismatched server name": {
+			Servers: []string{tlsServer}, Protocol: "TLS", CABundle: tlsCA, ServerName: "dns.example.org",
+		},
+		"HTTPS with untrusted certificate": {Servers: []string{httpsServer}, Protocol: "HTTPS"},
+		"HTTPS with unknown path": {
+			Servers: []string{httpsServer}, Protocol: "HTTPS", CABundle: httpsCA, Path: "/resolve",
+		},
+		"invalid CA bundle": {Servers: []string{httpsServer}, Protocol: "HTTPS", CABundle: []byte("invalid")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewClient(config).LookupIP(ctx, "ip4", "www.example.com"); err == nil {
+				t.Error("expected the lookup to fail")
+			}
+		})
+	}
+}
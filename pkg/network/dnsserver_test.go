@@ -0,0 +1,228 @@
+package network
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testZone maps lower-case absolute names to their resource records
+type testZone map[string][]dnsmessage.Resource
+
+func aRecord(name string, ttl uint32, ip string) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], net.ParseIP(ip).To4())
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.AResource{A: a},
+	}
+}
+
+func cnameRecord(name string, ttl uint32, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)},
+	}
+}
+
+func srvRecord(name string, ttl uint32, target string, port uint16) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.SRVResource{Target: dnsmessage.MustNewName(target), Port: port},
+	}
+}
+
+func recordType(body dnsmessage.ResourceBody) dnsmessage.Type {
+	switch body.(type) {
+	case *dnsmessage.AResource:
+		return dnsmessage.TypeA
+	case *dnsmessage.AAAAResource:
+		return dnsmessage.TypeAAAA
+	case *dnsmessage.SRVResource:
+		return dnsmessage.TypeSRV
+	}
+	return 0
+}
+
+// answer builds the response to a query from the zone, following CNAMEs like a recursive resolver would
+func (z testZone) answer(query []byte) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	question := msg.Questions[0]
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, RecursionAvailable: true},
+		Questions: msg.Questions,
+	}
+
+	name := strings.ToLower(question.Name.String())
+	if _, ok := z[name]; !ok {
+		response.RCode = dnsmessage.RCodeNameError
+	}
+	for range 8 {
+		next := ""
+		for _, record := range z[name] {
+			if cname, ok := record.Body.(*dnsmessage.CNAMEResource); ok {
+				response.Answers = append(response.Answers, record)
+				next = strings.ToLower(cname.CNAME.String())
+			} else if recordType(record.Body) == question.Type {
+				response.Answers = append(response.Answers, record)
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+
+	packed, err := response.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
+
+// serveStream answers length prefixed queries on a stream connection until it is closed
+func (z testZone) serveStream(conn io.ReadWriteCloser) {
+	defer func() { _ = conn.Close() }()
+	for {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		response := z.answer(query)
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(response)))
+		if _, err := conn.Write(append(framed, response...)); err != nil {
+			return
+		}
+	}
+}
+
+// startTestServer serves the zone on a local UDP and TCP port and returns the address
+func startTestServer(t *testing.T, zone testZone) string {
+	t.Helper()
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = packetConn.Close()
+		_ = listener.Close()
+		wg.Wait()
+	})
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := packetConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := zone.answer(buf[:n]); response != nil {
+				_, _ = packetConn.WriteTo(response, addr)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					t.Log(err)
+				}
+				return
+			}
+			go zone.serveStream(conn)
+		}
+	}()
+
+	return packetConn.LocalAddr().String()
+}
+
+// startTestHTTPSServer serves the zone over DNS-over-HTTPS at /dns-query and returns the address and the PEM encoded
+// CA of the server certificate, which is valid for example.com and 127.0.0.1
+func startTestHTTPSServer(t *testing.T, zone testZone) (string, []byte) {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dns-query" || r.Method != http.MethodPost ||
+			r.Header.Get("Content-Type") != "application/dns-message" {
+			http.NotFound(w, r)
+			return
+		}
+		query, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(zone.answer(query))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.Listener.Addr().String(), testServerCA(server)
+}
+
+// startTestTLSServer serves the zone over DNS-over-TLS and returns the address and the PEM encoded CA of the server
+// certificate, which is valid for example.com and 127.0.0.1
+func startTestTLSServer(t *testing.T, zone testZone) (string, []byte) {
+	t.Helper()
+
+	// Borrow the TLS config and certificate of an httptest server
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	t.Cleanup(certServer.Close)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", certServer.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = listener.Close()
+		wg.Wait()
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go zone.serveStream(conn)
+		}
+	}()
+
+	return listener.Addr().String(), testServerCA(certServer)
+}
+
+func testServerCA(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+var (
+	cacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "fqdn_egress_dns_cache_hits_total",
+		Help: "Number of DNS lookups answered from the shared cache, including cached negative answers.",
+	})
+	cacheMissesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "fqdn_egress_dns_cache_misses_total",
+		Help: "Number of DNS lookups sent to the nameservers because the shared cache had no valid answer.",
+	})
+	cacheDeduplicatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "fqdn_egress_dns_cache_deduplicated_total",
+		Help: "Number of DNS lookups that waited for an identical lookup already in flight instead of querying.",
+	})
+	cacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fqdn_egress_dns_cache_entries",
+		Help: "Number of entries in the shared DNS cache, including expired entries not swept yet.",
+	})
+	// resolveDuration creates a series per FQDN, reason and bucket, so the fqdn label is bounded by resolveDurationFQDNs
+	resolveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "fqdn_egress_dns_resolve_duration_seconds",
+		Help: fmt.Sprintf("Time taken to resolve a single FQDN, by FQDN and resolve reason. "+
+			"The FQDNs resolved after the first %d are reported as %q.", maxResolveDurationFQDNs, otherFQDNLabel),
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"fqdn", "reason"})
+)
+
+const (
+	// maxResolveDurationFQDNs bounds the number of FQDNs labelling resolveDuration
+	maxResolveDurationFQDNs = 50
+	// otherFQDNLabel labels the FQDNs resolved once maxResolveDurationFQDNs FQDNs are labelled
+	otherFQDNLabel = "other"
+)
+
+// fqdnLabels hands out the fqdn label of resolveDuration. The first maxResolveDurationFQDNs FQDNs resolved keep their
+// own label for the lifetime of the process, as the series of a histogram are not removed, the others share
+// otherFQDNLabel.
+type fqdnLabels struct {
+	mu    sync.Mutex
+	max   int
+	fqdns map[v1alpha1.FQDN]struct{}
+}
+
+var resolveDurationFQDNs = &fqdnLabels{max: maxResolveDurationFQDNs, fqdns: make(map[v1alpha1.FQDN]struct{})}
+
+// label returns the label of the FQDN, labelling it if fewer than max FQDNs are labelled
+func (l *fqdnLabels) label(fqdn v1alpha1.FQDN) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.fqdns[fqdn]; !ok {
+		if len(l.fqdns) >= l.max {
+			return otherFQDNLabel
+		}
+		l.fqdns[fqdn] = struct{}{}
+	}
+	return string(fqdn)
+}
+
+func init() {
+	metrics.Registry.MustRegister(cacheHitsTotal, cacheMissesTotal, cacheDeduplicatedTotal, cacheEntries, resolveDuration)
+}
@@ -0,0 +1,24 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+func TestFQDNLabelsAreBounded(t *testing.T) {
+	labels := &fqdnLabels{max: 2, fqdns: make(map[v1alpha1.FQDN]struct{})}
+	for _, test := range []struct {
+		fqdn     v1alpha1.FQDN
+		expected string
+	}{
+		{"www.example.com", "www.example.com"},
+		{"api.example.com", "api.example.com"},
+		{"www.example.org", otherFQDNLabel},
+		{"www.example.com", "www.example.com"},
+	} {
+		if label := labels.label(test.fqdn); label != test.expected {
+			t.Errorf("expected label %q for %s, got %q", test.expected, test.fqdn, label)
+		}
+	}
+}
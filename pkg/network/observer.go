@@ -0,0 +1,104 @@
+package network
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+// maxObservedNames bounds the number of names kept by a NameObserver
+const maxObservedNames = 10000
+
+// NameObserver records domain names seen during DNS resolution, such as CNAME targets, or reported by an external DNS
+// observer. The recorded names are used to discover the concrete FQDNs matching wildcard patterns.
+type NameObserver struct {
+	mu        sync.RWMutex
+	retention time.Duration
+	names     map[v1alpha1.FQDN]time.Time
+	now       func() time.Time
+}
+
+// NewNameObserver returns a NameObserver which forgets names that were not seen within the retention period
+func NewNameObserver(retention time.Duration) *NameObserver {
+	return &NameObserver{
+		retention: retention,
+		names:     make(map[v1alpha1.FQDN]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Observe records the given names as seen now. Invalid names are ignored.
+func (o *NameObserver) Observe(names ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.now()
+	o.expire(now)
+	for _, name := range names {
+		fqdn := v1alpha1.FQDN(strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), ".")))
+		if !fqdn.Valid() {
+			continue
+		}
+		if _, ok := o.names[fqdn]; !ok && len(o.names) >= maxObservedNames {
+			continue
+		}
+		o.names[fqdn] = now
+	}
+}
+
+// expire removes the names that were not seen within the retention period. Must be called with the lock held.
+func (o *NameObserver) expire(now time.Time) {
+	for fqdn, seen := range o.names {
+		if now.Sub(seen) > o.retention {
+			delete(o.names, fqdn)
+		}
+	}
+}
+
+// Names returns the recorded names matching the pattern, sorted alphabetically
+func (o *NameObserver) Names(pattern v1alpha1.FQDNPattern) []v1alpha1.FQDN {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	now := o.now()
+	var fqdns []v1alpha1.FQDN
+	for fqdn, seen := range o.names {
+		if now.Sub(seen) <= o.retention && pattern.Matches(fqdn) {
+			fqdns = append(fqdns, fqdn)
+		}
+	}
+	sort.SliceStable(fqdns, func(i, j int) bool {
+		return fqdns[i] < fqdns[j]
+	})
+	return fqdns
+}
+
+// ServeHTTP lets an external DNS observer report names it has seen. The request body of a POST contains one name per
+// line.
+func (o *NameObserver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(http.MaxBytesReader(w, req.Body, 1<<20))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			names = append(names, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	o.Observe(names...)
+	w.WriteHeader(http.StatusNoContent)
+}
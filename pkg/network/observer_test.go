@@ -0,0 +1,75 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+func TestNameObserverNormalizesAndMatchesNames(t *testing.T) {
+	observer := NewNameObserver(time.Hour)
+	observer.Observe("API.Example.com.", " cdn.example.com ", "a.b.example.com", "example.org", "not a name")
+
+	names := observer.Names("*.example.com")
+	expected := []v1alpha1.FQDN{"api.example.com", "cdn.example.com"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	names = observer.Names("**.example.com")
+	expected = []v1alpha1.FQDN{"a.b.example.com", "api.example.com", "cdn.example.com"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestNameObserverForgetsNamesAfterRetention(t *testing.T) {
+	observer := NewNameObserver(time.Minute)
+	now := time.Now()
+	observer.now = func() time.Time { return now }
+	observer.Observe("old.example.com")
+
+	now = now.Add(30 * time.Second)
+	observer.Observe("new.example.com")
+	if names := observer.Names("*.example.com"); len(names) != 2 {
+		t.Fatalf("expected both names within the retention, got %v", names)
+	}
+
+	now = now.Add(45 * time.Second)
+	expected := []v1alpha1.FQDN{"new.example.com"}
+	if names := observer.Names("*.example.com"); !slices.Equal(names, expected) {
+		t.Errorf("expected %v after the retention of the old name, got %v", expected, names)
+	}
+
+	// Observing again refreshes the name
+	observer.Observe("new.example.com")
+	now = now.Add(45 * time.Second)
+	if names := observer.Names("*.example.com"); !slices.Equal(names, expected) {
+		t.Errorf("expected the refreshed name %v, got %v", expected, names)
+	}
+}
+
+func TestNameObserverServeHTTP(t *testing.T) {
+	observer := NewNameObserver(time.Hour)
+
+	recorder := httptest.NewRecorder()
+	observer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/observed-names", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d for GET, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	body := strings.NewReader("api.example.com\n\n  cdn.example.com.\n")
+	observer.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/observed-names", body))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("expected status %d for POST, got %d", http.StatusNoContent, recorder.Code)
+	}
+	expected := []v1alpha1.FQDN{"api.example.com", "cdn.example.com"}
+	if names := observer.Names("*.example.com"); !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// poolIdleTimeout is how long a resolver of a DNSResolverPool is kept after it was last requested
+	poolIdleTimeout = time.Hour
+	// maxPoolResolvers bounds the number of resolvers of a DNSResolverPool, the least recently requested resolver is
+	// evicted first
+	maxPoolResolvers = 1000
+)
+
+type poolEntry struct {
+	resolver *DNSResolver
+	client   *Client
+	lastUsed time.Time
+}
+
+// DNSResolverPool provides one DNSResolver per resolver configuration. All resolvers of the pool share the DNS cache
+// and the name observer. Resolvers which were not requested within poolIdleTimeout are evicted, as the configurations
+// of deleted network policies are never requested again.
+type DNSResolverPool struct {
+	mu        sync.Mutex
+	cache     *Cache
+	observer  *NameObserver
+	fallback  *DNSResolver
+	resolvers map[string]*poolEntry
+	now       func() time.Time
+}
+
+// NewDNSResolverPool returns a pool whose default resolver queries the nameservers of defaultConfig, or the
+// nameservers of /etc/resolv.conf if defaultConfig is nil.
+func NewDNSResolverPool(cache *Cache, observer *NameObserver, defaultConfig *ClientConfig) *DNSResolverPool {
+	p := &DNSResolverPool{
+		cache:     cache,
+		observer:  observer,
+		resolvers: make(map[string]*poolEntry),
+		now:       time.Now,
+	}
+	if defaultConfig != nil {
+		// The default resolver is kept outside of the evicted resolvers
+		p.fallback = p.newDNSResolver(defaultConfig.Key(), NewClient(*defaultConfig))
+	} else {
+		p.fallback = p.newDNSResolver("default", NewDefaultResolver())
+	}
+	return p
+}
+
+// Default returns the resolver used by network policies without resolver configuration
+func (p *DNSResolverPool) Default() *DNSResolver {
+	return p.fallback
+}
+
+// ForConfig returns the resolver querying the nameservers of the config. Resolvers are created on first use and
+// reused for identical configs. Unused resolvers are evicted when a resolver is created.
+func (p *DNSResolverPool) ForConfig(config ClientConfig) *DNSResolver {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	key := config.Key()
+	if entry, ok := p.resolvers[key]; ok {
+		entry.lastUsed = now
+		return entry.resolver
+	}
+	p.evict(now)
+	client := NewClient(config)
+	entry := &poolEntry{resolver: p.newDNSResolver(key, client), client: client, lastUsed: now}
+	p.resolvers[key] = entry
+	return entry.resolver
+}
+
+// evict removes the resolvers which were not requested within poolIdleTimeout, and the least recently requested
+// resolver if the pool is full. Must be called with the lock held.
+func (p *DNSResolverPool) evict(now time.Time) {
+	var oldestKey string
+	var oldest *poolEntry
+	for key, entry := range p.resolvers {
+		if now.Sub(entry.lastUsed) > poolIdleTimeout {
+			p.remove(key)
+			continue
+		}
+		if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if oldest != nil && len(p.resolvers) >= maxPoolResolvers {
+		p.remove(oldestKey)
+	}
+}
+
+// remove removes the resolver of the key and closes its idle connections. Must be called with the lock held.
+func (p *DNSResolverPool) remove(key string) {
+	if entry, ok := p.resolvers[key]; ok {
+		entry.client.CloseIdleConnections()
+		delete(p.resolvers, key)
+	}
+}
+
+func (p *DNSResolverPool) newDNSResolver(scope string, upstream Resolver) *DNSResolver {
+	if p.cache != nil {
+		upstream = p.cache.Resolver(scope, upstream)
+	}
+	return NewDNSResolver(WithResolver(upstream), WithNameObserver(p.observer))
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDNSResolverPoolReusesResolvers(t *testing.T) {
+	pool := NewDNSResolverPool(NewCache(CacheOptions{}), nil, nil)
+	config := ClientConfig{Servers: []string{"192.0.2.53:53"}}
+	if pool.ForConfig(config) != pool.ForConfig(config) {
+		t.Error("expected identical configs to share a resolver")
+	}
+	if pool.ForConfig(config) == pool.ForConfig(ClientConfig{Servers: []string{"192.0.2.54:53"}}) {
+		t.Error("expected different configs to use different resolvers")
+	}
+}
+
+func TestDNSResolverPoolEvictsIdleResolvers(t *testing.T) {
+	pool := NewDNSResolverPool(NewCache(CacheOptions{}), nil, nil)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+	idle := ClientConfig{Servers: []string{"192.0.2.53:53"}}
+	used := ClientConfig{Servers: []string{"192.0.2.54:53"}}
+	pool.ForConfig(idle)
+	pool.ForConfig(used)
+
+	now = now.Add(poolIdleTimeout / 2)
+	pool.ForConfig(used)
+	now = now.Add(poolIdleTimeout/2 + time.Second)
+	pool.ForConfig(ClientConfig{Servers: []string{"192.0.2.55:53"}})
+
+	if _, ok := pool.resolvers[idle.Key()]; ok {
+		t.Error("expected the idle resolver to be evicted")
+	}
+	if _, ok := pool.resolvers[used.Key()]; !ok {
+		t.Error("expected the recently used resolver to be kept")
+	}
+}
+
+func TestDNSResolverPoolEvictsLeastRecentlyUsedResolver(t *testing.T) {
+	pool := NewDNSResolverPool(NewCache(CacheOptions{}), nil, nil)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+	config := func(i int) ClientConfig {
+		return ClientConfig{Servers: []string{fmt.Sprintf("192.0.2.1:%d", 1000+i)}}
+	}
+	for i := range maxPoolResolvers {
+		now = now.Add(time.Millisecond)
+		pool.ForConfig(config(i))
+	}
+	now = now.Add(time.Millisecond)
+	pool.ForConfig(config(0))
+	now = now.Add(time.Millisecond)
+	pool.ForConfig(config(maxPoolResolvers))
+
+	if len(pool.resolvers) != maxPoolResolvers {
+		t.Errorf("expected %d resolvers, got %d", maxPoolResolvers, len(pool.resolvers))
+	}
+	if _, ok := pool.resolvers[config(1).Key()]; ok {
+		t.Error("expected the least recently used resolver to be evicted")
+	}
+	if _, ok := pool.resolvers[config(0).Key()]; !ok {
+		t.Error("expected the reused resolver to be kept")
+	}
+}
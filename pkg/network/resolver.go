@@ -5,13 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
 )
 
+// tracer creates the spans of the DNS lookups. Spans are only exported if a tracer provider is installed.
+var tracer = otel.Tracer("github.com/mransonwang/fqdn-egress-operator/pkg/network")
+
 type lookupError struct {
 	Reason  v1alpha1.NetworkPolicyResolvedConditionReason
 	Message string
@@ -33,11 +43,19 @@ type DNSResolverResult struct {
 	Message string
 	// CIDRs found for the given domain if no error occurred
 	CIDRs []*v1alpha1.CIDR
+	// TTL of the DNS records, zero if the resolver did not report it
+	TTL time.Duration
+	// CNAMEs is the chain of canonical names followed from the domain to the CIDRs, empty if the domain has address
+	// records itself
+	CNAMEs []string
+	// PartialCNAMEs is true if the resolver does not report the CNAME chain, CNAMEs then only holds its last name
+	PartialCNAMEs bool
 }
 
 func NewDNSResolverResult(
 	domain v1alpha1.FQDN,
 	CIDRs []*v1alpha1.CIDR,
+	ttl time.Duration,
 	error error) *DNSResolverResult {
 
 	sort.SliceStable(CIDRs, func(i, j int) bool {
@@ -50,7 +68,17 @@ func NewDNSResolverResult(
 		Message: resolveMessage(error),
 		Status:  resolveReason(error),
 		CIDRs:   CIDRs,
+		TTL:     ttl,
+	}
+}
+
+// canonicalNames returns the names of the CNAME chain without the trailing dot of absolute names
+func canonicalNames(cnames []string) []string {
+	var names []string
+	for _, cname := range cnames {
+		names = append(names, strings.TrimSuffix(cname, "."))
 	}
+	return names
 }
 
 // resolveReason returns the reason for the status of the resolve result
@@ -141,6 +169,43 @@ func (dlr DNSResolverResultList) AggregatedResolveMessage() string {
 	return message
 }
 
+// RestrictCNAMEs fails the successful results whose CNAME chain leaves the allowed domains. The chain of a failed
+// result ends at the first name outside of the domains and its CIDRs are dropped. Results with a partial chain fail,
+// as names in the middle of the chain could leave the domains unnoticed. Any CNAME is allowed if no domains are given.
+func (dlr DNSResolverResultList) RestrictCNAMEs(allowed []v1alpha1.FQDN) {
+	if len(allowed) == 0 {
+		return
+	}
+	for _, dr := range dlr {
+		if dr.Error != nil {
+			continue
+		}
+		if dr.PartialCNAMEs {
+			err := &lookupError{
+				Reason: v1alpha1.NetworkPolicyResolveCNAMENotAllowed,
+				Message: fmt.Sprintf(
+					"the resolver does not report the CNAME chain of '%s' required by the allowed CNAME suffixes",
+					dr.Domain,
+				),
+			}
+			dr.Error, dr.Status, dr.Message, dr.CIDRs = err, resolveReason(err), resolveMessage(err), nil
+			continue
+		}
+		for i, cname := range dr.CNAMEs {
+			if slices.ContainsFunc(allowed, v1alpha1.FQDN(cname).InDomain) {
+				continue
+			}
+			err := &lookupError{
+				Reason:  v1alpha1.NetworkPolicyResolveCNAMENotAllowed,
+				Message: fmt.Sprintf("CNAME '%s' of '%s' is outside of the allowed CNAME suffixes", cname, dr.Domain),
+			}
+			dr.Error, dr.Status, dr.Message = err, resolveReason(err), resolveMessage(err)
+			dr.CIDRs, dr.CNAMEs = nil, dr.CNAMEs[:i+1]
+			break
+		}
+	}
+}
+
 // LookupTable returns a FQDN lookup table for the result list
 func (dlr DNSResolverResultList) LookupTable() map[v1alpha1.FQDN]*DNSResolverResult {
 	lookup := make(map[v1alpha1.FQDN]*DNSResolverResult)
@@ -154,43 +219,198 @@ type Resolver interface {
 	LookupIP(ctx context.Context, network string, host string) ([]net.IP, error)
 }
 
+// CNAMEResolver is implemented by Resolvers that can look up the canonical name of a host
+type CNAMEResolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// SRVResolver is implemented by Resolvers that can look up the SRV records of a service, like net.Resolver
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVRecordResolver is implemented by Resolvers that report the TTL of the SRV records they resolved
+type SRVRecordResolver interface {
+	LookupSRVRecords(ctx context.Context, name string) (*Records, error)
+}
+
+// lookupSRVRecords resolves the SRV records of the name using the SRVRecordResolver interface when the resolver
+// implements it. The boolean result is false if the resolver did not report the TTL of the records.
+func lookupSRVRecords(ctx context.Context, resolver Resolver, name string) (*Records, bool, error) {
+	switch srvResolver := resolver.(type) {
+	case SRVRecordResolver:
+		records, err := srvResolver.LookupSRVRecords(ctx, name)
+		return records, true, err
+	case SRVResolver:
+		// Empty service and proto look up the name as-is
+		_, srvs, err := srvResolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, false, err
+		}
+		return &Records{SRVs: srvs}, false, nil
+	}
+	return nil, false, &lookupError{
+		Reason:  v1alpha1.NetworkPolicyResolveOtherError,
+		Message: "The resolver does not support SRV lookups",
+	}
+}
+
 // DNSResolver resolves domains to IPs
 type DNSResolver struct {
 	resolver Resolver
+	observer *NameObserver
+}
+
+// DNSResolverOption configures a DNSResolver
+type DNSResolverOption func(*DNSResolver)
+
+// WithNameObserver records the canonical names of resolved FQDNs in the observer
+func WithNameObserver(observer *NameObserver) DNSResolverOption {
+	return func(r *DNSResolver) {
+		r.observer = observer
+	}
+}
+
+// WithResolver replaces the resolver used for lookups
+func WithResolver(resolver Resolver) DNSResolverOption {
+	return func(r *DNSResolver) {
+		r.resolver = resolver
+	}
 }
 
 // NewDNSResolver returns the default resolver to use for DNS lookup
-func NewDNSResolver() *DNSResolver {
-	return &DNSResolver{
-		resolver: &net.Resolver{},
+func NewDNSResolver(opts ...DNSResolverOption) *DNSResolver {
+	r := &DNSResolver{
+		resolver: NewDefaultResolver(),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
-// lookupIP resolves the host to its underlying IP addresses
+// NewDefaultResolver returns a Resolver querying the nameservers of /etc/resolv.conf directly, so that record TTLs are
+// known. Falls back to the system resolver if no nameservers are configured.
+func NewDefaultResolver() Resolver {
+	config, err := ClientConfigFromResolvConf("/etc/resolv.conf")
+	if err != nil {
+		return &net.Resolver{}
+	}
+	return NewClient(config)
+}
+
+// lookupCNAME returns the canonical name of the host, or an empty string if the host has no CNAME or the resolver
+// does not implement CNAMEResolver. Only the end of the chain is known, the intermediate names are not reported. A
+// failed lookup is an error, so a chain that cannot be checked does not pass as no chain.
+func (r *DNSResolver) lookupCNAME(ctx context.Context, host v1alpha1.FQDN) (string, error) {
+	cnameResolver, ok := r.resolver.(CNAMEResolver)
+	if !ok {
+		return "", nil
+	}
+	cname, err := cnameResolver.LookupCNAME(ctx, string(host))
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(strings.TrimSuffix(cname, "."), string(host)) {
+		return "", nil
+	}
+	return cname, nil
+}
+
+// reportsCNAMEChains returns true if the resolver reports the full CNAME chain of the hosts it resolves
+func reportsCNAMEChains(resolver Resolver) bool {
+	if caching, ok := resolver.(*cachingResolver); ok {
+		return reportsCNAMEChains(caching.upstream)
+	}
+	_, ok := resolver.(RecordResolver)
+	return ok
+}
+
+// lookupRecords resolves the host using the RecordResolver interface when the resolver implements it, so the TTL and
+// CNAME chain of the answer are known. Otherwise the addresses are returned with the canonical name of the host, if
+// the resolver can look it up.
+func (r *DNSResolver) lookupRecords(
+	ctx context.Context,
+	networkType v1alpha1.NetworkType,
+	host v1alpha1.FQDN,
+) (*Records, error) {
+	if recordResolver, ok := r.resolver.(RecordResolver); ok {
+		records, err := recordResolver.LookupRecords(ctx, networkType.ResolverString(), string(host))
+		if err != nil {
+			return nil, err
+		}
+		if r.observer != nil {
+			r.observer.Observe(records.CNAMEs...)
+		}
+		return records, nil
+	}
+
+	ips, err := r.resolver.LookupIP(ctx, networkType.ResolverString(), string(host))
+	if err != nil {
+		return nil, err
+	}
+	records := &Records{IPs: ips}
+	cname, err := r.lookupCNAME(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if cname != "" {
+		records.CNAMEs = []string{cname}
+		if r.observer != nil {
+			r.observer.Observe(cname)
+		}
+	}
+	return records, nil
+}
+
+// lookupIP resolves the host to its underlying IP addresses and the records they were found in, in a span reporting
+// the resolve reason and the number of addresses
 func (r *DNSResolver) lookupIP(
 	ctx context.Context,
 	networkType v1alpha1.NetworkType,
 	host v1alpha1.FQDN,
-) ([]*v1alpha1.CIDR, error) {
+) ([]*v1alpha1.CIDR, *Records, error) {
+	ctx, span := tracer.Start(ctx, "DNSResolver.lookupIP", trace.WithAttributes(tracing.FQDNKey.String(string(host))))
+	defer span.End()
+
+	cidrs, records, err := r.lookupCIDRs(ctx, networkType, host)
+	span.SetAttributes(
+		tracing.ReasonKey.String(string(resolveReason(err))),
+		tracing.AddressCountKey.Int(len(cidrs)),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, resolveMessage(err))
+	}
+	return cidrs, records, err
+}
+
+// lookupCIDRs resolves the host to the CIDRs of its IP addresses and the records they were found in. The records are
+// nil if the lookup failed.
+func (r *DNSResolver) lookupCIDRs(
+	ctx context.Context,
+	networkType v1alpha1.NetworkType,
+	host v1alpha1.FQDN,
+) ([]*v1alpha1.CIDR, *Records, error) {
 	if !host.Valid() {
-		return nil, &lookupError{
+		return nil, nil, &lookupError{
 			Reason:  v1alpha1.NetworkPolicyResolveInvalidDomain,
 			Message: fmt.Sprintf("Received invalid FQDN '%s'", host),
 		}
 	}
-	ips, err := r.resolver.LookupIP(ctx, networkType.ResolverString(), string(host))
+	records, err := r.lookupRecords(ctx, networkType, host)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var cidrs []*v1alpha1.CIDR
-	for _, ip := range ips {
+	for _, ip := range records.IPs {
 		prefix := 128
 		if ip.To4() != nil {
 			prefix = 32
 		}
 		cidrs = append(cidrs, &v1alpha1.CIDR{IP: ip, Prefix: prefix})
 	}
-	return cidrs, nil
+	return cidrs, records, nil
 }
 
 // Resolve all the given fqdns to a DNSResolverResult
@@ -202,13 +422,50 @@ func (r *DNSResolver) Resolve(
 	networkType v1alpha1.NetworkType,
 	fqdns []v1alpha1.FQDN,
 ) DNSResolverResultList {
-	results := make(chan *DNSResolverResult)
+	ctx, span := tracer.Start(ctx, "DNSResolver.Resolve", trace.WithAttributes(tracing.FQDNCountKey.Int(len(fqdns))))
+	defer span.End()
+
+	lookupResults := DNSResolverResultList(resolveConcurrently(
+		ctx, timeout, maxConcurrent, fqdns,
+		func(ctx context.Context, fqdn v1alpha1.FQDN) *DNSResolverResult {
+			start := time.Now()
+			cidrs, records, err := r.lookupIP(ctx, networkType, fqdn)
+			var ttl time.Duration
+			var cnames []string
+			if records != nil {
+				ttl, cnames = records.TTL, canonicalNames(records.CNAMEs)
+			}
+			result := NewDNSResolverResult(fqdn, cidrs, ttl, err)
+			result.CNAMEs = cnames
+			result.PartialCNAMEs = !reportsCNAMEChains(r.resolver)
+			resolveDuration.WithLabelValues(resolveDurationFQDNs.label(fqdn), string(result.Status)).
+				Observe(time.Since(start).Seconds())
+			return result
+		},
+	))
+	span.SetAttributes(
+		tracing.ReasonKey.String(string(lookupResults.AggregatedResolveStatus())),
+		tracing.AddressCountKey.Int(len(lookupResults.CIDRs())),
+	)
+	return lookupResults
+}
+
+// resolveConcurrently looks up each of the names with at most maxConcurrent lookups in flight, each bounded by the
+// timeout, and collects the results. Names whose lookup did not start before the context was cancelled are skipped.
+func resolveConcurrently[N any, R any](
+	ctx context.Context,
+	timeout time.Duration,
+	maxConcurrent int,
+	names []N,
+	lookup func(context.Context, N) R,
+) []R {
+	results := make(chan R)
 	sem := make(chan struct{}, maxConcurrent)
 
 	var wg sync.WaitGroup
-	for _, fqdn := range fqdns {
+	for _, name := range names {
 		wg.Add(1)
-		go func(rFQDN v1alpha1.FQDN) {
+		go func(name N) {
 			defer wg.Done()
 
 			select {
@@ -222,15 +479,14 @@ func (r *DNSResolver) Resolve(
 
 			childCtx, cancel := context.WithTimeout(ctx, timeout)
 			defer cancel()
-
-			cidrs, err := r.lookupIP(childCtx, networkType, rFQDN)
+			result := lookup(childCtx, name)
 
 			select {
-			case results <- NewDNSResolverResult(fqdn, cidrs, err):
+			case results <- result:
 			case <-ctx.Done():
 				// context cancelled while trying to send
 			}
-		}(fqdn)
+		}(name)
 	}
 
 	go func() {
@@ -238,15 +494,16 @@ func (r *DNSResolver) Resolve(
 		close(results)
 	}()
 
-	var lookupResults []*DNSResolverResult
-	for res := range results {
-		lookupResults = append(lookupResults, res)
+	var collected []R
+	for result := range results {
+		collected = append(collected, result)
 	}
-	return lookupResults
+	return collected
 }
 
 type FakeDNSResolver struct {
-	Results DNSResolverResultList
+	Results    DNSResolverResultList
+	SRVResults SRVResolverResultList
 }
 
 func (r *FakeDNSResolver) Resolve(
@@ -258,3 +515,12 @@ func (r *FakeDNSResolver) Resolve(
 ) DNSResolverResultList {
 	return r.Results
 }
+
+func (r *FakeDNSResolver) ResolveSRV(
+	_ context.Context,
+	_ time.Duration,
+	_ int,
+	_ []v1alpha1.SRVName,
+) SRVResolverResultList {
+	return r.SRVResults
+}
@@ -0,0 +1,147 @@
+package network
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
+)
+
+func TestResolveRecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	upstream := &countingResolver{records: &Records{IPs: []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}}}
+	resolver := NewDNSResolver(WithResolver(upstream))
+	results := resolver.Resolve(
+		context.Background(), time.Second, 2, v1alpha1.IPv4, []v1alpha1.FQDN{"example.com", "example.org"},
+	)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	lookups := map[string]sdktrace.ReadOnlySpan{}
+	var resolve sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "DNSResolver.Resolve":
+			resolve = span
+		case "DNSResolver.lookupIP":
+			for _, attr := range span.Attributes() {
+				if attr.Key == tracing.FQDNKey {
+					lookups[attr.Value.AsString()] = span
+				}
+			}
+		}
+	}
+	if resolve == nil {
+		t.Fatal("expected a DNSResolver.Resolve span")
+	}
+	if len(lookups) != 2 {
+		t.Fatalf("expected a lookupIP span per FQDN, got %d", len(lookups))
+	}
+	for fqdn, span := range lookups {
+		if span.Parent().SpanID() != resolve.SpanContext().SpanID() {
+			t.Errorf("expected the lookupIP span of %s to be a child of the Resolve span", fqdn)
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == tracing.AddressCountKey && attr.Value.AsInt64() != 2 {
+				t.Errorf("expected 2 addresses for %s, got %d", fqdn, attr.Value.AsInt64())
+			}
+			if attr.Key == tracing.ReasonKey && attr.Value.AsString() != string(v1alpha1.NetworkPolicyResolveSuccess) {
+				t.Errorf("expected reason %s for %s, got %s", v1alpha1.NetworkPolicyResolveSuccess, fqdn, attr.Value.AsString())
+			}
+		}
+	}
+}
+
+func TestRestrictCNAMEs(t *testing.T) {
+	cidrs := []*v1alpha1.CIDR{v1alpha1.MustCIDR("192.0.2.1/32")}
+	results := DNSResolverResultList{
+		NewDNSResolverResult("www.example.com", cidrs, time.Minute, nil),
+		NewDNSResolverResult("api.example.com", cidrs, time.Minute, nil),
+		NewDNSResolverResult("static.example.com", cidrs, time.Minute, nil),
+	}
+	results[0].CNAMEs = []string{"www.example.com.cdn.example.net", "e1.Edge.Example.NET"}
+	results[1].CNAMEs = []string{"api.example.com.cdn.example.net", "attacker.example.org", "e2.edge.example.net"}
+
+	results.RestrictCNAMEs([]v1alpha1.FQDN{"example.com", "example.net"})
+
+	if results[0].Status != v1alpha1.NetworkPolicyResolveSuccess || len(results[0].CIDRs) != 1 {
+		t.Errorf("expected a chain within the allowed suffixes to succeed, got %s", results[0].Status)
+	}
+	if results[1].Status != v1alpha1.NetworkPolicyResolveCNAMENotAllowed || len(results[1].CIDRs) != 0 {
+		t.Errorf("expected a chain leaving the allowed suffixes to fail, got %s with %v", results[1].Status, results[1].CIDRs)
+	}
+	if !slices.Equal(results[1].CNAMEs, []string{"api.example.com.cdn.example.net", "attacker.example.org"}) {
+		t.Errorf("expected the chain to end at the first name outside of the suffixes, got %v", results[1].CNAMEs)
+	}
+	if results[2].Status != v1alpha1.NetworkPolicyResolveSuccess {
+		t.Errorf("expected an FQDN without CNAMEs to succeed, got %s", results[2].Status)
+	}
+}
+
+// cnameResolver reports the addresses of a host and fails to look up its canonical name
+type cnameResolver struct {
+	err error
+}
+
+func (r *cnameResolver) LookupIP(context.Context, string, string) ([]net.IP, error) {
+	return []net.IP{net.ParseIP("192.0.2.1")}, nil
+}
+
+func (r *cnameResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	return host + ".", r.err
+}
+
+func TestRestrictCNAMEsFailsClosed(t *testing.T) {
+	allowed := []v1alpha1.FQDN{"example.com"}
+	fqdns := []v1alpha1.FQDN{"www.example.com"}
+
+	t.Run("fails a result with a partial chain", func(t *testing.T) {
+		results := NewDNSResolver(WithResolver(&cnameResolver{})).Resolve(
+			context.Background(), time.Second, 1, v1alpha1.IPv4, fqdns,
+		)
+		if results[0].Status != v1alpha1.NetworkPolicyResolveSuccess || !results[0].PartialCNAMEs {
+			t.Fatalf("expected a successful lookup with a partial chain, got %s", results[0].Status)
+		}
+		results.RestrictCNAMEs(allowed)
+		if results[0].Status != v1alpha1.NetworkPolicyResolveCNAMENotAllowed || len(results[0].CIDRs) != 0 {
+			t.Errorf("expected a partial chain to fail, got %s with %v", results[0].Status, results[0].CIDRs)
+		}
+	})
+
+	t.Run("fails a lookup whose CNAME lookup fails", func(t *testing.T) {
+		resolver := &cnameResolver{err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}
+		results := NewDNSResolver(WithResolver(resolver)).Resolve(
+			context.Background(), time.Second, 1, v1alpha1.IPv4, fqdns,
+		)
+		if results[0].Error == nil || len(results[0].CIDRs) != 0 {
+			t.Errorf("expected the lookup to fail, got %s with %v", results[0].Status, results[0].CIDRs)
+		}
+	})
+
+	t.Run("keeps a full chain of a record resolver", func(t *testing.T) {
+		upstream := &countingResolver{records: &Records{
+			IPs: []net.IP{net.ParseIP("192.0.2.1")}, CNAMEs: []string{"edge.example.com."},
+		}}
+		results := NewDNSResolver(WithResolver(upstream)).Resolve(
+			context.Background(), time.Second, 1, v1alpha1.IPv4, fqdns,
+		)
+		results.RestrictCNAMEs(allowed)
+		if results[0].Status != v1alpha1.NetworkPolicyResolveSuccess || results[0].PartialCNAMEs {
+			t.Errorf("expected a full chain within the suffixes to succeed, got %s", results[0].Status)
+		}
+	})
+}
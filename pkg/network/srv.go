@@ -0,0 +1,117 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+	"github.com/mransonwang/fqdn-egress-operator/pkg/tracing"
+)
+
+// SRVResolverResult is the outcome of the lookup of the SRV records of a name
+type SRVResolverResult struct {
+	// Name that the lookup was for
+	Name v1alpha1.SRVName
+	// Error that the lookup may have caused
+	Error error
+	// Resolve status
+	Status v1alpha1.NetworkPolicyResolvedConditionReason
+	// Message for the reason
+	Message string
+	// Targets found for the given name if no error occurred, sorted by target and port
+	Targets []v1alpha1.SRVTarget
+	// TTL of the SRV records, zero if the resolver did not report it
+	TTL time.Duration
+}
+
+// NewSRVResolverResult returns the result of the lookup of the SRV records of the name. Records whose target is '.'
+// announce that the service is not available and are skipped like records with an invalid target or port.
+func NewSRVResolverResult(
+	name v1alpha1.SRVName,
+	srvs []*net.SRV,
+	ttl time.Duration,
+	err error,
+) *SRVResolverResult {
+	var targets []v1alpha1.SRVTarget
+	for _, srv := range srvs {
+		target := v1alpha1.FQDN(strings.ToLower(strings.TrimSuffix(srv.Target, ".")))
+		if !target.Valid() || srv.Port == 0 {
+			continue
+		}
+		targets = append(targets, v1alpha1.SRVTarget{Target: target, Port: int32(srv.Port)})
+	}
+	slices.SortFunc(targets, v1alpha1.SRVTarget.Compare)
+
+	return &SRVResolverResult{
+		Name:    name,
+		Error:   err,
+		Message: resolveMessage(err),
+		Status:  resolveReason(err),
+		Targets: slices.Compact(targets),
+		TTL:     ttl,
+	}
+}
+
+// SRVResolverResultList is a wrapper around SRVResolverResult with helpful getter methods
+type SRVResolverResultList []*SRVResolverResult
+
+// LookupTable returns an SRV name lookup table for the result list
+func (l SRVResolverResultList) LookupTable() map[v1alpha1.SRVName]*SRVResolverResult {
+	lookup := make(map[v1alpha1.SRVName]*SRVResolverResult)
+	for _, result := range l {
+		lookup[result.Name] = result
+	}
+	return lookup
+}
+
+// ResolveSRV resolves the SRV records of all the given names to their targets and ports
+//   - maxConcurrent controls how many goroutines are spawned to look up the SRV records
+func (r *DNSResolver) ResolveSRV(
+	ctx context.Context,
+	timeout time.Duration,
+	maxConcurrent int,
+	names []v1alpha1.SRVName,
+) SRVResolverResultList {
+	ctx, span := tracer.Start(ctx, "DNSResolver.ResolveSRV", trace.WithAttributes(tracing.FQDNCountKey.Int(len(names))))
+	defer span.End()
+
+	return resolveConcurrently(ctx, timeout, maxConcurrent, names,
+		func(ctx context.Context, name v1alpha1.SRVName) *SRVResolverResult {
+			records, err := r.lookupSRV(ctx, name)
+			if err != nil {
+				return NewSRVResolverResult(name, nil, 0, err)
+			}
+			return NewSRVResolverResult(name, records.SRVs, records.TTL, nil)
+		},
+	)
+}
+
+// lookupSRV resolves the SRV records of the name, in a span reporting the resolve reason
+func (r *DNSResolver) lookupSRV(ctx context.Context, name v1alpha1.SRVName) (*Records, error) {
+	ctx, span := tracer.Start(ctx, "DNSResolver.lookupSRV", trace.WithAttributes(tracing.FQDNKey.String(string(name))))
+	defer span.End()
+
+	var records *Records
+	var err error
+	if name.Valid() {
+		records, _, err = lookupSRVRecords(ctx, r.resolver, string(name))
+	} else {
+		err = &lookupError{
+			Reason:  v1alpha1.NetworkPolicyResolveInvalidDomain,
+			Message: fmt.Sprintf("Received invalid SRV name '%s'", name),
+		}
+	}
+	span.SetAttributes(tracing.ReasonKey.String(string(resolveReason(err))))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, resolveMessage(err))
+	}
+	return records, err
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName is the service name reported in the spans of the operator
+const ServiceName = "fqdn-egress-operator"
+
+// The attributes set on the spans of the operator
+const (
+	// PolicyNameKey is the name of the FQDN network policy being reconciled
+	PolicyNameKey = attribute.Key("fqdn_egress.policy")
+	// FQDNKey is the FQDN being resolved
+	FQDNKey = attribute.Key("fqdn_egress.fqdn")
+	// FQDNCountKey is the number of FQDNs resolved in a batch
+	FQDNCountKey = attribute.Key("fqdn_egress.fqdn_count")
+	// ReasonKey is the resolve reason of an FQDN or of a network policy
+	ReasonKey = attribute.Key("fqdn_egress.reason")
+	// AddressCountKey is the number of addresses resolved for an FQDN, or applied in a network policy
+	AddressCountKey = attribute.Key("fqdn_egress.address_count")
+	// ResolvedAddressCountKey is the number of addresses resolved for a network policy before filtering
+	ResolvedAddressCountKey = attribute.Key("fqdn_egress.resolved_address_count")
+	// OperationKey is the result of a create or update of a generated object
+	OperationKey = attribute.Key("fqdn_egress.operation")
+)
+
+// Options configures the export of the spans
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC collector. Tracing is disabled if empty.
+	Endpoint string
+	// Insecure disables TLS for the connection to the collector
+	Insecure bool
+	// SamplingRatio is the fraction of the traces that are sampled, between 0 and 1
+	SamplingRatio float64
+}
+
+// Setup installs the global tracer provider exporting the spans to the OTLP collector. The returned function flushes
+// and stops the exporter. When no endpoint is configured, the global no-op tracer provider is kept.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL, semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SamplingRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+// GetNetworkPolicyDefaults returns the spec of the NetworkPolicyDefaults of the namespace, or nil if the namespace
+// has none or the CRD is not installed
+func GetNetworkPolicyDefaults(
+	ctx context.Context, reader client.Reader, namespace string,
+) (*v1alpha1.NetworkPolicyDefaultsSpec, error) {
+	if reader == nil {
+		return nil, nil
+	}
+	defaults := &v1alpha1.NetworkPolicyDefaults{}
+	key := client.ObjectKey{Namespace: namespace, Name: v1alpha1.NetworkPolicyDefaultsName}
+	err := reader.Get(ctx, key, defaults)
+	switch {
+	case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &defaults.Spec, nil
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+// governanceCache holds the compiled FQDNGovernance of each FQDNGovernancePolicy by name, so the regexes are only
+// compiled again when the policy changes
+var governanceCache = struct {
+	sync.Mutex
+	policies map[string]cachedGovernance
+}{policies: map[string]cachedGovernance{}}
+
+type cachedGovernance struct {
+	resourceVersion string
+	governance      *v1alpha1.FQDNGovernance
+}
+
+// compileFQDNGovernance returns the FQDNGovernance of each policy, compiled once per resourceVersion. The policies
+// missing from all, the listed policies, were removed and are dropped from the cache.
+func compileFQDNGovernance(
+	policies []v1alpha1.FQDNGovernancePolicy, all []v1alpha1.FQDNGovernancePolicy,
+) []*v1alpha1.FQDNGovernance {
+	governanceCache.Lock()
+	defer governanceCache.Unlock()
+	current := make(map[string]struct{}, len(all))
+	for _, policy := range all {
+		current[policy.Name] = struct{}{}
+	}
+	for name := range governanceCache.policies {
+		if _, ok := current[name]; !ok {
+			delete(governanceCache.policies, name)
+		}
+	}
+
+	governances := make([]*v1alpha1.FQDNGovernance, 0, len(policies))
+	for _, policy := range policies {
+		cached, ok := governanceCache.policies[policy.Name]
+		if !ok || policy.ResourceVersion == "" || cached.resourceVersion != policy.ResourceVersion {
+			cached = cachedGovernance{
+				resourceVersion: policy.ResourceVersion,
+				governance:      v1alpha1.NewFQDNGovernance([]v1alpha1.FQDNGovernancePolicy{policy}),
+			}
+			governanceCache.policies[policy.Name] = cached
+		}
+		governances = append(governances, cached.governance)
+	}
+	return governances
+}
+
+// GetFQDNGovernance returns the FQDNGovernance of the FQDNGovernancePolicies selecting the namespace, or nil if no
+// policy selects it or the CRD is not installed
+func GetFQDNGovernance(
+	ctx context.Context, reader client.Reader, namespace string,
+) (*v1alpha1.FQDNGovernance, error) {
+	if reader == nil || namespace == "" {
+		return nil, nil
+	}
+	policies := &v1alpha1.FQDNGovernancePolicyList{}
+	err := reader.List(ctx, policies)
+	switch {
+	case meta.IsNoMatchError(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	// The labels are only read if a policy has a namespace selector
+	var namespaceLabels labels.Set
+	selected := make([]v1alpha1.FQDNGovernancePolicy, 0, len(policies.Items))
+	for _, policy := range policies.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+		if err != nil {
+			// An invalid selector selects all namespaces, so the policy is not bypassed
+			selected = append(selected, policy)
+			continue
+		}
+		if !selector.Empty() && namespaceLabels == nil {
+			ns := &corev1.Namespace{}
+			if err := reader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+				return nil, err
+			}
+			namespaceLabels = labels.Set(ns.Labels)
+			if namespaceLabels == nil {
+				namespaceLabels = labels.Set{}
+			}
+		}
+		if selector.Empty() || selector.Matches(namespaceLabels) {
+			selected = append(selected, policy)
+		}
+	}
+	return v1alpha1.JoinFQDNGovernance(compileFQDNGovernance(selected, policies.Items)...), nil
+}
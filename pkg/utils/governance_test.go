@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mransonwang/fqdn-egress-operator/api/v1alpha1"
+)
+
+func cachedFQDNGovernance(name string) *v1alpha1.FQDNGovernance {
+	governanceCache.Lock()
+	defer governanceCache.Unlock()
+	return governanceCache.policies[name].governance
+}
+
+func TestGetFQDNGovernanceCache(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	policy := &v1alpha1.FQDNGovernancePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenants"},
+		Spec: v1alpha1.FQDNGovernancePolicySpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+			DeniedRegexes:     []string{`admin\..*`},
+		},
+	}
+	tenant := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Labels: map[string]string{"tenant": "true"}}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(policy, tenant, other).Build()
+
+	governance, err := GetFQDNGovernance(ctx, reader, "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if governance.Violation("admin.example.com") == "" {
+		t.Error("expected admin.example.com to be denied")
+	}
+	compiled := cachedFQDNGovernance(policy.Name)
+	if _, err := GetFQDNGovernance(ctx, reader, "tenant"); err != nil {
+		t.Fatal(err)
+	}
+	if cachedFQDNGovernance(policy.Name) != compiled {
+		t.Error("expected the unchanged policy not to be compiled again")
+	}
+	if governance, err := GetFQDNGovernance(ctx, reader, "default"); err != nil || governance != nil {
+		t.Errorf("expected no governance for a namespace not selected, got %v, %v", governance, err)
+	}
+
+	policy.Spec.DeniedRegexes = []string{`api\..*`}
+	if err := reader.Update(ctx, policy); err != nil {
+		t.Fatal(err)
+	}
+	governance, err = GetFQDNGovernance(ctx, reader, "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if governance.Violation("admin.example.com") != "" || governance.Violation("api.example.com") == "" {
+		t.Error("expected the updated policy to be applied")
+	}
+	if cachedFQDNGovernance(policy.Name) == compiled {
+		t.Error("expected the updated policy to be compiled again")
+	}
+
+	if err := reader.Delete(ctx, policy); err != nil {
+		t.Fatal(err)
+	}
+	if governance, err := GetFQDNGovernance(ctx, reader, "tenant"); err != nil || governance != nil {
+		t.Errorf("expected no governance without policies, got %v, %v", governance, err)
+	}
+	if cachedFQDNGovernance(policy.Name) != nil {
+		t.Error("expected the removed policy to be dropped from the cache")
+	}
+}
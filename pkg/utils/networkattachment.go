@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// NetworkAttachmentDefinitionGVK is the kind of the secondary networks selected by the target networks of FQDN network
+// policies. The Multus API is not imported, the objects are read as unstructured objects.
+var NetworkAttachmentDefinitionGVK = schema.GroupVersionKind{
+	Group: "k8s.cni.cncf.io", Version: "v1", Kind: "NetworkAttachmentDefinition",
+}
+
+// NewNetworkAttachmentDefinition returns an empty NetworkAttachmentDefinition to read into
+func NewNetworkAttachmentDefinition() *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(NetworkAttachmentDefinitionGVK)
+	return object
+}
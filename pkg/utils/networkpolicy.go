@@ -1,36 +1,84 @@
 package utils
 
 import (
+	"cmp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // Remove duplicate CIDRs in MultiNetworkPolicy
 func RemoveDuplicateCidrsInNetworkPolicy(networkPolicy *mnetv1beta1.MultiNetworkPolicy) {
-	if networkPolicy == nil || len(networkPolicy.Spec.Egress) == 0 {
+	if networkPolicy == nil {
 		return
 	}
 
+	if len(networkPolicy.Spec.Egress) > 0 {
+		rules := make([]portsAndPeers, len(networkPolicy.Spec.Egress))
+		for i, rule := range networkPolicy.Spec.Egress {
+			rules[i] = portsAndPeers{ports: rule.Ports, peers: rule.To}
+		}
+		merged := mergeRulesByPorts(rules)
+		networkPolicy.Spec.Egress = make([]mnetv1beta1.MultiNetworkPolicyEgressRule, len(merged))
+		for i, rule := range merged {
+			networkPolicy.Spec.Egress[i] = mnetv1beta1.MultiNetworkPolicyEgressRule{Ports: rule.ports, To: rule.peers}
+		}
+	}
+
+	if len(networkPolicy.Spec.Ingress) > 0 {
+		rules := make([]portsAndPeers, len(networkPolicy.Spec.Ingress))
+		for i, rule := range networkPolicy.Spec.Ingress {
+			rules[i] = portsAndPeers{ports: rule.Ports, peers: rule.From}
+		}
+		merged := mergeRulesByPorts(rules)
+		networkPolicy.Spec.Ingress = make([]mnetv1beta1.MultiNetworkPolicyIngressRule, len(merged))
+		for i, rule := range merged {
+			networkPolicy.Spec.Ingress[i] = mnetv1beta1.MultiNetworkPolicyIngressRule{Ports: rule.ports, From: rule.peers}
+		}
+	}
+}
+
+// portsAndPeers holds the ports and peers of an Egress or Ingress rule
+type portsAndPeers struct {
+	ports []mnetv1beta1.MultiNetworkPolicyPort
+	peers []mnetv1beta1.MultiNetworkPolicyPeer
+}
+
+// mergeRulesByPorts collects the ports allowed for each CIDR across the rules and groups the CIDRs allowed on the
+// same ports into one rule. A rule without ports allows all ports, so a CIDR of such a rule is allowed on all ports
+// whatever ports other rules allow it on. The rules are sorted by their ports, the peers of a rule by CIDR.
+func mergeRulesByPorts(rules []portsAndPeers) []portsAndPeers {
 	totalPeers := 0
-	for i := range networkPolicy.Spec.Egress {
-		totalPeers += len(networkPolicy.Spec.Egress[i].To)
+	for i := range rules {
+		totalPeers += len(rules[i].peers)
 	}
 
 	cidrToPortsMap := make(map[string]map[string]mnetv1beta1.MultiNetworkPolicyPort, totalPeers)
+	// IP blocks with except blocks are kept apart from the plain CIDR, keyed by getIPBlockKey
+	cidrToIPBlock := make(map[string]mnetv1beta1.IPBlock, totalPeers)
+	allPortsCidrs := make(map[string]bool)
 
-	for _, rule := range networkPolicy.Spec.Egress {
-		for _, peer := range rule.To {
+	for _, rule := range rules {
+		for _, peer := range rule.peers {
 			if peer.IPBlock == nil || peer.IPBlock.CIDR == "" {
 				continue
 			}
-			cidr := peer.IPBlock.CIDR
+			cidr := getIPBlockKey(*peer.IPBlock)
+			cidrToIPBlock[cidr] = *peer.IPBlock.DeepCopy()
 			if _, ok := cidrToPortsMap[cidr]; !ok {
 				cidrToPortsMap[cidr] = make(map[string]mnetv1beta1.MultiNetworkPolicyPort, 8)
 			}
+			if len(rule.ports) == 0 {
+				allPortsCidrs[cidr] = true
+			}
 
-			for _, p := range rule.Ports {
+			for _, p := range rule.ports {
 				pKey := getSinglePortKey(p)
 				cidrToPortsMap[cidr][pKey] = p
 			}
@@ -41,13 +89,17 @@ func RemoveDuplicateCidrsInNetworkPolicy(networkPolicy *mnetv1beta1.MultiNetwork
 	fingerprintToPortSlice := make(map[string][]mnetv1beta1.MultiNetworkPolicyPort, 16)
 
 	for cidr, portsMap := range cidrToPortsMap {
-		portSlice := make([]mnetv1beta1.MultiNetworkPolicyPort, 0, len(portsMap))
-		for _, p := range portsMap {
-			portSlice = append(portSlice, p)
+		var portSlice []mnetv1beta1.MultiNetworkPolicyPort
+		if !allPortsCidrs[cidr] {
+			portSlice = make([]mnetv1beta1.MultiNetworkPolicyPort, 0, len(portsMap))
+			for _, p := range portsMap {
+				portSlice = append(portSlice, p)
+			}
+			portSlice = mergePortRanges(portSlice)
 		}
-		
+
 		f := getPortsFingerprint(portSlice)
-		
+
 		if _, ok := portsFingerprintToCidrs[f]; !ok {
 			portsFingerprintToCidrs[f] = make([]string, 0)
 			fingerprintToPortSlice[f] = portSlice
@@ -55,8 +107,8 @@ func RemoveDuplicateCidrsInNetworkPolicy(networkPolicy *mnetv1beta1.MultiNetwork
 		portsFingerprintToCidrs[f] = append(portsFingerprintToCidrs[f], cidr)
 	}
 
-	newEgressRules := make([]mnetv1beta1.MultiNetworkPolicyEgressRule, 0, len(portsFingerprintToCidrs))
-	
+	newRules := make([]portsAndPeers, 0, len(portsFingerprintToCidrs))
+
 	sortedFingerprints := make([]string, 0, len(portsFingerprintToCidrs))
 	for f := range portsFingerprintToCidrs {
 		sortedFingerprints = append(sortedFingerprints, f)
@@ -69,18 +121,29 @@ func RemoveDuplicateCidrsInNetworkPolicy(networkPolicy *mnetv1beta1.MultiNetwork
 
 		peers := make([]mnetv1beta1.MultiNetworkPolicyPeer, len(cidrs))
 		for i, c := range cidrs {
+			block := cidrToIPBlock[c]
 			peers[i] = mnetv1beta1.MultiNetworkPolicyPeer{
-				IPBlock: &mnetv1beta1.IPBlock{CIDR: c},
+				IPBlock: &block,
 			}
 		}
 
-		newEgressRules = append(newEgressRules, mnetv1beta1.MultiNetworkPolicyEgressRule{
-			Ports: fingerprintToPortSlice[f],
-			To:    peers,
+		newRules = append(newRules, portsAndPeers{
+			ports: fingerprintToPortSlice[f],
+			peers: peers,
 		})
 	}
 
-	networkPolicy.Spec.Egress = newEgressRules
+	return newRules
+}
+
+// getIPBlockKey returns the CIDR of the block, followed by its sorted except blocks if any
+func getIPBlockKey(block mnetv1beta1.IPBlock) string {
+	if len(block.Except) == 0 {
+		return block.CIDR
+	}
+	except := slices.Clone(block.Except)
+	slices.Sort(except)
+	return block.CIDR + " except " + strings.Join(except, ",")
 }
 
 func getSinglePortKey(p mnetv1beta1.MultiNetworkPolicyPort) string {
@@ -91,10 +154,83 @@ func getSinglePortKey(p mnetv1beta1.MultiNetworkPolicyPort) string {
 	port := "any"
 	if p.Port != nil {
 		port = p.Port.String()
+		if p.EndPort != nil && p.Port.Type == intstr.Int && *p.EndPort > p.Port.IntVal {
+			port += "-" + strconv.Itoa(int(*p.EndPort))
+		}
 	}
 	return protocol + ":" + port
 }
 
+// mergePortRanges merges overlapping and adjacent port ranges of the same protocol. A port without a number allows
+// all ports of its protocol and replaces the ranges of the protocol. The result is sorted by protocol and port.
+func mergePortRanges(ports []mnetv1beta1.MultiNetworkPolicyPort) []mnetv1beta1.MultiNetworkPolicyPort {
+	type portRange struct{ start, end int32 }
+	byProtocol := make(map[corev1.Protocol][]portRange)
+	allPorts := make(map[corev1.Protocol]bool)
+	var named []mnetv1beta1.MultiNetworkPolicyPort
+	for _, p := range ports {
+		protocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			protocol = *p.Protocol
+		}
+		switch {
+		case p.Port == nil:
+			allPorts[protocol] = true
+		case p.Port.Type != intstr.Int:
+			named = append(named, p)
+		default:
+			end := p.Port.IntVal
+			if p.EndPort != nil && *p.EndPort > end {
+				end = *p.EndPort
+			}
+			byProtocol[protocol] = append(byProtocol[protocol], portRange{start: p.Port.IntVal, end: end})
+		}
+	}
+
+	protocols := make([]corev1.Protocol, 0, len(byProtocol)+len(allPorts))
+	for protocol := range byProtocol {
+		protocols = append(protocols, protocol)
+	}
+	for protocol := range allPorts {
+		if _, ok := byProtocol[protocol]; !ok {
+			protocols = append(protocols, protocol)
+		}
+	}
+	slices.Sort(protocols)
+
+	merged := make([]mnetv1beta1.MultiNetworkPolicyPort, 0, len(ports))
+	for _, protocol := range protocols {
+		if allPorts[protocol] {
+			merged = append(merged, mnetv1beta1.MultiNetworkPolicyPort{Protocol: &protocol})
+			continue
+		}
+		ranges := byProtocol[protocol]
+		slices.SortFunc(ranges, func(a, b portRange) int { return cmp.Compare(a.start, b.start) })
+		current := ranges[0]
+		flush := func(r portRange) {
+			port := intstr.FromInt32(r.start)
+			p := mnetv1beta1.MultiNetworkPolicyPort{Protocol: &protocol, Port: &port}
+			if r.end > r.start {
+				p.EndPort = &r.end
+			}
+			merged = append(merged, p)
+		}
+		for _, r := range ranges[1:] {
+			if r.start <= current.end+1 {
+				current.end = max(current.end, r.end)
+				continue
+			}
+			flush(current)
+			current = r
+		}
+		flush(current)
+	}
+	slices.SortFunc(named, func(a, b mnetv1beta1.MultiNetworkPolicyPort) int {
+		return cmp.Compare(getSinglePortKey(a), getSinglePortKey(b))
+	})
+	return append(merged, named...)
+}
+
 func getPortsFingerprint(ports []mnetv1beta1.MultiNetworkPolicyPort) string {
 	if len(ports) == 0 {
 		return "all-ports"
@@ -104,7 +240,7 @@ func getPortsFingerprint(ports []mnetv1beta1.MultiNetworkPolicyPort) string {
 	for i, p := range ports {
 		tmp[i] = getSinglePortKey(p)
 	}
-	
+
 	sort.Strings(tmp)
 	return strings.Join(tmp, ",")
 }
@@ -118,6 +254,9 @@ func CountDeDupedAddresses(networkPolicy *mnetv1beta1.MultiNetworkPolicy) int {
 	for i := range networkPolicy.Spec.Egress {
 		count += len(networkPolicy.Spec.Egress[i].To)
 	}
+	for i := range networkPolicy.Spec.Ingress {
+		count += len(networkPolicy.Spec.Ingress[i].From)
+	}
 
 	return count
 }
@@ -125,6 +264,75 @@ func CountDeDupedAddresses(networkPolicy *mnetv1beta1.MultiNetworkPolicy) int {
 func IsEmpty(networkPolicy *mnetv1beta1.MultiNetworkPolicy) bool {
 	if networkPolicy == nil {
 		return true
-	}	
+	}
 	return len(networkPolicy.Spec.Ingress) == 0 && len(networkPolicy.Spec.Egress) == 0
 }
+
+// ToNetworkPolicy converts a MultiNetworkPolicy to a networking.k8s.io/v1 NetworkPolicy with the same selectors,
+// rules and metadata
+func ToNetworkPolicy(networkPolicy *mnetv1beta1.MultiNetworkPolicy) *networkingv1.NetworkPolicy {
+	if networkPolicy == nil {
+		return nil
+	}
+
+	result := &networkingv1.NetworkPolicy{
+		ObjectMeta: *networkPolicy.ObjectMeta.DeepCopy(),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *networkPolicy.Spec.PodSelector.DeepCopy(),
+		},
+	}
+	for _, rule := range networkPolicy.Spec.Ingress {
+		result.Spec.Ingress = append(result.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: toNetworkPolicyPorts(rule.Ports),
+			From:  toNetworkPolicyPeers(rule.From),
+		})
+	}
+	for _, rule := range networkPolicy.Spec.Egress {
+		result.Spec.Egress = append(result.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+			Ports: toNetworkPolicyPorts(rule.Ports),
+			To:    toNetworkPolicyPeers(rule.To),
+		})
+	}
+	for _, policyType := range networkPolicy.Spec.PolicyTypes {
+		result.Spec.PolicyTypes = append(result.Spec.PolicyTypes, networkingv1.PolicyType(policyType))
+	}
+	return result
+}
+
+func toNetworkPolicyPorts(ports []mnetv1beta1.MultiNetworkPolicyPort) []networkingv1.NetworkPolicyPort {
+	if ports == nil {
+		return nil
+	}
+	result := make([]networkingv1.NetworkPolicyPort, 0, len(ports))
+	for _, p := range ports {
+		p := *p.DeepCopy()
+		result = append(result, networkingv1.NetworkPolicyPort{
+			Protocol: p.Protocol,
+			Port:     p.Port,
+			EndPort:  p.EndPort,
+		})
+	}
+	return result
+}
+
+func toNetworkPolicyPeers(peers []mnetv1beta1.MultiNetworkPolicyPeer) []networkingv1.NetworkPolicyPeer {
+	if peers == nil {
+		return nil
+	}
+	result := make([]networkingv1.NetworkPolicyPeer, 0, len(peers))
+	for _, peer := range peers {
+		peer := *peer.DeepCopy()
+		converted := networkingv1.NetworkPolicyPeer{
+			PodSelector:       peer.PodSelector,
+			NamespaceSelector: peer.NamespaceSelector,
+		}
+		if peer.IPBlock != nil {
+			converted.IPBlock = &networkingv1.IPBlock{
+				CIDR:   peer.IPBlock.CIDR,
+				Except: peer.IPBlock.Except,
+			}
+		}
+		result = append(result, converted)
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"testing"
+
+	mnetv1beta1 "github.com/k8snetworkplumbingwg/multi-networkpolicy/pkg/apis/k8s.cni.cncf.io/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func port(protocol corev1.Protocol, port int, endPort int32) mnetv1beta1.MultiNetworkPolicyPort {
+	p := intstr.FromInt32(int32(port))
+	result := mnetv1beta1.MultiNetworkPolicyPort{Protocol: &protocol, Port: &p}
+	if endPort != 0 {
+		result.EndPort = &endPort
+	}
+	return result
+}
+
+func cidrPeer(cidr string, except ...string) mnetv1beta1.MultiNetworkPolicyPeer {
+	return mnetv1beta1.MultiNetworkPolicyPeer{IPBlock: &mnetv1beta1.IPBlock{CIDR: cidr, Except: except}}
+}
+
+func TestToNetworkPolicy(t *testing.T) {
+	if ToNetworkPolicy(nil) != nil {
+		t.Error("expected nil for a nil MultiNetworkPolicy")
+	}
+
+	selector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	mnp := &mnetv1beta1.MultiNetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"team": "a"}},
+		Spec: mnetv1beta1.MultiNetworkPolicySpec{
+			PodSelector: selector,
+			Ingress: []mnetv1beta1.MultiNetworkPolicyIngressRule{{
+				Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(corev1.ProtocolTCP, 443, 0)},
+				From:  []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("198.51.100.0/24")},
+			}},
+			Egress: []mnetv1beta1.MultiNetworkPolicyEgressRule{{
+				Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(corev1.ProtocolUDP, 8000, 8080)},
+				To: []mnetv1beta1.MultiNetworkPolicyPeer{
+					cidrPeer("192.0.2.0/24", "192.0.2.128/25"),
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "dns"}}},
+				},
+			}},
+			PolicyTypes: []mnetv1beta1.MultiPolicyType{mnetv1beta1.PolicyTypeIngress, mnetv1beta1.PolicyTypeEgress},
+		},
+	}
+
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	https, low := intstr.FromInt32(443), intstr.FromInt32(8000)
+	high := int32(8080)
+	expected := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"team": "a"}},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: selector,
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &https}},
+				From:  []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "198.51.100.0/24"}}},
+			}},
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &low, EndPort: &high}},
+				To: []networkingv1.NetworkPolicyPeer{
+					{IPBlock: &networkingv1.IPBlock{CIDR: "192.0.2.0/24", Except: []string{"192.0.2.128/25"}}},
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "dns"}}},
+				},
+			}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}
+	converted := ToNetworkPolicy(mnp)
+	if !equality.Semantic.DeepEqual(converted, expected) {
+		t.Errorf("expected %+v, got %+v", expected, converted)
+	}
+
+	// The conversion does not share memory with the MultiNetworkPolicy
+	*converted.Spec.Egress[0].Ports[0].EndPort = 9000
+	converted.Spec.Egress[0].To[0].IPBlock.Except[0] = "192.0.2.0/25"
+	if *mnp.Spec.Egress[0].Ports[0].EndPort != 8080 || mnp.Spec.Egress[0].To[0].IPBlock.Except[0] != "192.0.2.128/25" {
+		t.Error("expected the converted network policy to be a deep copy")
+	}
+}
+
+func TestToNetworkPolicyKeepsAllPortsRules(t *testing.T) {
+	mnp := &mnetv1beta1.MultiNetworkPolicy{Spec: mnetv1beta1.MultiNetworkPolicySpec{
+		Egress: []mnetv1beta1.MultiNetworkPolicyEgressRule{{
+			To: []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.1/32")},
+		}},
+		PolicyTypes: []mnetv1beta1.MultiPolicyType{mnetv1beta1.PolicyTypeEgress},
+	}}
+	converted := ToNetworkPolicy(mnp)
+	if len(converted.Spec.Egress) != 1 || converted.Spec.Egress[0].Ports != nil {
+		t.Errorf("expected one rule allowing all ports, got %+v", converted.Spec.Egress)
+	}
+	if converted.Spec.Ingress != nil {
+		t.Errorf("expected no Ingress rules, got %+v", converted.Spec.Ingress)
+	}
+}
+
+func TestGetSinglePortKey(t *testing.T) {
+	named := intstr.FromString("https")
+	tests := []struct {
+		port     mnetv1beta1.MultiNetworkPolicyPort
+		expected string
+	}{
+		{mnetv1beta1.MultiNetworkPolicyPort{}, "TCP:any"},
+		{port(corev1.ProtocolUDP, 53, 0), "UDP:53"},
+		{port(corev1.ProtocolTCP, 8000, 8080), "TCP:8000-8080"},
+		// An end port below the port is ignored like a single port
+		{port(corev1.ProtocolTCP, 8000, 80), "TCP:8000"},
+		{mnetv1beta1.MultiNetworkPolicyPort{Port: &named}, "TCP:https"},
+	}
+	for _, test := range tests {
+		if key := getSinglePortKey(test.port); key != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, key)
+		}
+	}
+}
+
+func TestMergePortRanges(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	named := intstr.FromString("https")
+	tests := []struct {
+		name     string
+		ports    []mnetv1beta1.MultiNetworkPolicyPort
+		expected []mnetv1beta1.MultiNetworkPolicyPort
+	}{
+		{
+			name:     "overlapping ranges",
+			ports:    []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 8050, 8100), port(tcp, 8000, 8080)},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 8000, 8100)},
+		},
+		{
+			name:     "adjacent ranges",
+			ports:    []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 8000, 8080), port(tcp, 8081, 8090)},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 8000, 8090)},
+		},
+		{
+			name:     "disjoint ranges",
+			ports:    []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 9000, 9010), port(tcp, 8000, 8080)},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 8000, 8080), port(tcp, 9000, 9010)},
+		},
+		{
+			name: "a range with single ports",
+			ports: []mnetv1beta1.MultiNetworkPolicyPort{
+				port(tcp, 8080, 0), port(tcp, 8000, 8080), port(tcp, 8081, 0), port(tcp, 443, 0),
+			},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 443, 0), port(tcp, 8000, 8081)},
+		},
+		{
+			name:     "protocols are merged separately",
+			ports:    []mnetv1beta1.MultiNetworkPolicyPort{port(corev1.ProtocolUDP, 53, 0), port(tcp, 53, 0)},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 53, 0), port(corev1.ProtocolUDP, 53, 0)},
+		},
+		{
+			name:     "a port without a number allows all ports of its protocol",
+			ports:    []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 8000, 8080), {Protocol: &tcp}},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{{Protocol: &tcp}},
+		},
+		{
+			name:     "named ports are kept after the numbered ports",
+			ports:    []mnetv1beta1.MultiNetworkPolicyPort{{Protocol: &tcp, Port: &named}, port(tcp, 443, 0)},
+			expected: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 443, 0), {Protocol: &tcp, Port: &named}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if merged := mergePortRanges(test.ports); !equality.Semantic.DeepEqual(merged, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, merged)
+			}
+		})
+	}
+}
+
+func TestRemoveDuplicateCidrsInNetworkPolicy(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	mnp := &mnetv1beta1.MultiNetworkPolicy{Spec: mnetv1beta1.MultiNetworkPolicySpec{
+		Egress: []mnetv1beta1.MultiNetworkPolicyEgressRule{
+			{
+				Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 443, 0)},
+				To:    []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.1/32"), cidrPeer("192.0.2.2/32")},
+			},
+			{
+				Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 80, 0)},
+				To:    []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.2/32"), cidrPeer("192.0.2.3/32")},
+			},
+			// A rule without ports allows all ports, whatever ports the CIDR is allowed on by other rules
+			{To: []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.3/32"), cidrPeer("192.0.2.4/32")}},
+		},
+		Ingress: []mnetv1beta1.MultiNetworkPolicyIngressRule{
+			{
+				Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 443, 0)},
+				From:  []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("198.51.100.1/32")},
+			},
+			{From: []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("198.51.100.1/32")}},
+		},
+	}}
+	RemoveDuplicateCidrsInNetworkPolicy(mnp)
+
+	expectedEgress := []mnetv1beta1.MultiNetworkPolicyEgressRule{
+		{
+			Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 443, 0)},
+			To:    []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.1/32")},
+		},
+		{
+			Ports: []mnetv1beta1.MultiNetworkPolicyPort{port(tcp, 80, 0), port(tcp, 443, 0)},
+			To:    []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.2/32")},
+		},
+		{To: []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("192.0.2.3/32"), cidrPeer("192.0.2.4/32")}},
+	}
+	expectedIngress := []mnetv1beta1.MultiNetworkPolicyIngressRule{
+		{From: []mnetv1beta1.MultiNetworkPolicyPeer{cidrPeer("198.51.100.1/32")}},
+	}
+	if !equality.Semantic.DeepEqual(mnp.Spec.Egress, expectedEgress) {
+		t.Errorf("expected the egress rules %v, got %v", expectedEgress, mnp.Spec.Egress)
+	}
+	if !equality.Semantic.DeepEqual(mnp.Spec.Ingress, expectedIngress) {
+		t.Errorf("expected the ingress rules %v, got %v", expectedIngress, mnp.Spec.Ingress)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -13,6 +14,13 @@ func typeName(obj interface{}) string {
 		return "Unknown"
 	}
 
+	// Unstructured objects only carry their kind in the type meta
+	if object, ok := obj.(runtime.Object); ok {
+		if kind := object.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+			return kind
+		}
+	}
+
 	t := reflect.TypeOf(obj)
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -20,6 +28,11 @@ func typeName(obj interface{}) string {
 	return t.Name()
 }
 
+// Kind returns the kind of the object, or the name of its type if the kind is not set
+func Kind(object client.Object) string {
+	return typeName(object)
+}
+
 func OperationErrorReason(object client.Object) string {
 	return fmt.Sprintf("%sError", typeName(object))
 }